@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeysCheckInterval throttles how often the key file is stat'ed for
+// changes; a rotated key takes effect within this long.
+const apiKeysCheckInterval = 5 * time.Second
+
+var (
+	validAPIKeyName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	validKeyHash    = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	// Scopes are resource:verb, e.g. tasks:dispatch, kv:read, missions:*.
+	validScope = regexp.MustCompile(`^(\*|[a-z]+:(\*|[a-z]+))$`)
+)
+
+// apiKeysFile is the API_KEYS_FILE document. Keys are stored as the hex
+// SHA-256 of the secret ("sha256:..."), never in the clear.
+type apiKeysFile struct {
+	Keys []storedAPIKey `json:"keys"`
+}
+
+type storedAPIKey struct {
+	Name    string     `json:"name"`
+	Hash    string     `json:"hash"`
+	Role    string     `json:"role,omitempty"` // default viewer
+	Scopes  []string   `json:"scopes"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// APIKeyInfo is an entry of GET /api/auth/keys; it never includes the hash.
+type APIKeyInfo struct {
+	Name     string     `json:"name"`
+	Role     string     `json:"role"`
+	Scopes   []string   `json:"scopes"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Expired  bool       `json:"expired"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+// apiKeys is the named key store; nil unless API_KEYS_FILE is set.
+var apiKeys *apiKeyStore
+
+// apiKeyStore holds named API keys loaded from a file, reloading it when it
+// changes. A Kubernetes Secret mounted as a volume works the same way: the
+// kubelet swaps the file atomically and its modification time changes.
+type apiKeyStore struct {
+	path string
+
+	mu       sync.Mutex
+	keys     []storedAPIKey
+	digests  [][]byte // decoded Hash of keys[i]
+	modTime  time.Time
+	size     int64
+	checked  time.Time
+	lastUsed map[string]time.Time // by key name, kept across reloads
+}
+
+// newAPIKeyStore loads the key file; unlike later reloads, a bad file at
+// startup is an error.
+func newAPIKeyStore(path string) (*apiKeyStore, error) {
+	s := &apiKeyStore{path: path, lastUsed: map[string]time.Time{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadAPIKeyStore reads API_KEYS_FILE; the store is off (nil, nil) when it
+// is unset.
+func loadAPIKeyStore() (*apiKeyStore, error) {
+	path := envOr("API_KEYS_FILE", "")
+	if path == "" {
+		return nil, nil
+	}
+	return newAPIKeyStore(path)
+}
+
+// parseAPIKeys validates a key file.
+func parseAPIKeys(data []byte) ([]storedAPIKey, error) {
+	var cfg apiKeysFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	hashes := map[string]bool{}
+	for i := range cfg.Keys {
+		k := &cfg.Keys[i]
+		if !validAPIKeyName.MatchString(k.Name) {
+			return nil, fmt.Errorf("invalid key name %q", k.Name)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("key %q defined twice", k.Name)
+		}
+		k.Hash = strings.ToLower(k.Hash)
+		if !validKeyHash.MatchString(k.Hash) {
+			return nil, fmt.Errorf("key %q: hash must be sha256:<64 hex digits>", k.Name)
+		}
+		if hashes[k.Hash] {
+			return nil, fmt.Errorf("key %q reuses another key's secret", k.Name)
+		}
+		if k.Role == "" {
+			k.Role = roleViewer
+		}
+		if roleRank[k.Role] == 0 {
+			return nil, fmt.Errorf("key %q: invalid role %q", k.Name, k.Role)
+		}
+		if k.Scopes == nil {
+			k.Scopes = []string{}
+		}
+		for _, scope := range k.Scopes {
+			if !validScope.MatchString(scope) {
+				return nil, fmt.Errorf("key %q: invalid scope %q", k.Name, scope)
+			}
+		}
+		names[k.Name], hashes[k.Hash] = true, true
+	}
+	return cfg.Keys, nil
+}
+
+// load reads the key file; callers hold s.mu, or own s exclusively.
+func (s *apiKeyStore) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("read API keys: %w", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read API keys: %w", err)
+	}
+	return s.set(data, info)
+}
+
+// set replaces the keys with those in data, read from a file described by
+// info. Keys that fail to parse leave the previous ones in place.
+func (s *apiKeyStore) set(data []byte, info os.FileInfo) error {
+	keys, err := parseAPIKeys(data)
+	if err != nil {
+		return fmt.Errorf("parse API keys %s: %w", s.path, err)
+	}
+	digests := make([][]byte, len(keys))
+	for i, k := range keys {
+		digests[i], _ = hex.DecodeString(strings.TrimPrefix(k.Hash, "sha256:"))
+	}
+	s.keys, s.digests = keys, digests
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// refresh reloads the file if it changed since the last check. A file that
+// fails to parse is logged and the previous keys stay in effect; a file
+// that was removed or can't be read revokes every key.
+func (s *apiKeyStore) refresh(now time.Time) {
+	if now.Sub(s.checked) < apiKeysCheckInterval {
+		return
+	}
+	s.checked = now
+	info, err := os.Stat(s.path)
+	var data []byte
+	if err == nil {
+		if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+			return
+		}
+		data, err = os.ReadFile(s.path)
+	}
+	if err != nil {
+		if !s.modTime.IsZero() {
+			slog.Error("API key file missing or unreadable, revoking all named keys", "path", s.path, "error", err)
+		}
+		s.keys, s.digests = nil, nil
+		s.modTime, s.size = time.Time{}, 0
+		return
+	}
+	if err := s.set(data, info); err != nil {
+		slog.Error("API key file invalid, keeping previous keys", "error", err)
+		return
+	}
+	slog.Info("API keys reloaded", "path", s.path, "keys", len(s.keys))
+}
+
+// authenticate returns the key matching token and records its use. Every
+// stored digest is compared in constant time, so timing reveals nothing
+// about which key (if any) was close.
+func (s *apiKeyStore) authenticate(token string, now time.Time) (storedAPIKey, bool) {
+	sum := sha256.Sum256([]byte(token))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(now)
+	match := -1
+	for i, digest := range s.digests {
+		if subtle.ConstantTimeCompare(sum[:], digest) == 1 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return storedAPIKey{}, false
+	}
+	key := s.keys[match]
+	if key.Expires != nil && !now.Before(*key.Expires) {
+		slog.Warn("Expired API key used", "key", key.Name)
+		return storedAPIKey{}, false
+	}
+	s.lastUsed[key.Name] = now
+	return key, true
+}
+
+// active reports whether the key with this hash is still configured and
+// unexpired, for connections authenticated before a reload.
+func (s *apiKeyStore) active(hash string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(now)
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k.Expires == nil || now.Before(*k.Expires)
+		}
+	}
+	return false
+}
+
+// list describes the configured keys, sorted by name.
+func (s *apiKeyStore) list(now time.Time) []APIKeyInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(now)
+	infos := make([]APIKeyInfo, 0, len(s.keys))
+	for _, k := range s.keys {
+		info := APIKeyInfo{Name: k.Name, Role: k.Role, Scopes: k.Scopes, Expires: k.Expires}
+		info.Expired = k.Expires != nil && !now.Before(*k.Expires)
+		if used, ok := s.lastUsed[k.Name]; ok {
+			info.LastUsed = &used
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// apiKeyIdentityFor is the identity of a caller using a stored key.
+func apiKeyIdentityFor(key storedAPIKey) Identity {
+	return Identity{Subject: "key:" + key.Name, Name: key.Name, Groups: []string{}, Scopes: key.Scopes, Method: "api-key"}
+}
+
+// taskDispatchRoutes are the POST routes that publish a task to a knight.
+// They need tasks:dispatch whatever resource they sit under, and share the
+// dispatch rate limit.
+var taskDispatchRoutes = map[string]bool{
+	"/api/tasks/dispatch":   true,
+	"/api/briefings/rollup": true,
+}
+
+// routeScope is the scope a stored key needs for a method on a route
+// template: "<first path segment>:read" for reads, ":write" otherwise, and
+// tasks:dispatch for routes that dispatch knight tasks. Identity endpoints
+// need no scope.
+func routeScope(method, template string) string {
+	resource := strings.SplitN(strings.TrimPrefix(template, "/api/"), "/", 2)[0]
+	switch {
+	case resource == "auth" && template != "/api/auth/keys":
+		return ""
+	case method == http.MethodPost && taskDispatchRoutes[template]:
+		return "tasks:dispatch"
+	case template == "/api/ws/ticket":
+		return "ws:read"
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+// hasScope reports whether the caller may use scope. Identities without a
+// scope list (OIDC users, the shared DASHBOARD_API_KEY) are limited by
+// their role only.
+func hasScope(id Identity, scope string) bool {
+	if id.Scopes == nil || scope == "" {
+		return true
+	}
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, s := range id.Scopes {
+		if s == "*" || s == scope || s == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeysHandler serves GET /auth/keys: the named keys with their scopes,
+// expiry and when each was last used.
+func apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if apiKeys == nil {
+		http.Error(w, "API key store not configured (set API_KEYS_FILE)", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeys.list(time.Now()))
+}
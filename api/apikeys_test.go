@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func testKeyHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func writeTestKeys(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys([]byte(fmt.Sprintf(`{"keys":[{"name":"ci","hash":%q,"scopes":["tasks:dispatch","kv:*"]}]}`, testKeyHash("a"))))
+	if err != nil || len(keys) != 1 || keys[0].Role != roleViewer {
+		t.Fatalf("expected one viewer key, got %+v %v", keys, err)
+	}
+
+	for _, bad := range []string{
+		`{"keys":[{"name":"CI","hash":"` + testKeyHash("a") + `"}]}`,
+		`{"keys":[{"name":"ci","hash":"plaintext"}]}`,
+		`{"keys":[{"name":"ci","hash":"` + testKeyHash("a") + `","role":"root"}]}`,
+		`{"keys":[{"name":"ci","hash":"` + testKeyHash("a") + `","scopes":["dispatch"]}]}`,
+		`{"keys":[{"name":"ci","hash":"` + testKeyHash("a") + `"},{"name":"ci","hash":"` + testKeyHash("b") + `"}]}`,
+		`{"keys":[{"name":"ci","hash":"` + testKeyHash("a") + `"},{"name":"cd","hash":"` + testKeyHash("a") + `"}]}`,
+		`{"keys":[{"name":"ci","hash":"` + testKeyHash("a") + `","secret":"a"}]}`,
+	} {
+		if _, err := parseAPIKeys([]byte(bad)); err == nil {
+			t.Errorf("expected %s rejected", bad)
+		}
+	}
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[
+		{"name":"ci","hash":%q,"role":"operator","scopes":["tasks:dispatch"]},
+		{"name":"old","hash":%q,"expires":"2020-01-01T00:00:00Z"}
+	]}`, testKeyHash("ci-secret"), testKeyHash("old-secret")))
+	store, err := newAPIKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if key, ok := store.authenticate("ci-secret", now); !ok || key.Name != "ci" || key.Role != roleOperator {
+		t.Errorf("expected ci key, got %+v %v", key, ok)
+	}
+	if _, ok := store.authenticate("ci-secreT", now); ok {
+		t.Error("expected wrong secret rejected")
+	}
+	if _, ok := store.authenticate("old-secret", now); ok {
+		t.Error("expected expired key rejected")
+	}
+	infos := store.list(now)
+	if len(infos) != 2 || infos[0].Name != "ci" || infos[0].LastUsed == nil || !infos[1].Expired || infos[1].LastUsed != nil {
+		t.Errorf("unexpected key list %+v", infos)
+	}
+
+	// Rotation: the file is reloaded once the check interval has passed,
+	// and last-used times survive the reload
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[{"name":"ci","hash":%q,"scopes":["*"]}]}`, testKeyHash("ci-rotated")))
+	later := now.Add(apiKeysCheckInterval)
+	if _, ok := store.authenticate("ci-secret", later); ok {
+		t.Error("expected rotated-out secret rejected")
+	}
+	if _, ok := store.authenticate("ci-rotated", later); !ok {
+		t.Error("expected rotated secret accepted")
+	}
+	if infos := store.list(later); len(infos) != 1 || infos[0].LastUsed == nil || !infos[0].LastUsed.Equal(later) {
+		t.Errorf("unexpected key list after reload %+v", infos)
+	}
+
+	// A broken file keeps the previous keys
+	writeTestKeys(t, path, `{"keys":[{"name":"ci"`)
+	if _, ok := store.authenticate("ci-rotated", later.Add(apiKeysCheckInterval)); !ok {
+		t.Error("expected previous keys kept when the file is invalid")
+	}
+
+	// Removing the file revokes every key until it's back
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.authenticate("ci-rotated", later.Add(2*apiKeysCheckInterval)); ok {
+		t.Error("expected keys revoked when the file is removed")
+	}
+	if infos := store.list(later); len(infos) != 0 {
+		t.Errorf("expected no keys listed, got %+v", infos)
+	}
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[{"name":"ci","hash":%q,"scopes":["*"]}]}`, testKeyHash("ci-rotated")))
+	if _, ok := store.authenticate("ci-rotated", later.Add(3*apiKeysCheckInterval)); !ok {
+		t.Error("expected keys back once the file is restored")
+	}
+
+	if _, err := newAPIKeyStore(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected missing key file rejected at startup")
+	}
+}
+
+func TestRouteScope(t *testing.T) {
+	tests := []struct{ method, template, scope string }{
+		{"GET", "/api/kv/{bucket}/{key}", "kv:read"},
+		{"PUT", "/api/kv/{bucket}/{key}", "kv:write"},
+		{"POST", "/api/tasks/dispatch", "tasks:dispatch"},
+		{"POST", "/api/briefings/rollup", "tasks:dispatch"},
+		{"GET", "/api/briefings/rollup", "briefings:read"},
+		{"GET", "/api/briefings/rollup/{task_id}", "briefings:read"},
+		{"POST", "/api/briefings/{date}/notes", "briefings:write"},
+		{"POST", "/api/missions", "missions:write"},
+		{"GET", "/api/auth/me", ""},
+		{"GET", "/api/auth/keys", "auth:read"},
+	}
+	for _, tt := range tests {
+		if got := routeScope(tt.method, tt.template); got != tt.scope {
+			t.Errorf("routeScope(%s %s) = %q, want %q", tt.method, tt.template, got, tt.scope)
+		}
+	}
+
+	scoped := Identity{Scopes: []string{"tasks:dispatch", "kv:*"}}
+	for scope, want := range map[string]bool{"tasks:dispatch": true, "kv:write": true, "tasks:read": false, "missions:write": false} {
+		if hasScope(scoped, scope) != want {
+			t.Errorf("hasScope(%s) = %v, want %v", scope, !want, want)
+		}
+	}
+	if !hasScope(Identity{}, "missions:write") || hasScope(Identity{Scopes: []string{}}, "fleet:read") {
+		t.Error("expected nil scopes unrestricted and empty scopes to allow nothing")
+	}
+}
+
+func TestAuthMiddlewareAPIKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[
+		{"name":"ci","hash":%q,"role":"operator","scopes":["tasks:dispatch","fleet:read"]},
+		{"name":"ops","hash":%q,"role":"admin","scopes":["*"]}
+	]}`, testKeyHash("ci-secret"), testKeyHash("ops-secret")))
+	var err error
+	if apiKeys, err = newAPIKeyStore(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { apiKeys = nil }()
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.Use(rbacMiddleware)
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/fleet", ok).Methods("GET")
+	api.HandleFunc("/tasks/dispatch", ok).Methods("POST")
+	api.HandleFunc("/missions", ok).Methods("POST")
+	api.HandleFunc("/auth/me", authMeHandler).Methods("GET")
+	api.HandleFunc("/auth/keys", apiKeysHandler).Methods("GET")
+
+	do := func(key, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		key, method, path string
+		expected          int
+	}{
+		{"ci-secret", "GET", "/api/fleet", http.StatusOK},
+		{"ci-secret", "POST", "/api/tasks/dispatch", http.StatusOK},
+		{"ci-secret", "POST", "/api/missions", http.StatusForbidden},
+		{"ci-secret", "GET", "/api/auth/keys", http.StatusForbidden},
+		{"ops-secret", "POST", "/api/missions", http.StatusOK},
+		{"wrong", "GET", "/api/fleet", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := do(tt.key, tt.method, tt.path); w.Code != tt.expected {
+			t.Errorf("%s %s %s: expected %d, got %d %s", tt.key, tt.method, tt.path, tt.expected, w.Code, w.Body.String())
+		}
+	}
+	if w := do("ci-secret", "POST", "/api/missions"); !strings.Contains(w.Body.String(), `"requiredScope":"missions:write"`) {
+		t.Errorf("expected 403 naming the missing scope, got %s", w.Body.String())
+	}
+
+	var me Identity
+	json.Unmarshal(do("ci-secret", "GET", "/api/auth/me").Body.Bytes(), &me)
+	if me.Subject != "key:ci" || me.Method != "api-key" {
+		t.Errorf("expected ci key identity, got %+v", me)
+	}
+
+	var infos []APIKeyInfo
+	json.Unmarshal(do("ops-secret", "GET", "/api/auth/keys").Body.Bytes(), &infos)
+	if len(infos) != 2 || infos[0].Name != "ci" || infos[0].LastUsed == nil {
+		t.Errorf("expected key list with last-used times, got %+v", infos)
+	}
+}
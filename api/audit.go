@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// auditMemorySize is how many records are kept in memory, the fallback
+	// when the audit stream is unavailable.
+	auditMemorySize = 1000
+	// auditMaxBodyHash caps how much of a request body is hashed.
+	auditMaxBodyHash = 4 << 20
+	// auditMaxScan caps the stream messages one GET /audit reads: the most
+	// recent ones, so a busy log still shows its newest records.
+	auditMaxScan = 10000
+	// auditDefaultWindow is how far back GET /audit looks without ?since=.
+	auditDefaultWindow = 24 * time.Hour
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditRecord is one mutating action taken through the API.
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Actor        string    `json:"actor"` // JWT subject, key:<name>, or dashboard
+	ActorName    string    `json:"actorName"`
+	AuthMethod   string    `json:"authMethod"`
+	SourceIP     string    `json:"sourceIp"`
+	ForwardedFor string    `json:"forwardedFor,omitempty"`
+	Action       string    `json:"action"` // e.g. mission.delete
+	Target       string    `json:"target"` // e.g. missions/m1
+	BodySHA256   string    `json:"bodySha256,omitempty"`
+	Status       int       `json:"status,omitempty"` // HTTP status; 0 for WebSocket commands
+	Outcome      string    `json:"outcome"`          // success, denied or failed
+}
+
+// auditActions names the mutating routes, keyed by "METHOD template".
+// Unlisted routes get a name derived from the template.
+var auditActions = map[string]string{
+	"POST /api/tasks/dispatch":                             "task.dispatch",
+	"POST /api/missions":                                   "mission.create",
+	"DELETE /api/missions/{name}":                          "mission.delete",
+	"POST /api/missions/{name}/plan/{decision}":            "mission.plan.decide",
+	"POST /api/missions/{name}/report":                     "mission.report.save",
+	"PUT /api/kv/{bucket}/{key}":                           "kv.put",
+	"DELETE /api/kv/{bucket}/{key}":                        "kv.delete",
+	"POST /api/roundtables":                                "roundtable.create",
+	"PATCH /api/roundtables/{name}":                        "roundtable.update",
+	"POST /api/roundtables/{name}/{action:suspend|resume}": "roundtable.suspend-resume",
+	"PATCH /api/roundtables/{name}/warmpool":               "roundtable.warmpool.resize",
+	"POST /api/briefings/rollup":                           "briefing.rollup",
+	"POST /api/briefings/{date}/notes":                     "briefing.annotate",
+	"POST /api/vault/notes":                                "vault.note.write",
+	"POST /api/ws/ticket":                                  "ws.ticket.issue",
+}
+
+// auditAction names a mutation: the listed name, or the literal template
+// segments plus the method, e.g. "foo.bar.post".
+func auditAction(method, template string) string {
+	if action, ok := auditActions[method+" "+template]; ok {
+		return action
+	}
+	parts := []string{}
+	for _, seg := range strings.Split(strings.TrimPrefix(template, "/api/"), "/") {
+		if seg != "" && !strings.HasPrefix(seg, "{") {
+			parts = append(parts, seg)
+		}
+	}
+	return strings.Join(append(parts, strings.ToLower(method)), ".")
+}
+
+// auditOutcome classifies an HTTP status.
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "denied"
+	case status >= 400:
+		return "failed"
+	}
+	return "success"
+}
+
+// auditLog records to the audit JetStream stream when it is available, and
+// always to an in-memory ring.
+type auditLog struct {
+	js      jetstream.JetStream
+	stream  jetstream.Stream // nil: memory only
+	subject string           // records go to <subject>.<action>
+
+	mu      sync.Mutex
+	records []AuditRecord // ring, oldest first once full
+	next    int
+}
+
+// audit is the process-wide audit log; memory-only until main connects it
+// to JetStream.
+var audit = newAuditLog(nil, nil, "")
+
+func newAuditLog(js jetstream.JetStream, stream jetstream.Stream, subject string) *auditLog {
+	return &auditLog{js: js, stream: stream, subject: subject}
+}
+
+// openAuditStream creates or updates the audit stream. Its subjects must
+// not overlap any other stream's, hence a prefix outside the fleet's.
+func openAuditStream(ctx context.Context, js jetstream.JetStream, name, subject string, maxAge time.Duration) (*auditLog, error) {
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        name,
+		Description: "roundtable-ui audit log",
+		Subjects:    []string{subject + ".>"},
+		Storage:     jetstream.FileStorage,
+		MaxAge:      maxAge,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newAuditLog(js, stream, subject), nil
+}
+
+// record stores a record; publishing happens in the background so a slow
+// JetStream never delays the response.
+func (a *auditLog) record(rec AuditRecord) {
+	a.mu.Lock()
+	if len(a.records) < auditMemorySize {
+		a.records = append(a.records, rec)
+	} else {
+		a.records[a.next] = rec
+		a.next = (a.next + 1) % auditMemorySize
+	}
+	a.mu.Unlock()
+
+	slog.Info("Audit", "actor", rec.Actor, "action", rec.Action, "target", rec.Target, "outcome", rec.Outcome)
+	if a.stream == nil {
+		return
+	}
+	data, _ := json.Marshal(rec)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := a.js.Publish(ctx, a.subject+"."+rec.Action, data); err != nil {
+			slog.Error("Audit publish failed", "action", rec.Action, "error", err)
+		}
+	}()
+}
+
+// auditFilter selects records for GET /audit.
+type auditFilter struct {
+	Actor  string // exact JWT subject / key:<name>, or actor name
+	Action string // glob, e.g. mission.*
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (f auditFilter) matches(rec AuditRecord) bool {
+	if f.Actor != "" && rec.Actor != f.Actor && rec.ActorName != f.Actor {
+		return false
+	}
+	if f.Action != "" {
+		if ok, _ := path.Match(f.Action, rec.Action); !ok {
+			return false
+		}
+	}
+	return !rec.Time.Before(f.Since) && (f.Until.IsZero() || !rec.Time.After(f.Until))
+}
+
+// query returns matching records, newest first, where they came from, and
+// whether older matches may have been left out because the memory ring or
+// the stream scan didn't reach back to f.Since.
+func (a *auditLog) query(ctx context.Context, f auditFilter) ([]AuditRecord, string, bool) {
+	if a.stream != nil {
+		records, truncated, err := a.queryStream(ctx, f)
+		if err == nil {
+			return records, "jetstream", truncated
+		}
+		slog.Warn("Audit stream query failed, using memory", "error", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	records := []AuditRecord{}
+	for i := len(a.records) - 1; i >= 0 && len(records) < f.Limit; i-- {
+		rec := a.records[(a.next+i)%len(a.records)]
+		if f.matches(rec) {
+			records = append(records, rec)
+		}
+	}
+	truncated := len(records) < f.Limit && len(a.records) == auditMemorySize && a.records[a.next].Time.After(f.Since)
+	return records, "memory", truncated
+}
+
+// queryStream scans the last auditMaxScan messages of the stream, or from
+// f.Since if that is later, keeping the newest f.Limit matches.
+func (a *auditLog) queryStream(ctx context.Context, f auditFilter) ([]AuditRecord, bool, error) {
+	info, err := a.stream.Info(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	filter := a.subject + ".>"
+	if f.Action != "" && !strings.ContainsAny(f.Action, "*?[") {
+		filter = a.subject + "." + f.Action
+	}
+	cfg := jetstream.OrderedConsumerConfig{FilterSubjects: []string{filter}}
+	startSeq := info.State.FirstSeq
+	if info.State.LastSeq >= auditMaxScan && info.State.LastSeq-auditMaxScan+1 > startSeq {
+		startSeq = info.State.LastSeq - auditMaxScan + 1
+		cfg.DeliverPolicy, cfg.OptStartSeq = jetstream.DeliverByStartSequencePolicy, startSeq
+	} else {
+		since := f.Since
+		cfg.DeliverPolicy, cfg.OptStartTime = jetstream.DeliverByStartTimePolicy, &since
+	}
+	cons, err := a.stream.OrderedConsumer(ctx, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	records, reachedStart, err := scanAudit(func(n int) ([]jetstream.Msg, error) {
+		batch, err := cons.FetchNoWait(n)
+		if err != nil {
+			return nil, err
+		}
+		var msgs []jetstream.Msg
+		for msg := range batch.Messages() {
+			msgs = append(msgs, msg)
+		}
+		return msgs, batch.Error()
+	}, f)
+	if err != nil {
+		return nil, false, err
+	}
+	// Starting past the first sequence skipped older records; they matter
+	// only if the window didn't already reach back to f.Since
+	truncated := startSeq > info.State.FirstSeq && !reachedStart && len(records) < f.Limit
+	return records, truncated, nil
+}
+
+// scanAudit reads records with fetch until nothing is pending, keeping the
+// newest f.Limit matches, newest first. reachedSince reports that the scan
+// began at or before f.Since, so nothing older could have matched.
+func scanAudit(fetch func(n int) ([]jetstream.Msg, error), f auditFilter) (records []AuditRecord, reachedSince bool, err error) {
+	var matched []AuditRecord
+	first := true
+	for scanned := 0; scanned < auditMaxScan; {
+		msgs, err := fetch(500)
+		if err != nil {
+			return nil, false, err
+		}
+		done := false
+		for _, msg := range msgs {
+			var rec AuditRecord
+			if json.Unmarshal(msg.Data(), &rec) != nil {
+				continue
+			}
+			if first {
+				reachedSince, first = !rec.Time.After(f.Since), false
+			}
+			if f.matches(rec) {
+				matched = append(matched, rec)
+			}
+			if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+				done = true
+			}
+		}
+		scanned += len(msgs)
+		if len(msgs) == 0 || done {
+			break
+		}
+	}
+	if first {
+		reachedSince = true // nothing newer than where the scan began
+	}
+
+	records = make([]AuditRecord, 0, f.Limit)
+	for i := len(matched) - 1; i >= 0 && len(records) < f.Limit; i-- {
+		records = append(records, matched[i])
+	}
+	return records, reachedSince, nil
+}
+
+// newAuditRecord fills in the actor and source of a request.
+func newAuditRecord(r *http.Request, action, target string) AuditRecord {
+	id := requestIdentity(r)
+	return AuditRecord{
+		Time:         time.Now().UTC(),
+		Actor:        id.Subject,
+		ActorName:    id.Name,
+		AuthMethod:   id.Method,
+		SourceIP:     clientIP(r),
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Action:       action,
+		Target:       target,
+	}
+}
+
+type auditTargetKey struct{}
+
+// setAuditTarget lets a handler name what it acted on more precisely than
+// the request path, e.g. the knight a task was dispatched to.
+func setAuditTarget(r *http.Request, target string) {
+	if p, ok := r.Context().Value(auditTargetKey{}).(*string); ok {
+		*p = target
+	}
+}
+
+// auditMiddleware records every mutating /api request: who made it, from
+// where, a hash of the body and how it ended. It runs after authMiddleware
+// (for the actor) and the rate limiter (so a flood of refused requests
+// isn't a flood of records), and before rbacMiddleware (so denials are
+// recorded).
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := mux.CurrentRoute(r)
+		if route == nil || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Hash the body as the handler reads it rather than buffering it
+		// up front; a request refused before its body is read has no hash
+		var hashed *bodyHasher
+		if r.Body != nil {
+			hashed = &bodyHasher{hash: sha256.New()}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, hashed), r.Body}
+		}
+
+		target := strings.TrimPrefix(r.URL.Path, "/api/")
+		r = r.WithContext(context.WithValue(r.Context(), auditTargetKey{}, &target))
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r)
+
+		rec := newAuditRecord(r, auditAction(r.Method, template), target)
+		rec.BodySHA256, rec.Status, rec.Outcome = hashed.sum(), sr.status, auditOutcome(sr.status)
+		audit.record(rec)
+	})
+}
+
+// bodyHasher hashes the first auditMaxBodyHash bytes written to it and
+// discards the rest.
+type bodyHasher struct {
+	hash hash.Hash
+	n    int64
+}
+
+func (b *bodyHasher) Write(p []byte) (int, error) {
+	if rest := auditMaxBodyHash - b.n; rest > 0 {
+		if int64(len(p)) > rest {
+			b.hash.Write(p[:rest])
+		} else {
+			b.hash.Write(p)
+		}
+	}
+	b.n += int64(len(p))
+	return len(p), nil
+}
+
+// sum is the hex digest, or "" when nothing was read.
+func (b *bodyHasher) sum() string {
+	if b == nil || b.n == 0 {
+		return ""
+	}
+	return hex.EncodeToString(b.hash.Sum(nil))
+}
+
+// auditHandler serves GET /audit?actor=&action=&since=&until=&limit=,
+// newest first. since and until are RFC 3339; since defaults to 24h ago.
+// action is a glob such as mission.*.
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := auditFilter{Actor: q.Get("actor"), Action: q.Get("action"), Limit: defaultAuditLimit}
+	if _, err := path.Match(f.Action, ""); err != nil {
+		http.Error(w, "Invalid action pattern", http.StatusBadRequest)
+		return
+	}
+	for _, p := range []struct {
+		name string
+		into *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s (want RFC 3339)", p.name), http.StatusBadRequest)
+				return
+			}
+			*p.into = t
+		}
+	}
+	if f.Since.IsZero() {
+		f.Since = time.Now().Add(-auditDefaultWindow)
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (1-%d)", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	records, source, truncated := audit.query(r.Context(), f)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"records":   records,
+		"source":    source,
+		"truncated": truncated,
+	})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestAuditAction(t *testing.T) {
+	tests := []struct{ method, template, action string }{
+		{"DELETE", "/api/missions/{name}", "mission.delete"},
+		{"POST", "/api/tasks/dispatch", "task.dispatch"},
+		{"POST", "/api/roundtables/{name}/{action:suspend|resume}", "roundtable.suspend-resume"},
+		{"POST", "/api/widgets/{id}/spin", "widgets.spin.post"},
+	}
+	for _, tt := range tests {
+		if got := auditAction(tt.method, tt.template); got != tt.action {
+			t.Errorf("auditAction(%s %s) = %s, want %s", tt.method, tt.template, got, tt.action)
+		}
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	audit = newAuditLog(nil, nil, "")
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[
+		{"name":"ci","hash":%q,"role":"operator","scopes":["*"]},
+		{"name":"ops","hash":%q,"role":"admin","scopes":["*"]}
+	]}`, testKeyHash("ci-secret"), testKeyHash("ops-secret")))
+	var err error
+	if apiKeys, err = newAPIKeyStore(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { apiKeys = nil }()
+
+	var handlerBody string
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.Use(auditMiddleware)
+	r.Use(rbacMiddleware)
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/tasks/dispatch", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handlerBody = string(body)
+		setAuditTarget(r, "knights/galahad/tasks/galahad-ui-1")
+	}).Methods("POST")
+	api.HandleFunc("/missions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Mission not found", http.StatusNotFound)
+	}).Methods("DELETE")
+	api.HandleFunc("/fleet", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	api.HandleFunc("/audit", auditHandler).Methods("GET")
+
+	do := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	dispatch := `{"knight":"galahad","domain":"security","task":"scan"}`
+	do("ci-secret", "POST", "/api/tasks/dispatch", dispatch)
+	if handlerBody != dispatch {
+		t.Errorf("handler saw body %q", handlerBody)
+	}
+	do("ci-secret", "DELETE", "/api/missions/m1", "")
+	do("ops-secret", "DELETE", "/api/missions/m1", "")
+	do("ci-secret", "GET", "/api/fleet", "")
+
+	var resp struct {
+		Records []AuditRecord `json:"records"`
+		Source  string        `json:"source"`
+	}
+	w := do("ops-secret", "GET", "/api/audit", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected audit listing, got %d %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Source != "memory" || len(resp.Records) != 3 {
+		t.Fatalf("expected 3 records from memory (reads aren't audited), got %s %+v", resp.Source, resp.Records)
+	}
+
+	sum := sha256.Sum256([]byte(dispatch))
+	first := resp.Records[2]
+	if first.Actor != "key:ci" || first.ActorName != "ci" || first.Action != "task.dispatch" ||
+		first.Target != "knights/galahad/tasks/galahad-ui-1" || first.BodySHA256 != hex.EncodeToString(sum[:]) ||
+		first.Outcome != "success" || first.SourceIP != "192.0.2.1" || first.ForwardedFor != "203.0.113.7" {
+		t.Errorf("unexpected dispatch record %+v", first)
+	}
+	if denied := resp.Records[1]; denied.Action != "mission.delete" || denied.Target != "missions/m1" || denied.Status != http.StatusForbidden || denied.Outcome != "denied" {
+		t.Errorf("unexpected denied record %+v", denied)
+	}
+	if failed := resp.Records[0]; failed.Actor != "key:ops" || failed.Status != http.StatusNotFound || failed.Outcome != "failed" {
+		t.Errorf("unexpected failed record %+v", failed)
+	}
+
+	filterTests := []struct {
+		query string
+		count int
+	}{
+		{"actor=ci", 2},
+		{"actor=key:ops", 1},
+		{"action=mission.*", 2},
+		{"action=task.dispatch&actor=ops", 0},
+		{"limit=1", 1},
+		{"since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), 0},
+		{"until=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), 0},
+	}
+	for _, tt := range filterTests {
+		w := do("ops-secret", "GET", "/api/audit?"+tt.query, "")
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusOK || len(resp.Records) != tt.count {
+			t.Errorf("?%s: expected %d records, got %d %s", tt.query, tt.count, w.Code, w.Body.String())
+		}
+	}
+	for _, bad := range []string{"since=yesterday", "limit=0", "limit=5000", "action=["} {
+		if w := do("ops-secret", "GET", "/api/audit?"+bad, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("?%s: expected 400, got %d", bad, w.Code)
+		}
+	}
+	if w := do("ci-secret", "GET", "/api/audit", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected operators denied the audit log, got %d", w.Code)
+	}
+}
+
+// countingJetStream counts audit publishes; nothing else is called.
+type countingJetStream struct {
+	jetstream.JetStream
+	published atomic.Int32
+}
+
+func (c *countingJetStream) Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	c.published.Add(1)
+	return &jetstream.PubAck{}, nil
+}
+
+// TestAuditAfterRateLimit checks requests refused by the rate limiter are
+// neither read nor published to the audit stream, and that bodies are
+// hashed as the handler streams them.
+func TestAuditAfterRateLimit(t *testing.T) {
+	js := &countingJetStream{}
+	audit = newAuditLog(js, struct{ jetstream.Stream }{}, "audit")
+	defer func() { audit = newAuditLog(nil, nil, "") }()
+
+	budgets, _ := parseRateLimits("dispatch=1/m")
+	rl := newRateLimiter(budgets)
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.Use(rl.middleware)
+	r.Use(auditMiddleware)
+	r.Use(rbacMiddleware)
+	r.HandleFunc("/api/tasks/dispatch", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}).Methods("POST")
+
+	body := strings.Repeat("x", auditMaxBodyHash+10)
+	var bodies []*strings.Reader
+	for i := 0; i < 5; i++ {
+		bodies = append(bodies, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/tasks/dispatch", bodies[i]))
+		if expected := map[bool]int{true: http.StatusOK, false: http.StatusTooManyRequests}[i == 0]; w.Code != expected {
+			t.Errorf("request %d: expected %d, got %d", i, expected, w.Code)
+		}
+	}
+	for i, b := range bodies[1:] {
+		if b.Len() != len(body) {
+			t.Errorf("rate limited request %d: expected its body unread, %d bytes left", i+1, b.Len())
+		}
+	}
+
+	audit.mu.Lock()
+	records := append([]AuditRecord(nil), audit.records...)
+	audit.mu.Unlock()
+	sum := sha256.Sum256([]byte(body[:auditMaxBodyHash]))
+	if len(records) != 1 || records[0].BodySHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected one record hashing the first %d bytes, got %+v", auditMaxBodyHash, records)
+	}
+	deadline := time.Now().Add(time.Second)
+	for js.published.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := js.published.Load(); n != 1 {
+		t.Errorf("expected one audit publish, got %d", n)
+	}
+}
+
+func TestAuditLogMemoryRing(t *testing.T) {
+	log := newAuditLog(nil, nil, "")
+	start := time.Now()
+	for i := 0; i < auditMemorySize+5; i++ {
+		log.record(AuditRecord{Time: start.Add(time.Duration(i) * time.Second), Action: "task.dispatch", Target: fmt.Sprint(i)})
+	}
+	records, _, truncated := log.query(context.Background(), auditFilter{Since: start, Limit: maxAuditLimit})
+	if len(records) != auditMemorySize || records[0].Target != fmt.Sprint(auditMemorySize+4) || records[len(records)-1].Target != "5" {
+		t.Errorf("expected the newest %d records newest first, got %d (%s..%s)", auditMemorySize, len(records), records[0].Target, records[len(records)-1].Target)
+	}
+	if truncated {
+		t.Error("expected a full page not reported as truncated")
+	}
+	if _, _, truncated := log.query(context.Background(), auditFilter{Since: start, Action: "task.dispatch", Actor: "nobody", Limit: 10}); !truncated {
+		t.Error("expected records evicted from the ring reported")
+	}
+}
+
+// TestScanAudit checks the stream scan keeps the newest matches and reports
+// whether it began early enough to cover ?since=.
+func TestScanAudit(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	var msgs []jetstream.Msg
+	for i := 0; i < 1200; i++ {
+		data, _ := json.Marshal(AuditRecord{Time: start.Add(time.Duration(i) * time.Minute), Action: "task.dispatch", Target: fmt.Sprint(i)})
+		msgs = append(msgs, historyMsg{data: data, pending: uint64(1199 - i)})
+	}
+	fetcher := func() func(int) ([]jetstream.Msg, error) {
+		rest := msgs
+		return func(n int) ([]jetstream.Msg, error) {
+			batch := rest[:min(n, len(rest))]
+			rest = rest[len(batch):]
+			return batch, nil
+		}
+	}
+
+	records, reached, err := scanAudit(fetcher(), auditFilter{Since: start.Add(-time.Hour), Limit: 3})
+	if err != nil || len(records) != 3 || records[0].Target != "1199" || records[2].Target != "1197" {
+		t.Fatalf("expected the newest 3 records newest first, got %+v %v", records, err)
+	}
+	if reached {
+		t.Error("expected a scan starting after ?since= reported")
+	}
+	if _, reached, _ := scanAudit(fetcher(), auditFilter{Since: start, Limit: 3}); !reached {
+		t.Error("expected a scan starting at ?since= to cover it")
+	}
+}
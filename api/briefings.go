@@ -0,0 +1,700 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultBriefingSearchLimit = 20
+	maxBriefingSearchLimit     = 100
+	// briefingSnippetRadius is how many bytes of context surround the first
+	// match in a search snippet.
+	briefingSnippetRadius = 80
+)
+
+// BriefingSearchResult is one ranked match for GET /briefings/search.
+// Snippet is HTML-escaped with matched terms wrapped in <mark>.
+type BriefingSearchResult struct {
+	Date    string  `json:"date"`
+	File    string  `json:"file"`
+	Score   float64 `json:"score"`
+	Matches int     `json:"matches"`
+	Snippet string  `json:"snippet"`
+}
+
+// BriefingSearchResponse is the response for GET /briefings/search.
+type BriefingSearchResponse struct {
+	Query   string                 `json:"query"`
+	Total   int                    `json:"total"`
+	Results []BriefingSearchResult `json:"results"`
+}
+
+// indexedBriefing is one file in the briefing index.
+type indexedBriefing struct {
+	file    string
+	date    string
+	modTime time.Time
+	size    int64
+	content string
+	terms   map[string]int
+}
+
+// briefingIndex is an in-process inverted index over the daily briefings.
+// Each search first stats the directory and re-reads only files whose mtime
+// or size changed, so edits made in the vault show up on the next query
+// without a background goroutine.
+type briefingIndex struct {
+	dir string
+
+	// refreshMu serializes refreshes, which do their I/O without mu; docs
+	// and postings change only with both held.
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+	built     bool // the directory has been indexed at least once
+	docs      map[string]*indexedBriefing
+	postings  map[string]map[string]int // term -> file -> occurrences
+}
+
+func newBriefingIndex(dir string) *briefingIndex {
+	return &briefingIndex{
+		dir:      dir,
+		docs:     map[string]*indexedBriefing{},
+		postings: map[string]map[string]int{},
+	}
+}
+
+// tokenize lowercases text and splits it into letter/digit runs.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// refresh brings the index in line with the directory. The directory is
+// listed and changed files read before mu is taken, so searches only wait
+// for the index update itself; a search arriving while another refresh
+// runs uses the index as it stands rather than listing the directory again,
+// unless the index has never been built, when it waits.
+func (idx *briefingIndex) refresh() error {
+	if !idx.refreshMu.TryLock() {
+		idx.mu.Lock()
+		built := idx.built
+		idx.mu.Unlock()
+		if built {
+			return nil
+		}
+		idx.refreshMu.Lock()
+	}
+	defer idx.refreshMu.Unlock()
+
+	entries, err := os.ReadDir(idx.dir)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	var changed []*indexedBriefing
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		seen[e.Name()] = true
+		// Reading docs without mu is safe: only refresh changes it
+		if doc, ok := idx.docs[e.Name()]; ok && doc.modTime.Equal(info.ModTime()) && doc.size == info.Size() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(idx.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		changed = append(changed, &indexedBriefing{
+			file:    e.Name(),
+			date:    strings.TrimSuffix(e.Name(), ".md"),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			content: string(content),
+		})
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, doc := range changed {
+		idx.remove(doc.file)
+		idx.add(doc)
+	}
+	for file := range idx.docs {
+		if !seen[file] {
+			idx.remove(file)
+		}
+	}
+	idx.built = true
+	return nil
+}
+
+func (idx *briefingIndex) add(doc *indexedBriefing) {
+	doc.terms = map[string]int{}
+	for _, term := range tokenize(doc.content) {
+		doc.terms[term]++
+	}
+	for term, n := range doc.terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[string]int{}
+		}
+		idx.postings[term][doc.file] = n
+	}
+	idx.docs[doc.file] = doc
+}
+
+func (idx *briefingIndex) remove(file string) {
+	doc, ok := idx.docs[file]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(idx.postings[term], file)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, file)
+}
+
+// search returns briefings containing every query term, scored by TF-IDF
+// (newest first on ties), or newest first when byDate is set.
+func (idx *briefingIndex) search(query string, byDate bool) ([]BriefingSearchResult, error) {
+	terms := dedupe(tokenize(query))
+	if len(terms) == 0 {
+		return []BriefingSearchResult{}, nil
+	}
+
+	if err := idx.refresh(); err != nil {
+		return nil, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Start from the rarest term's postings and intersect
+	sort.Slice(terms, func(i, j int) bool { return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]]) })
+	results := []BriefingSearchResult{}
+	for file := range idx.postings[terms[0]] {
+		score, matches := 0.0, 0
+		for _, term := range terms {
+			n := idx.postings[term][file]
+			if n == 0 {
+				score = -1
+				break
+			}
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(idx.postings[term])))
+			score += (1 + math.Log(float64(n))) * idf
+			matches += n
+		}
+		if score < 0 {
+			continue
+		}
+		doc := idx.docs[file]
+		results = append(results, BriefingSearchResult{
+			Date:    doc.date,
+			File:    doc.file,
+			Score:   math.Round(score*1000) / 1000,
+			Matches: matches,
+			Snippet: briefingSnippet(doc.content, terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if !byDate && results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Date > results[j].Date
+	})
+	return results, nil
+}
+
+func dedupe(values []string) []string {
+	seen := map[string]bool{}
+	out := values[:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// briefingSnippet cuts a window around the first matching word, escapes it
+// and wraps every matching word in <mark>.
+func briefingSnippet(content string, terms []string) string {
+	want := map[string]bool{}
+	for _, term := range terms {
+		want[term] = true
+	}
+
+	type span struct{ start, end int }
+	var words []span
+	start := -1
+	for i, r := range content + " " {
+		wordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if wordRune && start < 0 {
+			start = i
+		} else if !wordRune && start >= 0 {
+			if want[strings.ToLower(content[start:i])] {
+				words = append(words, span{start, i})
+			}
+			start = -1
+		}
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	from := max(0, words[0].start-briefingSnippetRadius)
+	to := min(len(content), words[0].end+briefingSnippetRadius)
+	// Don't cut UTF-8 sequences in half
+	for from > 0 && !utf8.RuneStart(content[from]) {
+		from--
+	}
+	for to < len(content) && !utf8.RuneStart(content[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, w := range words {
+		if w.start < from {
+			continue
+		}
+		if w.end > to {
+			break
+		}
+		b.WriteString(html.EscapeString(content[pos:w.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(content[w.start:w.end]))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(content[pos:to]))
+	if to < len(content) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// briefingSearchHandler serves GET /briefings/search?q=&limit=&sort=date.
+func briefingSearchHandler(index *briefingIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			http.Error(w, "Missing q parameter", http.StatusBadRequest)
+			return
+		}
+		limit := defaultBriefingSearchLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxBriefingSearchLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy != "" && sortBy != "relevance" && sortBy != "date" {
+			http.Error(w, "Invalid sort (allowed: relevance, date)", http.StatusBadRequest)
+			return
+		}
+
+		results, err := index.search(q, sortBy == "date")
+		if err != nil {
+			http.Error(w, "Briefings directory not found", http.StatusNotFound)
+			return
+		}
+
+		resp := BriefingSearchResponse{Query: q, Total: len(results), Results: results}
+		if len(results) > limit {
+			resp.Results = results[:limit]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// validBriefingDate guards briefing paths: only YYYY-MM-DD names are served,
+// which rules out path traversal.
+var validBriefingDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+var (
+	wikiLinkPattern  = regexp.MustCompile(`\[\[([^\]|#]+)(?:#([^\]|]*))?(?:\|([^\]]*))?\]\]`)
+	inlineTagPattern = regexp.MustCompile(`(?:^|\s)#([A-Za-z][\w/-]*)`)
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+)
+
+// BriefingSummary is one entry of GET /briefings.
+type BriefingSummary struct {
+	Date     string    `json:"date"`
+	File     string    `json:"file"`
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags"`
+	Modified time.Time `json:"modified"`
+}
+
+// BriefingSection is a level 1 or 2 heading and the text beneath it.
+type BriefingSection struct {
+	Level   int    `json:"level"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// WikiLink is an Obsidian [[Target#Heading|Alias]] link. Path is the
+// vault-relative file it resolves to, empty when nothing matches.
+type WikiLink struct {
+	Target  string `json:"target"`
+	Heading string `json:"heading,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// BriefingDocument is the response for GET /briefings/{date}?format=json.
+// Knights and Missions come from the front-matter keys of the same name and
+// from links into the vault's Knights/ and Missions/ folders.
+type BriefingDocument struct {
+	BriefingSummary
+	FrontMatter map[string]interface{} `json:"frontMatter,omitempty"`
+	Sections    []BriefingSection      `json:"sections"`
+	Knights     []string               `json:"knights"`
+	Missions    []string               `json:"missions"`
+	Links       []WikiLink             `json:"links"`
+}
+
+// splitFrontMatter separates a leading YAML front-matter block from the
+// body. Malformed front-matter is left in the body rather than failing the
+// whole briefing.
+func splitFrontMatter(content string) (map[string]interface{}, string) {
+	content = strings.TrimPrefix(content, "\uFEFF")
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return nil, content
+	}
+	rest := content[strings.Index(content, "\n")+1:]
+	for offset := 0; offset < len(rest); {
+		end := strings.Index(rest[offset:], "\n")
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if trimmed := strings.TrimRight(line, "\r"); trimmed == "---" || trimmed == "..." {
+			var fm map[string]interface{}
+			if err := yaml.Unmarshal([]byte(rest[:offset]), &fm); err != nil {
+				return nil, content
+			}
+			if end < 0 {
+				return fm, ""
+			}
+			return fm, rest[offset+end+1:]
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	return nil, content
+}
+
+// frontMatterList reads a front-matter value that may be a list or a comma
+// or space separated string.
+func frontMatterList(fm map[string]interface{}, key string) []string {
+	var out []string
+	switch v := fm[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, strings.TrimPrefix(s, "#"))
+			}
+		}
+	case string:
+		for _, s := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			out = append(out, strings.TrimPrefix(s, "#"))
+		}
+	}
+	return out
+}
+
+// sortedSet dedupes and sorts values, returning an empty (not nil) slice.
+func sortedSet(values []string) []string {
+	out := dedupe(values)
+	sort.Strings(out)
+	if out == nil {
+		out = []string{}
+	}
+	return out
+}
+
+func lowerAll(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return values
+}
+
+// parseBriefing extracts a briefing's front-matter, title, tags, sections
+// and wikilinks. Headings and tags inside code fences are ignored.
+func parseBriefing(file string, content []byte, modTime time.Time, links *vaultLinkResolver) BriefingDocument {
+	fm, body := splitFrontMatter(string(content))
+	doc := BriefingDocument{
+		BriefingSummary: BriefingSummary{
+			Date:     strings.TrimSuffix(file, ".md"),
+			File:     file,
+			Modified: modTime,
+		},
+		FrontMatter: fm,
+		Sections:    []BriefingSection{},
+		Links:       []WikiLink{},
+	}
+	if title, ok := fm["title"].(string); ok {
+		doc.Title = title
+	}
+	tags := frontMatterList(fm, "tags")
+	knights := frontMatterList(fm, "knights")
+	missions := frontMatterList(fm, "missions")
+
+	var section *BriefingSection
+	var sectionBody []string
+	flush := func() {
+		if section != nil {
+			section.Content = strings.TrimSpace(strings.Join(sectionBody, "\n"))
+			doc.Sections = append(doc.Sections, *section)
+		}
+		section, sectionBody = nil, nil
+	}
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if m := headingPattern.FindStringSubmatch(line); m != nil && len(m[1]) <= 2 {
+				if doc.Title == "" && len(m[1]) == 1 {
+					doc.Title = m[2]
+				}
+				flush()
+				section = &BriefingSection{Level: len(m[1]), Title: m[2]}
+				continue
+			}
+			for _, m := range inlineTagPattern.FindAllStringSubmatch(line, -1) {
+				tags = append(tags, m[1])
+			}
+		}
+		sectionBody = append(sectionBody, line)
+	}
+	flush()
+
+	seen := map[string]bool{}
+	for _, m := range wikiLinkPattern.FindAllStringSubmatch(body, -1) {
+		link := WikiLink{
+			Target:  strings.TrimSpace(m[1]),
+			Heading: strings.TrimSpace(m[2]),
+			Alias:   strings.TrimSpace(m[3]),
+		}
+		if seen[link.Target+"#"+link.Heading] {
+			continue
+		}
+		seen[link.Target+"#"+link.Heading] = true
+		if links != nil {
+			link.Path = links.resolve(link.Target)
+		}
+		switch folder := strings.ToLower(strings.SplitN(link.Path, "/", 2)[0]); folder {
+		case "knights":
+			knights = append(knights, strings.TrimSuffix(filepath.Base(link.Path), ".md"))
+		case "missions":
+			missions = append(missions, strings.TrimSuffix(filepath.Base(link.Path), ".md"))
+		}
+		doc.Links = append(doc.Links, link)
+	}
+
+	if doc.Title == "" {
+		doc.Title = doc.Date
+	}
+	doc.Tags = sortedSet(tags)
+	// Knight and mission names are Kubernetes resource names
+	doc.Knights = sortedSet(lowerAll(knights))
+	doc.Missions = sortedSet(lowerAll(missions))
+	return doc
+}
+
+// vaultLinkResolver maps wikilink targets to vault-relative paths. The vault
+// is walked at most once per ttl; dot-directories (.obsidian, .git) are
+// skipped.
+type vaultLinkResolver struct {
+	mu     sync.Mutex
+	root   string
+	ttl    time.Duration
+	built  time.Time
+	byName map[string]string // lowercased name without .md -> path
+	byPath map[string]string // lowercased path without .md -> path
+}
+
+func newVaultLinkResolver(root string, ttl time.Duration) *vaultLinkResolver {
+	return &vaultLinkResolver{root: root, ttl: ttl}
+}
+
+func (v *vaultLinkResolver) rebuild() {
+	v.byName = map[string]string{}
+	v.byPath = map[string]string{}
+	filepath.WalkDir(v.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != v.root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(v.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		key := strings.ToLower(strings.TrimSuffix(rel, ".md"))
+		v.byPath[key] = rel
+		// Obsidian resolves bare names to the shortest matching path
+		name := strings.ToLower(strings.TrimSuffix(d.Name(), ".md"))
+		if existing, ok := v.byName[name]; !ok || len(rel) < len(existing) {
+			v.byName[name] = rel
+		}
+		return nil
+	})
+	v.built = time.Now()
+}
+
+// resolve returns the vault path a link target points at, or "".
+func (v *vaultLinkResolver) resolve(target string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.byName == nil || time.Since(v.built) > v.ttl {
+		v.rebuild()
+	}
+	key := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(target, "/"), ".md"))
+	if strings.Contains(key, "/") {
+		return v.byPath[key]
+	}
+	return v.byName[key]
+}
+
+// listBriefings parses every Markdown file in dir, newest (highest name)
+// first.
+func listBriefings(dir string) ([]BriefingSummary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	briefings := []BriefingSummary{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		briefings = append(briefings, parseBriefing(e.Name(), content, info.ModTime(), nil).BriefingSummary)
+	}
+	sort.Slice(briefings, func(i, j int) bool { return briefings[i].Date > briefings[j].Date })
+	return briefings, nil
+}
+
+// briefingListHandler serves GET /briefings: every daily briefing with its
+// title and tags, newest first.
+func briefingListHandler(vaultPath string) http.HandlerFunc {
+	dir := filepath.Join(vaultPath, "Briefings", "Daily")
+	return func(w http.ResponseWriter, r *http.Request) {
+		briefings, err := listBriefings(dir)
+		if err != nil {
+			http.Error(w, "Briefings directory not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(briefings)
+	}
+}
+
+// serveBriefing writes the note <allowedDir>/<name>.md as Markdown, or
+// parsed with ?format=json. name must already be validated.
+func serveBriefing(w http.ResponseWriter, r *http.Request, allowedDir, name string, links *vaultLinkResolver) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "md" && format != "json" {
+		http.Error(w, "Invalid format (allowed: md, json)", http.StatusBadRequest)
+		return
+	}
+
+	path := filepath.Clean(fmt.Sprintf("%s/%s.md", allowedDir, name))
+	if !strings.HasPrefix(path, allowedDir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		http.Error(w, "Briefing not found", http.StatusNotFound)
+		return
+	}
+
+	// Send this back as If-Match when annotating (POST /briefings/{date}/notes)
+	w.Header().Set("ETag", contentETag(content))
+	if format == "json" {
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parseBriefing(name+".md", content, modTime, links))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown")
+	w.Write(content)
+}
+
+// briefingHandler serves GET /briefings/{date} as Markdown, or parsed with
+// ?format=json.
+func briefingHandler(vaultPath string) http.HandlerFunc {
+	allowedDir := filepath.Clean(fmt.Sprintf("%s/Briefings/Daily", vaultPath))
+	links := newVaultLinkResolver(vaultPath, time.Minute)
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		date := vars["date"]
+
+		// Sanitize: only allow YYYY-MM-DD format to prevent path traversal
+		if !validBriefingDate.MatchString(date) {
+			http.Error(w, "Invalid date format", http.StatusBadRequest)
+			return
+		}
+		serveBriefing(w, r, allowedDir, date, links)
+	}
+}
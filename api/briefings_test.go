@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func writeTestBriefing(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBriefingSearch(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Briefings", "Daily")
+	writeTestBriefing(t, dir, "2024-01-01.md", "# Briefing\n\nGalahad finished the <scan> of the perimeter.")
+	writeTestBriefing(t, dir, "2024-01-02.md", "# Briefing\n\nGalahad scan failed. Galahad retried the scan.")
+	writeTestBriefing(t, dir, "2024-01-03.md", "# Briefing\n\nQuiet day, nothing to report.")
+	writeTestBriefing(t, dir, "notes.txt", "galahad scan")
+
+	index := newBriefingIndex(dir)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings/search", briefingSearchHandler(index)).Methods("GET")
+
+	// On a cold index a search waits for the first build instead of
+	// answering from an empty index
+	index.refreshMu.Lock()
+	cold := make(chan []BriefingSearchResult)
+	go func() {
+		results, _ := index.search("galahad", false)
+		cold <- results
+	}()
+	select {
+	case <-cold:
+		t.Fatal("expected the search to wait for the first build")
+	case <-time.After(20 * time.Millisecond):
+	}
+	index.refreshMu.Unlock()
+	if results := <-cold; len(results) != 2 {
+		t.Errorf("expected the cold search to see both briefings, got %+v", results)
+	}
+
+	search := func(query string) (int, BriefingSearchResponse) {
+		req := httptest.NewRequest("GET", "/api/briefings/search?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp BriefingSearchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := search("q=Galahad+scan")
+	if code != http.StatusOK || resp.Total != 2 {
+		t.Fatalf("expected 2 matches, got %d: %+v", code, resp)
+	}
+	if resp.Results[0].Date != "2024-01-02" || resp.Results[0].Matches != 4 {
+		t.Errorf("expected the repeated mentions to rank first, got %+v", resp.Results[0])
+	}
+	if snippet := resp.Results[1].Snippet; !strings.Contains(snippet, "<mark>Galahad</mark>") || !strings.Contains(snippet, "&lt;<mark>scan</mark>&gt;") {
+		t.Errorf("expected escaped, highlighted snippet, got %q", snippet)
+	}
+
+	_, resp = search("q=galahad&sort=date&limit=1")
+	if resp.Total != 2 || len(resp.Results) != 1 || resp.Results[0].Date != "2024-01-02" {
+		t.Errorf("expected newest mention first, got %+v", resp)
+	}
+
+	// Edits and new files are picked up on the next query
+	later := time.Now().Add(time.Minute)
+	writeTestBriefing(t, dir, "2024-01-03.md", "# Briefing\n\nGalahad scan rerun.")
+	os.Chtimes(filepath.Join(dir, "2024-01-03.md"), later, later)
+	os.Remove(filepath.Join(dir, "2024-01-01.md"))
+	_, resp = search("q=galahad&sort=date")
+	if resp.Total != 2 || resp.Results[0].Date != "2024-01-03" || resp.Results[1].Date != "2024-01-02" {
+		t.Errorf("expected index refreshed after change, got %+v", resp)
+	}
+
+	// A search during another refresh answers from the index as it stands
+	index.refreshMu.Lock()
+	writeTestBriefing(t, dir, "2024-01-04.md", "# Briefing\n\nGalahad scan again.")
+	_, resp = search("q=galahad")
+	index.refreshMu.Unlock()
+	if resp.Total != 2 {
+		t.Errorf("expected the search not to wait for the refresh, got %+v", resp)
+	}
+	if _, resp = search("q=galahad"); resp.Total != 3 {
+		t.Errorf("expected the new briefing indexed by the next refresh, got %+v", resp)
+	}
+
+	for _, bad := range []string{"", "q=", "q=x&limit=0", "q=x&limit=abc", "q=x&sort=size"} {
+		if code, _ := search(bad); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", bad, code)
+		}
+	}
+
+	if code, resp := search("q=unmentioned"); code != http.StatusOK || resp.Total != 0 || resp.Results == nil {
+		t.Errorf("expected empty result list, got %d %+v", code, resp)
+	}
+}
+
+const testBriefingMarkdown = `---
+title: Morning Report
+tags: [daily, fleet]
+knights:
+  - galahad
+---
+# Ignored Because Front-Matter Has A Title
+
+Intro text #ops
+
+## Incidents
+
+[[galahad]] hit a timeout on [[Missions/recon|the recon mission]].
+
+` + "```" + `
+## not a heading #notatag
+` + "```" + `
+
+## Next Steps
+
+See [[Runbooks/Restart#Steps]] and [[Nowhere]].
+`
+
+func TestParseBriefing(t *testing.T) {
+	vault := t.TempDir()
+	writeTestBriefing(t, filepath.Join(vault, "Knights"), "Galahad.md", "knight")
+	writeTestBriefing(t, filepath.Join(vault, "Missions"), "recon.md", "mission")
+	writeTestBriefing(t, filepath.Join(vault, "Runbooks"), "restart.md", "runbook")
+	writeTestBriefing(t, filepath.Join(vault, ".obsidian"), "nowhere.md", "config")
+
+	doc := parseBriefing("2024-01-02.md", []byte(testBriefingMarkdown), time.Time{}, newVaultLinkResolver(vault, time.Minute))
+
+	if doc.Date != "2024-01-02" || doc.Title != "Morning Report" {
+		t.Errorf("unexpected date/title: %s %q", doc.Date, doc.Title)
+	}
+	if strings.Join(doc.Tags, ",") != "daily,fleet,ops" {
+		t.Errorf("expected front-matter and inline tags, got %v", doc.Tags)
+	}
+	if strings.Join(doc.Knights, ",") != "galahad" {
+		t.Errorf("expected front-matter and linked knight merged, got %v", doc.Knights)
+	}
+	if strings.Join(doc.Missions, ",") != "recon" {
+		t.Errorf("expected recon mission from link, got %v", doc.Missions)
+	}
+
+	var titles []string
+	for _, s := range doc.Sections {
+		titles = append(titles, s.Title)
+	}
+	if strings.Join(titles, "|") != "Ignored Because Front-Matter Has A Title|Incidents|Next Steps" {
+		t.Errorf("unexpected sections %v", titles)
+	}
+	if !strings.Contains(doc.Sections[1].Content, "## not a heading") {
+		t.Errorf("expected fenced heading kept in section content, got %q", doc.Sections[1].Content)
+	}
+
+	paths := map[string]string{}
+	for _, l := range doc.Links {
+		paths[l.Target] = l.Path
+	}
+	want := map[string]string{
+		"galahad":          "Knights/Galahad.md",
+		"Missions/recon":   "Missions/recon.md",
+		"Runbooks/Restart": "Runbooks/restart.md",
+		"Nowhere":          "",
+	}
+	for target, path := range want {
+		if got, ok := paths[target]; !ok || got != path {
+			t.Errorf("link %s resolved to %q, want %q", target, got, path)
+		}
+	}
+
+	// No front-matter: title falls back to the first H1, then the date
+	plain := parseBriefing("2024-01-03.md", []byte("# Quiet Day\n\nNothing."), time.Time{}, nil)
+	if plain.Title != "Quiet Day" || plain.FrontMatter != nil || len(plain.Tags) != 0 {
+		t.Errorf("unexpected plain briefing %+v", plain)
+	}
+	if untitled := parseBriefing("2024-01-04.md", []byte("---\n: [bad\n---\ntext"), time.Time{}, nil); untitled.Title != "2024-01-04" {
+		t.Errorf("expected date title for malformed front-matter, got %q", untitled.Title)
+	}
+}
+
+func TestBriefingListAndJSON(t *testing.T) {
+	vault := t.TempDir()
+	dir := filepath.Join(vault, "Briefings", "Daily")
+	writeTestBriefing(t, dir, "2024-01-01.md", "# First")
+	writeTestBriefing(t, dir, "2024-01-02.md", testBriefingMarkdown)
+	writeTestBriefing(t, dir, "readme.txt", "not a briefing")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings", briefingListHandler(vault)).Methods("GET")
+	router.HandleFunc("/api/briefings/{date}", briefingHandler(vault)).Methods("GET")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/briefings", nil))
+	var list []BriefingSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if len(list) != 2 || list[0].Date != "2024-01-02" || list[0].Title != "Morning Report" || list[1].Title != "First" {
+		t.Errorf("expected newest-first summaries, got %+v", list)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/briefings/2024-01-02?format=json", nil))
+	var doc BriefingDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected JSON briefing, got %d %s", w.Code, w.Body.String())
+	}
+	if doc.FrontMatter["title"] != "Morning Report" || len(doc.Sections) != 3 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/briefings/2024-01-02?format=pdf", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", w.Code)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+
+	// briefingPollInterval is how often the watcher rescans collections. It
+	// is the only change source when fsnotify is unavailable, and otherwise
+	// picks up collection folders created after startup.
+	briefingPollInterval = 30 * time.Second
+)
+
+// Atom 1.0 (RFC 4287) documents, just the elements feed readers use.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+// briefingMarkdown renders briefings to HTML. Raw HTML in the source is
+// dropped (goldmark's default), so feed content can't smuggle in scripts.
+var briefingMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// requestBaseURL is the scheme://host the client used, honouring the
+// ingress's X-Forwarded-Proto.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// buildBriefingFeed turns the newest daily briefings into an Atom feed and
+// returns it with the newest modification time.
+func buildBriefingFeed(dir, baseURL string, limit int) (*atomFeed, time.Time, error) {
+	briefings, err := listBriefings(dir)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(briefings) > limit {
+		briefings = briefings[:limit]
+	}
+
+	feed := &atomFeed{
+		ID:     "urn:roundtable:briefings:daily",
+		Title:  "Round Table Daily Briefings",
+		Author: atomPerson{Name: "Round Table"},
+		Links: []atomLink{
+			{Href: baseURL + "/api/briefings/feed.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/chronicles", Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+	}
+	var newest time.Time
+	for _, b := range briefings {
+		content, err := os.ReadFile(filepath.Join(dir, b.File))
+		if err != nil {
+			continue
+		}
+		_, body := splitFrontMatter(string(content))
+		var rendered bytes.Buffer
+		if err := briefingMarkdown.Convert([]byte(body), &rendered); err != nil {
+			continue
+		}
+
+		published := b.Modified
+		if day, err := time.Parse("2006-01-02", b.Date); err == nil {
+			published = day
+		}
+		entry := atomEntry{
+			ID:        "urn:roundtable:briefing:daily:" + b.Date,
+			Title:     b.Title,
+			Published: published.UTC().Format(time.RFC3339),
+			Updated:   b.Modified.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: baseURL + "/api/briefings/" + b.Date, Rel: "alternate", Type: "text/markdown"},
+			},
+			Content: atomContent{Type: "html", Body: rendered.String()},
+		}
+		for _, tag := range b.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+		if b.Modified.After(newest) {
+			newest = b.Modified
+		}
+	}
+	if newest.IsZero() {
+		newest = time.Unix(0, 0)
+	}
+	feed.Updated = newest.UTC().Format(time.RFC3339)
+	return feed, newest, nil
+}
+
+// briefingFeedHandler serves GET /briefings/feed.atom?limit=. It goes
+// through http.ServeContent so feed readers polling with If-Modified-Since
+// get a 304 until a briefing changes.
+func briefingFeedHandler(vaultPath string) http.HandlerFunc {
+	dir := filepath.Join(vaultPath, "Briefings", "Daily")
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultFeedLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxFeedLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		feed, modTime, err := buildBriefingFeed(dir, requestBaseURL(r), limit)
+		if err != nil {
+			http.Error(w, "Briefings directory not found", http.StatusNotFound)
+			return
+		}
+		data, err := xml.MarshalIndent(feed, "", "  ")
+		if err != nil {
+			slog.Error("Atom feed render error", "error", err)
+			http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		http.ServeContent(w, r, "feed.atom", modTime, bytes.NewReader(append([]byte(xml.Header), data...)))
+	}
+}
+
+// BriefingCreatedEvent is the data of a briefing.created event on /api/ws.
+type BriefingCreatedEvent struct {
+	Collection string `json:"collection"`
+	Entry      string `json:"entry"`
+	Path       string `json:"path"`
+}
+
+// briefingWatcher publishes briefing.created to the event hub when a note
+// appears in a briefing collection. fsnotify events and the periodic rescan
+// both just trigger a directory diff, so atomic renames, editors' temp files
+// and missed events are all handled the same way.
+type briefingWatcher struct {
+	vaultPath   string
+	collections []briefingCollection
+	hub         *eventHub
+	known       map[string]bool // vault-relative paths already seen
+}
+
+// newBriefingWatcher records the notes that already exist so startup
+// doesn't announce them.
+func newBriefingWatcher(vaultPath string, collections []briefingCollection, hub *eventHub) *briefingWatcher {
+	bw := &briefingWatcher{vaultPath: vaultPath, collections: collections, hub: hub, known: map[string]bool{}}
+	for _, c := range collections {
+		for _, entry := range bw.entries(c) {
+			bw.known[c.Dir+"/"+entry+".md"] = true
+		}
+	}
+	return bw
+}
+
+// entries lists the valid note names in a collection.
+func (bw *briefingWatcher) entries(c briefingCollection) []string {
+	files, err := os.ReadDir(filepath.Join(bw.vaultPath, c.Dir))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".md")
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".md") && c.pattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scan diffs one collection against what was seen before, announcing new
+// notes and forgetting removed ones.
+func (bw *briefingWatcher) scan(c briefingCollection) {
+	present := map[string]bool{}
+	for _, entry := range bw.entries(c) {
+		path := c.Dir + "/" + entry + ".md"
+		present[path] = true
+		if bw.known[path] {
+			continue
+		}
+		bw.known[path] = true
+		data, _ := json.Marshal(BriefingCreatedEvent{Collection: c.Name, Entry: entry, Path: path})
+		bw.hub.publish(TaskEvent{Type: "briefing.created", Subject: path, Data: data, Timestamp: time.Now()})
+		slog.Info("Briefing created", "collection", c.Name, "path", path)
+	}
+	for path := range bw.known {
+		if strings.HasPrefix(path, c.Dir+"/") && !strings.Contains(strings.TrimPrefix(path, c.Dir+"/"), "/") && !present[path] {
+			delete(bw.known, path)
+		}
+	}
+}
+
+// run watches until ctx is cancelled.
+func (bw *briefingWatcher) run(ctx context.Context, pollInterval time.Duration) {
+	watcher, err := fsnotify.NewWatcher()
+	var fsEvents <-chan fsnotify.Event
+	var fsErrors <-chan error
+	if err != nil {
+		slog.Warn("fsnotify unavailable, polling briefings", "interval", pollInterval, "error", err)
+		watcher = nil
+	} else {
+		defer watcher.Close()
+		fsEvents, fsErrors = watcher.Events, watcher.Errors
+	}
+
+	watched := map[string]bool{}
+	addWatches := func() {
+		if watcher == nil {
+			return
+		}
+		for _, c := range bw.collections {
+			dir := filepath.Join(bw.vaultPath, c.Dir)
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err == nil {
+				watched[dir] = true
+			}
+		}
+	}
+	addWatches()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			addWatches()
+			for _, c := range bw.collections {
+				bw.scan(c)
+			}
+		case event, ok := <-fsEvents:
+			if !ok {
+				fsEvents = nil
+				continue
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) && !event.Has(fsnotify.Remove) {
+				continue
+			}
+			dir := filepath.Dir(event.Name)
+			for _, c := range bw.collections {
+				if filepath.Join(bw.vaultPath, c.Dir) == dir {
+					bw.scan(c)
+				}
+			}
+		case err, ok := <-fsErrors:
+			if !ok {
+				fsErrors = nil
+				continue
+			}
+			slog.Warn("Briefing watcher error", "error", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBriefingFeed(t *testing.T) {
+	vault := t.TempDir()
+	dir := filepath.Join(vault, "Briefings", "Daily")
+	writeTestBriefing(t, dir, "2024-01-01.md", "# First\n\n<script>alert(1)</script> plain & simple")
+	writeTestBriefing(t, dir, "2024-01-02.md", testBriefingMarkdown)
+	modified := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "2024-01-02.md"), modified, modified)
+	os.Chtimes(filepath.Join(dir, "2024-01-01.md"), modified.Add(-24*time.Hour), modified.Add(-24*time.Hour))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings/feed.atom", briefingFeedHandler(vault)).Methods("GET")
+
+	req := httptest.NewRequest("GET", "https://dash.example/api/briefings/feed.atom", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].Title != "Morning Report" || feed.Updated != "2024-01-02T08:00:00Z" {
+		t.Fatalf("unexpected feed %+v", feed)
+	}
+	newest := feed.Entries[0]
+	if newest.Published != "2024-01-02T00:00:00Z" || len(newest.Categories) != 3 || newest.Links[0].Href != "https://dash.example/api/briefings/2024-01-02" {
+		t.Errorf("unexpected entry %+v", newest)
+	}
+	if !strings.Contains(newest.Content.Body, "<h2>Incidents</h2>") || strings.Contains(newest.Content.Body, "title: Morning Report") {
+		t.Errorf("expected rendered HTML without front-matter, got %s", newest.Content.Body)
+	}
+	if strings.Contains(feed.Entries[1].Content.Body, "<script>") {
+		t.Errorf("expected raw HTML dropped, got %s", feed.Entries[1].Content.Body)
+	}
+
+	// Feed readers polling with If-Modified-Since get a 304
+	req = httptest.NewRequest("GET", "/api/briefings/feed.atom", nil)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/briefings/feed.atom?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestBriefingWatcher(t *testing.T) {
+	vault := t.TempDir()
+	daily := filepath.Join(vault, "Briefings", "Daily")
+	writeTestBriefing(t, daily, "2024-01-01.md", "existing")
+
+	hub := newEventHub()
+	events, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+	collections, _ := loadBriefingCollections("")
+	bw := newBriefingWatcher(vault, collections, hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bw.run(ctx, 50*time.Millisecond)
+
+	// Temp files and non-matching names are ignored; the weekly folder is
+	// created after startup and picked up by the rescan
+	writeTestBriefing(t, daily, ".2024-01-02.md.tmp-1", "partial")
+	writeTestBriefing(t, daily, "scratch.md", "not a date")
+	writeTestBriefing(t, daily, "2024-01-02.md", "new")
+	writeTestBriefing(t, filepath.Join(vault, "Briefings", "Weekly"), "2024-W01.md", "week")
+
+	got := map[string]BriefingCreatedEvent{}
+	deadline := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case ev := <-events:
+			var data BriefingCreatedEvent
+			json.Unmarshal(ev.Data, &data)
+			if ev.Type != "briefing.created" || ev.Subject != data.Path {
+				t.Errorf("unexpected event %+v", ev)
+			}
+			got[data.Path] = data
+		case <-deadline:
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	if got["Briefings/Daily/2024-01-02.md"].Entry != "2024-01-02" || got["Briefings/Weekly/2024-W01.md"].Collection != "weekly" {
+		t.Errorf("unexpected events %v", got)
+	}
+
+	// Nothing further: the existing briefing and ignored files stay quiet
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected extra event %+v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// eventHubBuffer is how many events a slow subscriber may lag behind before
+// further events to it are dropped.
+const eventHubBuffer = 64
+
+// eventHub fans dashboard-originated events (ones that don't travel over
+// NATS, such as vault changes) out to every open /api/ws connection.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan TaskEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan TaskEvent]struct{}{}}
+}
+
+// uiEvents is the process-wide hub wsHandler forwards to its clients.
+var uiEvents = newEventHub()
+
+// subscribe registers a listener; call the returned func to unsubscribe.
+func (h *eventHub) subscribe() (<-chan TaskEvent, func()) {
+	ch := make(chan TaskEvent, eventHubBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish delivers an event to every subscriber without blocking.
+func (h *eventHub) publish(event TaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Event hub subscriber lagging, dropping event", "type", event.Type)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := newEventHub()
+	a, unsubscribeA := hub.subscribe()
+	b, unsubscribeB := hub.subscribe()
+
+	hub.publish(TaskEvent{Type: "briefing.created", Subject: "x"})
+	for _, ch := range []<-chan TaskEvent{a, b} {
+		select {
+		case ev := <-ch:
+			if ev.Subject != "x" {
+				t.Errorf("unexpected event %+v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected event delivered to every subscriber")
+		}
+	}
+
+	unsubscribeA()
+	unsubscribeA() // idempotent
+	if _, ok := <-a; ok {
+		t.Error("expected channel closed after unsubscribe")
+	}
+
+	// A subscriber that stops reading is skipped, not blocked on
+	for i := 0; i < eventHubBuffer+10; i++ {
+		hub.publish(TaskEvent{Type: "briefing.created"})
+	}
+	if len(b) != eventHubBuffer {
+		t.Errorf("expected buffer of %d, got %d", eventHubBuffer, len(b))
+	}
+	unsubscribeB()
+}
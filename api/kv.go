@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// maxKVValueSize caps PUT bodies; NATS' default max payload is 1MB.
+const maxKVValueSize = 1 << 20
+
+// validKVKey matches NATS KV keys that fit in a single route segment:
+// dot-separated tokens, no wildcards or empty tokens.
+var validKVKey = regexp.MustCompile(`^[-_=a-zA-Z0-9]+(\.[-_=a-zA-Z0-9]+)*$`)
+
+// getKVBucket returns an existing KV bucket handle. Unlike
+// getOrCreateKVBucket it never creates — reads of unknown buckets are 404s.
+func getKVBucket(ctx context.Context, bucket string) (jetstream.KeyValue, error) {
+	kv, err := js.KeyValue(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("KV bucket %s: %w", bucket, err)
+	}
+	return kv, nil
+}
+
+// kvError maps a JetStream KV error to an HTTP response.
+func kvError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, jetstream.ErrBucketNotFound):
+		http.Error(w, "Bucket not found", http.StatusNotFound)
+	case errors.Is(err, jetstream.ErrKeyNotFound), errors.Is(err, jetstream.ErrKeyDeleted):
+		http.Error(w, notFound, http.StatusNotFound)
+	case isRevisionConflict(err):
+		http.Error(w, "Revision conflict", http.StatusPreconditionFailed)
+	default:
+		slog.Error("KV error", "error", err)
+		http.Error(w, "KV operation failed", http.StatusInternalServerError)
+	}
+}
+
+// isRevisionConflict reports whether a KV write failed its expected-revision
+// check (Update with a stale revision, Create of an existing key).
+func isRevisionConflict(err error) bool {
+	var apiErr *jetstream.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence
+}
+
+// kvETag formats a KV revision as a strong ETag.
+func kvETag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// parseRevisionPrecondition parses an If-Match value into a KV revision.
+// Quoted and weak ETags are accepted; "*" returns ok with revision 0.
+func parseRevisionPrecondition(value string) (revision uint64, err error) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return 0, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	revision, err = strconv.ParseUint(value, 10, 64)
+	if err != nil || revision == 0 {
+		return 0, fmt.Errorf("If-Match must be a KV revision")
+	}
+	return revision, nil
+}
+
+// kvKeyVars validates and returns the {bucket} and {key} route variables.
+func kvKeyVars(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	vars := mux.Vars(r)
+	bucket, key := vars["bucket"], vars["key"]
+	if !validBucketName.MatchString(bucket) {
+		http.Error(w, "Invalid bucket name", http.StatusBadRequest)
+		return "", "", false
+	}
+	if !validKVKey.MatchString(key) {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return "", "", false
+	}
+	return bucket, key, true
+}
+
+// kvPutHandler writes a key. Concurrency is optimistic: If-Match: "<rev>"
+// only writes over that revision, If-Match: * requires the key to exist and
+// If-None-Match: * only creates. Failed preconditions are 412s. Unlike reads,
+// a PUT may create the bucket.
+func kvPutHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket, key, ok := kvKeyVars(w, r)
+		if !ok {
+			return
+		}
+
+		if !policy.authorize(w, r, bucket, key, true) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		createOnly := strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+		var revision uint64
+		if ifMatch != "" {
+			if createOnly {
+				http.Error(w, "If-Match and If-None-Match are mutually exclusive", http.StatusBadRequest)
+				return
+			}
+			rev, err := parseRevisionPrecondition(ifMatch)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			revision = rev
+		}
+
+		value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxKVValueSize))
+		if err != nil {
+			http.Error(w, "Value too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		ctx := r.Context()
+		kv, err := getOrCreateKVBucket(ctx, bucket)
+		if err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+
+		created := false
+		switch {
+		case createOnly:
+			revision, err = kv.Create(ctx, key, value)
+			created = true
+		case ifMatch != "" && revision == 0: // If-Match: *
+			entry, gerr := kv.Get(ctx, key)
+			if gerr != nil {
+				http.Error(w, "Key does not exist", http.StatusPreconditionFailed)
+				return
+			}
+			revision, err = kv.Update(ctx, key, value, entry.Revision())
+		case ifMatch != "":
+			revision, err = kv.Update(ctx, key, value, revision)
+		default:
+			revision, err = kv.Put(ctx, key, value)
+		}
+		if err != nil {
+			if errors.Is(err, jetstream.ErrKeyExists) {
+				http.Error(w, "Key already exists", http.StatusPreconditionFailed)
+				return
+			}
+			kvError(w, err, "Key not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", kvETag(revision))
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"bucket":   bucket,
+			"key":      key,
+			"revision": revision,
+		})
+	}
+}
+
+// kvDeleteHandler deletes a key, leaving a tombstone in its history;
+// ?purge=true also drops the retained revisions. If-Match is honoured as
+// for PUT.
+func kvDeleteHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket, key, ok := kvKeyVars(w, r)
+		if !ok {
+			return
+		}
+
+		if !policy.authorize(w, r, bucket, key, true) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var revision uint64
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			rev, err := parseRevisionPrecondition(ifMatch)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			revision = rev
+		}
+
+		ctx := r.Context()
+		kv, err := getKVBucket(ctx, bucket)
+		if err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+		// NATS happily tombstones keys that never existed — check first
+		if _, err := kv.Get(ctx, key); err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+
+		var opts []jetstream.KVDeleteOpt
+		if revision != 0 {
+			opts = append(opts, jetstream.LastRevision(revision))
+		}
+		if r.URL.Query().Get("purge") == "true" {
+			err = kv.Purge(ctx, key, opts...)
+		} else {
+			err = kv.Delete(ctx, key, opts...)
+		}
+		if err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// KVRevision is one retained revision of a KV key. Value is inlined as JSON
+// when it parses as JSON and as a string otherwise; deletes have no value.
+type KVRevision struct {
+	Revision  uint64      `json:"revision"`
+	Operation string      `json:"operation"`
+	Created   time.Time   `json:"created"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// kvOperation names a KV operation for API responses.
+func kvOperation(op jetstream.KeyValueOp) string {
+	switch op {
+	case jetstream.KeyValueDelete:
+		return "delete"
+	case jetstream.KeyValuePurge:
+		return "purge"
+	default:
+		return "put"
+	}
+}
+
+// kvRevisionValue renders a KV value for JSON responses.
+func kvRevisionValue(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	if json.Valid(value) {
+		return json.RawMessage(value)
+	}
+	return string(value)
+}
+
+// kvHistoryHandler returns a key's retained revisions (the bucket's History
+// setting, 3 for buckets this dashboard creates), oldest first.
+func kvHistoryHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket, key, ok := kvKeyVars(w, r)
+		if !ok {
+			return
+		}
+
+		if !policy.authorize(w, r, bucket, key, false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		ctx := r.Context()
+		kv, err := getKVBucket(ctx, bucket)
+		if err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+		entries, err := kv.History(ctx, key)
+		if err != nil {
+			kvError(w, err, "Key not found")
+			return
+		}
+
+		revisions := make([]KVRevision, 0, len(entries))
+		for _, e := range entries {
+			revisions = append(revisions, KVRevision{
+				Revision:  e.Revision(),
+				Operation: kvOperation(e.Operation()),
+				Created:   e.Created().UTC(),
+				Value:     kvRevisionValue(policy.redactValue(e.Value())),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"bucket":    bucket,
+			"key":       key,
+			"revisions": revisions,
+		})
+	}
+}
+
+// KVBucketInfo describes a KV bucket for the catalogue endpoints.
+type KVBucketInfo struct {
+	Bucket      string `json:"bucket"`
+	Description string `json:"description,omitempty"`
+	Values      uint64 `json:"values"`
+	Bytes       uint64 `json:"bytes"`
+	History     int64  `json:"history"`
+	TTL         string `json:"ttl,omitempty"` // empty when values never expire
+	TTLSeconds  int64  `json:"ttlSeconds"`
+	Replicas    int    `json:"replicas,omitempty"`
+	Storage     string `json:"storage,omitempty"` // file or memory
+	Compressed  bool   `json:"compressed"`
+}
+
+// kvBucketInfo converts a bucket status. Replicas, storage and description
+// live on the backing stream, which JetStream-backed statuses expose.
+func kvBucketInfo(status jetstream.KeyValueStatus) KVBucketInfo {
+	info := KVBucketInfo{
+		Bucket:     status.Bucket(),
+		Values:     status.Values(),
+		Bytes:      status.Bytes(),
+		History:    status.History(),
+		TTLSeconds: int64(status.TTL().Seconds()),
+		Compressed: status.IsCompressed(),
+	}
+	if ttl := status.TTL(); ttl > 0 {
+		info.TTL = ttl.String()
+	}
+	if s, ok := status.(interface{ StreamInfo() *jetstream.StreamInfo }); ok && s.StreamInfo() != nil {
+		cfg := s.StreamInfo().Config
+		info.Description = cfg.Description
+		info.Replicas = cfg.Replicas
+		switch cfg.Storage {
+		case jetstream.FileStorage:
+			info.Storage = "file"
+		case jetstream.MemoryStorage:
+			info.Storage = "memory"
+		}
+	}
+	return info
+}
+
+// kvBucketsHandler lists every KV bucket with its status.
+func kvBucketsHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		lister := js.KeyValueStores(r.Context())
+		buckets := []KVBucketInfo{}
+		role := requestRole(r)
+		for status := range lister.Status() {
+			if policy.canRead(role, status.Bucket()) {
+				buckets = append(buckets, kvBucketInfo(status))
+			}
+		}
+		if err := lister.Error(); err != nil {
+			slog.Error("KV bucket list error", "error", err)
+			http.Error(w, "Failed to list KV buckets", http.StatusInternalServerError)
+			return
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Bucket < buckets[j].Bucket })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buckets)
+	}
+}
+
+// kvBucketHandler returns one bucket's status.
+func kvBucketHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket := mux.Vars(r)["bucket"]
+		if !validBucketName.MatchString(bucket) {
+			http.Error(w, "Invalid bucket name", http.StatusBadRequest)
+			return
+		}
+
+		if !policy.authorize(w, r, bucket, "", false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+		kv, err := getKVBucket(r.Context(), bucket)
+		if err != nil {
+			kvError(w, err, "Bucket not found")
+			return
+		}
+		status, err := kv.Status(r.Context())
+		if err != nil {
+			kvError(w, err, "Bucket not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(kvBucketInfo(status))
+	}
+}
+
+// Key listing page sizes for GET /kv/{bucket}/keys.
+const (
+	defaultKVKeysLimit = 1000
+	maxKVKeysLimit     = 10000
+)
+
+// listKVKeys returns a bucket's live keys starting with prefix, minus keys
+// hidden by the policy. Prefixes that
+// end on a token boundary ("mission.") are filtered server-side.
+func listKVKeys(ctx context.Context, kv jetstream.KeyValue, prefix string, policy *kvPolicy) ([]string, error) {
+	var lister jetstream.KeyLister
+	var err error
+	if strings.HasSuffix(prefix, ".") && validKVKey.MatchString(strings.TrimSuffix(prefix, ".")) {
+		lister, err = kv.ListKeysFiltered(ctx, prefix+">")
+	} else {
+		lister, err = kv.ListKeys(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer lister.Stop()
+
+	keys := []string{}
+	for key := range lister.Keys() {
+		if strings.HasPrefix(key, prefix) && policy.keyVisible(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// paginateKeys sorts keys and returns the page after cursor (the last key of
+// the previous page), plus the cursor for the next page ("" on the last page).
+func paginateKeys(keys []string, cursor string, limit int) ([]string, string) {
+	sort.Strings(keys)
+	start := 0
+	if cursor != "" {
+		start = sort.SearchStrings(keys, cursor)
+		if start < len(keys) && keys[start] == cursor {
+			start++
+		}
+	}
+	end := start + limit
+	if end >= len(keys) {
+		return keys[start:], ""
+	}
+	return keys[start:end], keys[end-1]
+}
+
+// validKVWatchPattern matches a key or a NATS-style key pattern: "*" matches
+// one token and a trailing ">" matches the rest (e.g. mychain.* or mychain.>).
+var validKVWatchPattern = regexp.MustCompile(`^(>|([-_=a-zA-Z0-9]+|\*)(\.([-_=a-zA-Z0-9]+|\*))*(\.>)?)$`)
+
+// KVWatchEvent is a put, delete or purge pushed by the KV watch stream.
+type KVWatchEvent struct {
+	Bucket    string      `json:"bucket"`
+	Key       string      `json:"key"`
+	Operation string      `json:"operation"`
+	Revision  uint64      `json:"revision"`
+	Created   time.Time   `json:"created"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+func kvWatchEvent(entry jetstream.KeyValueEntry) KVWatchEvent {
+	return KVWatchEvent{
+		Bucket:    entry.Bucket(),
+		Key:       entry.Key(),
+		Operation: kvOperation(entry.Operation()),
+		Revision:  entry.Revision(),
+		Created:   entry.Created().UTC(),
+		Value:     kvRevisionValue(entry.Value()),
+	}
+}
+
+// kvWatchHandler streams changes to keys matching ?key= (default: all keys)
+// as SSE "kv" events. The current value of each matching key is replayed
+// first, followed by a "ready" event; ?updatesOnly=true skips the replay.
+func kvWatchHandler(policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket := mux.Vars(r)["bucket"]
+		if !validBucketName.MatchString(bucket) {
+			http.Error(w, "Invalid bucket name", http.StatusBadRequest)
+			return
+		}
+		pattern := r.URL.Query().Get("key")
+		if pattern == "" {
+			pattern = ">"
+		}
+		if !validKVWatchPattern.MatchString(pattern) {
+			http.Error(w, "Invalid key pattern", http.StatusBadRequest)
+			return
+		}
+
+		if !policy.authorize(w, r, bucket, "", false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		ctx := r.Context()
+		kv, err := getKVBucket(ctx, bucket)
+		if err != nil {
+			kvError(w, err, "Bucket not found")
+			return
+		}
+		var opts []jetstream.WatchOpt
+		if r.URL.Query().Get("updatesOnly") == "true" {
+			opts = append(opts, jetstream.UpdatesOnly())
+		}
+		watcher, err := kv.Watch(ctx, pattern, opts...)
+		if err != nil {
+			kvError(w, err, "Bucket not found")
+			return
+		}
+		defer watcher.Stop()
+
+		stream := newSSEWriter(w)
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if stream.heartbeat() != nil {
+					return
+				}
+			case entry, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				if entry == nil {
+					// Initial values delivered — everything after is live
+					if stream.send("ready", map[string]string{"bucket": bucket, "key": pattern}) != nil {
+						return
+					}
+					continue
+				}
+				if !policy.keyVisible(entry.Key()) {
+					continue
+				}
+				ev := kvWatchEvent(entry)
+				ev.Value = kvRevisionValue(policy.redactValue(entry.Value()))
+				if stream.send("kv", ev) != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// TestKVRequestValidation checks request validation, which runs before NATS is
+// needed (the test router has no JetStream).
+func TestKVRequestValidation(t *testing.T) {
+	router := setupTestRouter()
+	js = nil
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"invalid bucket", "PUT", "/api/kv/1bad/key", http.StatusBadRequest},
+		{"wildcard key", "PUT", "/api/kv/cache/a.*", http.StatusBadRequest},
+		{"empty token", "DELETE", "/api/kv/cache/a..b", http.StatusBadRequest},
+		{"history invalid key", "GET", "/api/kv/cache/a.>/history", http.StatusBadRequest},
+		{"put without NATS", "PUT", "/api/kv/cache/a.b", http.StatusServiceUnavailable},
+		{"delete without NATS", "DELETE", "/api/kv/cache/a.b", http.StatusServiceUnavailable},
+		{"history without NATS", "GET", "/api/kv/cache/a.b/history", http.StatusServiceUnavailable},
+		{"get without NATS", "GET", "/api/kv/cache/a.b", http.StatusServiceUnavailable},
+		{"buckets without NATS", "GET", "/api/kv", http.StatusServiceUnavailable},
+		{"bucket invalid name", "GET", "/api/kv/1bad", http.StatusBadRequest},
+		{"bucket without NATS", "GET", "/api/kv/cache", http.StatusServiceUnavailable},
+		{"keys without NATS", "GET", "/api/kv/cache/keys?prefix=a.", http.StatusServiceUnavailable},
+		{"watch invalid pattern", "GET", "/api/kv/chain-outputs/watch?key=a.>.b", http.StatusBadRequest},
+		{"watch without NATS", "GET", "/api/kv/chain-outputs/watch?key=mychain.*", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseRevisionPrecondition(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint64
+		wantErr bool
+	}{
+		{`"42"`, 42, false},
+		{`W/"7"`, 7, false},
+		{"13", 13, false},
+		{"*", 0, false},
+		{`"0"`, 0, true},
+		{`"abc"`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRevisionPrecondition(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRevisionPrecondition(%q) = %d, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestKVErrorMapping(t *testing.T) {
+	conflict := &jetstream.APIError{Code: 400, ErrorCode: jetstream.JSErrCodeStreamWrongLastSequence, Description: "wrong last sequence: 4"}
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("KV bucket x: %w", jetstream.ErrBucketNotFound), http.StatusNotFound},
+		{jetstream.ErrKeyNotFound, http.StatusNotFound},
+		{conflict, http.StatusPreconditionFailed},
+		{fmt.Errorf("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		kvError(w, tt.err, "Key not found")
+		if w.Code != tt.want {
+			t.Errorf("kvError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+		if strings.Contains(w.Body.String(), tt.err.Error()) {
+			t.Errorf("kvError(%v) leaked the error: %q", tt.err, w.Body.String())
+		}
+	}
+
+	if v := kvRevisionValue([]byte(`{"a":1}`)); fmt.Sprint(v) != `{"a":1}` {
+		t.Errorf("expected JSON value inlined, got %v", v)
+	}
+	if v := kvRevisionValue([]byte("plain")); v != "plain" {
+		t.Errorf("expected string value, got %v", v)
+	}
+}
+
+// fakeKVStatus is a JetStream-backed bucket status without a server
+type fakeKVStatus struct {
+	info *jetstream.StreamInfo
+}
+
+func (f fakeKVStatus) Bucket() string                    { return "mission-results" }
+func (f fakeKVStatus) Values() uint64                    { return 12 }
+func (f fakeKVStatus) History() int64                    { return 3 }
+func (f fakeKVStatus) TTL() time.Duration                { return 720 * time.Hour }
+func (f fakeKVStatus) BackingStore() string              { return "JetStream" }
+func (f fakeKVStatus) Bytes() uint64                     { return 4096 }
+func (f fakeKVStatus) IsCompressed() bool                { return false }
+func (f fakeKVStatus) StreamInfo() *jetstream.StreamInfo { return f.info }
+
+func TestKVBucketInfo(t *testing.T) {
+	info := kvBucketInfo(fakeKVStatus{info: &jetstream.StreamInfo{Config: jetstream.StreamConfig{
+		Description: "Round Table mission-results store",
+		Replicas:    3,
+		Storage:     jetstream.MemoryStorage,
+	}}})
+	if info.Bucket != "mission-results" || info.Values != 12 || info.Bytes != 4096 || info.History != 3 {
+		t.Errorf("unexpected counters: %+v", info)
+	}
+	if info.TTL != "720h0m0s" || info.TTLSeconds != 2592000 {
+		t.Errorf("unexpected TTL: %s / %d", info.TTL, info.TTLSeconds)
+	}
+	if info.Replicas != 3 || info.Storage != "memory" || info.Description == "" {
+		t.Errorf("expected stream config fields, got %+v", info)
+	}
+}
+
+func TestPaginateKeys(t *testing.T) {
+	keys := []string{"d", "a", "c", "b", "e"}
+
+	page, next := paginateKeys(keys, "", 2)
+	if strings.Join(page, ",") != "a,b" || next != "b" {
+		t.Fatalf("first page = %v next %q", page, next)
+	}
+	page, next = paginateKeys(keys, next, 2)
+	if strings.Join(page, ",") != "c,d" || next != "d" {
+		t.Fatalf("second page = %v next %q", page, next)
+	}
+	page, next = paginateKeys(keys, next, 2)
+	if strings.Join(page, ",") != "e" || next != "" {
+		t.Fatalf("last page = %v next %q", page, next)
+	}
+	// A cursor for a key deleted since the previous page still resumes after it
+	page, _ = paginateKeys([]string{"a", "c"}, "b", 10)
+	if strings.Join(page, ",") != "c" {
+		t.Errorf("expected resume after deleted cursor, got %v", page)
+	}
+}
+
+func TestKVWatchPattern(t *testing.T) {
+	valid := []string{">", "mychain.*", "mychain.>", "mychain.step-1", "*.output", "a.*.c"}
+	invalid := []string{"", "a.>.b", "a..b", "a*", ".a", "a.", "a b"}
+	for _, p := range valid {
+		if !validKVWatchPattern.MatchString(p) {
+			t.Errorf("expected %q to be a valid pattern", p)
+		}
+	}
+	for _, p := range invalid {
+		if validKVWatchPattern.MatchString(p) {
+			t.Errorf("expected %q to be rejected", p)
+		}
+	}
+}
+
+// fakeKVEntry is a KV watch update without a server
+type fakeKVEntry struct {
+	op    jetstream.KeyValueOp
+	value []byte
+}
+
+func (f fakeKVEntry) Bucket() string                  { return "chain-outputs" }
+func (f fakeKVEntry) Key() string                     { return "mychain.scan" }
+func (f fakeKVEntry) Value() []byte                   { return f.value }
+func (f fakeKVEntry) Revision() uint64                { return 7 }
+func (f fakeKVEntry) Created() time.Time              { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) }
+func (f fakeKVEntry) Delta() uint64                   { return 0 }
+func (f fakeKVEntry) Operation() jetstream.KeyValueOp { return f.op }
+
+func TestKVWatchEvent(t *testing.T) {
+	ev := kvWatchEvent(fakeKVEntry{op: jetstream.KeyValuePut, value: []byte(`{"output":"done"}`)})
+	payload, _ := json.Marshal(ev)
+	want := `{"bucket":"chain-outputs","key":"mychain.scan","operation":"put","revision":7,"created":"2024-01-01T10:00:00Z","value":{"output":"done"}}`
+	if string(payload) != want {
+		t.Errorf("unexpected put event:\n got %s\nwant %s", payload, want)
+	}
+
+	ev = kvWatchEvent(fakeKVEntry{op: jetstream.KeyValueDelete})
+	if ev.Operation != "delete" || ev.Value != nil {
+		t.Errorf("expected delete without value, got %+v", ev)
+	}
+}
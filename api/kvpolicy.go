@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+)
+
+// kvPolicyFile is the on-disk KV access policy (KV_POLICY_FILE):
+//
+//	{
+//	  "buckets": {"allow": ["mission-results", "chain-outputs", "fleet-*"], "deny": ["*-secrets"]},
+//	  "keys": {"deny": ["*.credentials"]},
+//	  "permissions": {"mission-results": {"read": "viewer", "write": "admin"}},
+//	  "redact": ["(?i)bearer (?P<secret>[a-z0-9._-]+)"]
+//	}
+//
+// Bucket and key patterns are globs. Omitting "redact" keeps the built-in
+// secret patterns; an empty list disables redaction.
+type kvPolicyFile struct {
+	Buckets struct {
+		Allow []string `json:"allow"`
+		Deny  []string `json:"deny"`
+	} `json:"buckets"`
+	Keys struct {
+		Allow []string `json:"allow"`
+		Deny  []string `json:"deny"`
+	} `json:"keys"`
+	Permissions map[string]kvPermission `json:"permissions"`
+	Redact      *[]string               `json:"redact"`
+}
+
+// kvPermission is the minimum role to read or write a bucket.
+type kvPermission struct {
+	Read  string `json:"read"`
+	Write string `json:"write"`
+}
+
+// defaultKVPermission applies to buckets without a permissions entry.
+var defaultKVPermission = kvPermission{Read: roleViewer, Write: roleOperator}
+
+// defaultKVRedactions catch common credential shapes. Where a pattern has a
+// "secret" group only that group is masked, so JSON values stay parseable.
+var defaultKVRedactions = []string{
+	`(?i)"[a-z0-9_-]*(?:password|passwd|secret|token|api[_-]?key|private[_-]?key)"\s*:\s*"(?P<secret>[^"]+)"`,
+	`(?i)\bbearer\s+(?P<secret>[a-z0-9._~+/-]{16,}=*)`,
+	`\bsk-[A-Za-z0-9_-]{20,}`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}`,
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	`\bAKIA[0-9A-Z]{16}\b`,
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+const redactedValue = "[REDACTED]"
+
+// kvPolicy decides which buckets and keys the KV browser exposes, who may
+// read or write them, and what is masked in returned values.
+type kvPolicy struct {
+	bucketAllow, bucketDeny []string
+	keyAllow, keyDeny       []string
+	permissions             map[string]kvPermission
+	redactions              []*regexp.Regexp
+}
+
+// loadKVPolicy reads a policy file; an empty path yields the default policy
+// (every bucket visible, viewers read, operators write, built-in redactions).
+func loadKVPolicy(file string) (*kvPolicy, error) {
+	var cfg kvPolicyFile
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read KV policy: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parse KV policy %s: %w", file, err)
+		}
+	}
+	return newKVPolicy(cfg)
+}
+
+func newKVPolicy(cfg kvPolicyFile) (*kvPolicy, error) {
+	p := &kvPolicy{
+		bucketAllow: cfg.Buckets.Allow,
+		bucketDeny:  cfg.Buckets.Deny,
+		keyAllow:    cfg.Keys.Allow,
+		keyDeny:     cfg.Keys.Deny,
+		permissions: cfg.Permissions,
+	}
+	for _, patterns := range [][]string{p.bucketAllow, p.bucketDeny, p.keyAllow, p.keyDeny} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid KV policy pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	for pattern, perm := range p.permissions {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid KV permissions pattern %q: %w", pattern, err)
+		}
+		for _, role := range []string{perm.Read, perm.Write} {
+			if role != "" && roleRank[role] == 0 {
+				return nil, fmt.Errorf("unknown role %q for bucket pattern %q", role, pattern)
+			}
+		}
+	}
+
+	redact := defaultKVRedactions
+	if cfg.Redact != nil {
+		redact = *cfg.Redact
+	}
+	for _, expr := range redact {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KV redaction %q: %w", expr, err)
+		}
+		p.redactions = append(p.redactions, re)
+	}
+	return p, nil
+}
+
+// matchesAny reports whether name matches one of the glob patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// visible applies an allow/deny pair: deny wins, an empty allowlist allows all.
+func visible(allow, deny []string, name string) bool {
+	if matchesAny(deny, name) {
+		return false
+	}
+	return len(allow) == 0 || matchesAny(allow, name)
+}
+
+func (p *kvPolicy) bucketVisible(bucket string) bool {
+	return visible(p.bucketAllow, p.bucketDeny, bucket)
+}
+
+func (p *kvPolicy) keyVisible(key string) bool {
+	return visible(p.keyAllow, p.keyDeny, key)
+}
+
+// permission returns the bucket's roles: an exact entry wins, then the
+// longest matching glob, then defaultKVPermission.
+func (p *kvPolicy) permission(bucket string) kvPermission {
+	perm, ok := p.permissions[bucket]
+	if !ok {
+		best := -1
+		for pattern, candidate := range p.permissions {
+			if matched, _ := path.Match(pattern, bucket); matched && len(pattern) > best {
+				perm, best = candidate, len(pattern)
+			}
+		}
+	}
+	if perm.Read == "" {
+		perm.Read = defaultKVPermission.Read
+	}
+	if perm.Write == "" {
+		perm.Write = defaultKVPermission.Write
+	}
+	return perm
+}
+
+// canRead reports whether role may read the bucket; hidden buckets are
+// unreadable for everyone.
+func (p *kvPolicy) canRead(role, bucket string) bool {
+	return p.bucketVisible(bucket) && roleAtLeast(role, p.permission(bucket).Read)
+}
+
+// canReadKey reports whether role may read the key, for callers that embed
+// KV values in other responses and leave out what the caller can't see.
+func (p *kvPolicy) canReadKey(role, bucket, key string) bool {
+	return p.canRead(role, bucket) && p.keyVisible(key)
+}
+
+// authorize checks access to a bucket (and key, if non-empty) and writes the
+// error response when denied. Hidden buckets and keys are reported as not
+// found so their existence isn't revealed; a missing role is a 403.
+func (p *kvPolicy) authorize(w http.ResponseWriter, r *http.Request, bucket, key string, write bool) bool {
+	if !p.bucketVisible(bucket) {
+		http.Error(w, "Bucket not found", http.StatusNotFound)
+		return false
+	}
+	if key != "" && !p.keyVisible(key) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return false
+	}
+	perm := p.permission(bucket)
+	required := perm.Read
+	if write {
+		required = perm.Write
+	}
+	if !roleAtLeast(requestRole(r), required) {
+		forbidden(w, required)
+		return false
+	}
+	return true
+}
+
+// redact masks secret-looking content in a value. It reports whether
+// anything was masked.
+func (p *kvPolicy) redact(value []byte) ([]byte, bool) {
+	redacted := false
+	for _, re := range p.redactions {
+		group := re.SubexpIndex("secret")
+		matches := re.FindAllSubmatchIndex(value, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		redacted = true
+		var out []byte
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if group > 0 && m[2*group] >= 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			out = append(out, value[last:start]...)
+			out = append(out, redactedValue...)
+			last = end
+		}
+		value = append(out, value[last:]...)
+	}
+	return value, redacted
+}
+
+// redactValue is redact without the report, for values embedded in JSON.
+func (p *kvPolicy) redactValue(value []byte) []byte {
+	value, _ = p.redact(value)
+	return value
+}
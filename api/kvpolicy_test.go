@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testKVPolicyJSON = `{
+	"buckets": {"allow": ["mission-results", "chain-outputs", "fleet-*"], "deny": ["fleet-secrets"]},
+	"keys": {"deny": ["*.credentials"]},
+	"permissions": {
+		"mission-results": {"write": "admin"},
+		"fleet-*": {"read": "operator"}
+	}
+}`
+
+func writeTestKVPolicy(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "kv-policy.json")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadKVPolicy(t *testing.T) {
+	policy, err := loadKVPolicy(writeTestKVPolicy(t, testKVPolicyJSON))
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	visible := map[string]bool{
+		"mission-results": true,
+		"fleet-a":         true,
+		"fleet-secrets":   false,
+		"unrelated":       false,
+	}
+	for bucket, want := range visible {
+		if got := policy.bucketVisible(bucket); got != want {
+			t.Errorf("bucketVisible(%s) = %v, want %v", bucket, got, want)
+		}
+	}
+	if policy.keyVisible("op-1.credentials") || !policy.keyVisible("op-1") {
+		t.Error("expected *.credentials keys to be hidden")
+	}
+
+	if perm := policy.permission("mission-results"); perm.Read != roleViewer || perm.Write != roleAdmin {
+		t.Errorf("unexpected mission-results permission: %+v", perm)
+	}
+	if perm := policy.permission("fleet-a"); perm.Read != roleOperator || perm.Write != roleOperator {
+		t.Errorf("unexpected fleet-a permission: %+v", perm)
+	}
+	if policy.canRead(roleViewer, "fleet-a") || !policy.canRead(roleOperator, "fleet-a") {
+		t.Error("expected fleet-* reads to require operator")
+	}
+
+	for _, bad := range []string{
+		`{"permissions": {"x": {"read": "superuser"}}}`,
+		`{"redact": ["("]}`,
+		`{"buckets": {"allow": ["["]}}`,
+		`{"bucket": {}}`,
+	} {
+		if _, err := loadKVPolicy(writeTestKVPolicy(t, bad)); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+// TestKVPolicyAuthorize checks hidden buckets/keys read as 404 and missing
+// roles as 403, before NATS is consulted
+func TestKVPolicyAuthorize(t *testing.T) {
+	policy, err := loadKVPolicy(writeTestKVPolicy(t, testKVPolicyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js = nil
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/kv/{bucket}/{key}", kvGetHandler(policy)).Methods("GET")
+	router.HandleFunc("/api/kv/{bucket}/{key}", kvPutHandler(policy)).Methods("PUT")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		role           string
+		expectedStatus int
+	}{
+		{"hidden bucket", "GET", "/api/kv/unrelated/a", roleAdmin, http.StatusNotFound},
+		{"denied bucket", "GET", "/api/kv/fleet-secrets/a", roleAdmin, http.StatusNotFound},
+		{"hidden key", "GET", "/api/kv/mission-results/op-1.credentials", roleAdmin, http.StatusNotFound},
+		{"viewer read", "GET", "/api/kv/mission-results/op-1", roleViewer, http.StatusServiceUnavailable},
+		{"viewer read restricted", "GET", "/api/kv/fleet-a/op-1", roleViewer, http.StatusForbidden},
+		{"operator write admin bucket", "PUT", "/api/kv/mission-results/op-1", roleOperator, http.StatusForbidden},
+		{"admin write", "PUT", "/api/kv/mission-results/op-1", roleAdmin, http.StatusServiceUnavailable},
+		{"operator write", "PUT", "/api/kv/chain-outputs/c.s", roleOperator, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`))
+			req = req.WithContext(withRole(req.Context(), tt.role))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusForbidden {
+				var body map[string]string
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body["requiredRole"] == "" {
+					t.Errorf("expected requiredRole in 403 body, got %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestKVPolicyDedicatedReads checks the mission results and step output
+// routes, and the report's KV reads, go through the same policy.
+func TestKVPolicyDedicatedReads(t *testing.T) {
+	policy, err := loadKVPolicy(writeTestKVPolicy(t, `{
+		"keys": {"deny": ["*.credentials"]},
+		"permissions": {"mission-results": {"read": "admin"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js = nil
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/missions/{name}/results", missionResultsHandler(policy)).Methods("GET")
+	router.HandleFunc("/api/chains/{name}/steps/{step}/output", chainStepOutputHandler(policy)).Methods("GET")
+
+	tests := []struct {
+		path           string
+		role           string
+		expectedStatus int
+	}{
+		{"/api/missions/op-1/results", roleViewer, http.StatusForbidden},
+		{"/api/missions/op-1/results", roleAdmin, http.StatusServiceUnavailable},
+		{"/api/missions/op-1/results", "", http.StatusForbidden},
+		{"/api/chains/c1/steps/credentials/output", roleAdmin, http.StatusNotFound},
+		{"/api/chains/c1/steps/s1/output", roleViewer, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req = req.WithContext(withRole(req.Context(), tt.role))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s as %q: expected status %d, got %d", tt.path, tt.role, tt.expectedStatus, w.Code)
+		}
+	}
+
+	if policy.canReadKey(roleViewer, "mission-results", "op-1") || !policy.canReadKey(roleAdmin, "mission-results", "op-1") {
+		t.Error("expected mission results readable by admins only")
+	}
+	if policy.canReadKey(roleAdmin, "chain-outputs", "c1.credentials") {
+		t.Error("expected hidden keys unreadable")
+	}
+}
+
+func TestKVPolicyRedact(t *testing.T) {
+	policy, _ := loadKVPolicy("")
+
+	value := []byte(`{"output":"ok","apiKey":"abc123","nested":{"db_password":"hunter2"},"note":"use sk-ABCDEFGHIJKLMNOPQRSTUVWX"}`)
+	redacted, changed := policy.redact(value)
+	if !changed {
+		t.Fatal("expected value to be redacted")
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(redacted, &parsed); err != nil {
+		t.Fatalf("redacted JSON no longer parses: %v (%s)", err, redacted)
+	}
+	if parsed["apiKey"] != redactedValue || parsed["nested"].(map[string]interface{})["db_password"] != redactedValue {
+		t.Errorf("expected key-valued secrets masked, got %s", redacted)
+	}
+	if parsed["output"] != "ok" || strings.Contains(string(redacted), "sk-ABC") {
+		t.Errorf("unexpected redaction result: %s", redacted)
+	}
+
+	if _, changed := policy.redact([]byte(`{"output":"nothing to see"}`)); changed {
+		t.Error("expected clean value to be untouched")
+	}
+
+	// An explicit empty list disables redaction
+	none, _ := loadKVPolicy(writeTestKVPolicy(t, `{"redact": []}`))
+	if _, changed := none.redact(value); changed {
+		t.Error("expected redaction disabled")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// defaultKVMaxValueBytes is the most of a value one response carries; the
+// rest of a bigger value is fetched with Range requests. Override with
+// KV_MAX_VALUE_BYTES.
+const defaultKVMaxValueBytes = 4 << 20
+
+// kvMaxValueBytes reads KV_MAX_VALUE_BYTES, falling back to the default.
+func kvMaxValueBytes() int {
+	n, err := strconv.Atoi(envOr("KV_MAX_VALUE_BYTES", strconv.Itoa(defaultKVMaxValueBytes)))
+	if err != nil || n <= 0 {
+		return defaultKVMaxValueBytes
+	}
+	return n
+}
+
+// sniffKVContentType guesses a stored value's media type. KV entries carry
+// no metadata, so JSON is checked first, then Markdown's usual markers, then
+// net/http's sniffer (which reports binary as application/octet-stream).
+func sniffKVContentType(value []byte) string {
+	if json.Valid(value) {
+		return "application/json"
+	}
+	detected := http.DetectContentType(value)
+	if strings.HasPrefix(detected, "text/plain") && looksLikeMarkdown(value) {
+		return "text/markdown; charset=utf-8"
+	}
+	return detected
+}
+
+// looksLikeMarkdown checks the start of a text value for front-matter,
+// headings, fences or lists.
+func looksLikeMarkdown(value []byte) bool {
+	head := value
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if bytes.HasPrefix(head, []byte("---\n")) {
+		return true
+	}
+	for _, line := range bytes.Split(head, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		for _, marker := range []string{"# ", "## ", "### ", "```", "- [ ] ", "- [x] "} {
+			if bytes.HasPrefix(line, []byte(marker)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveKVEntry serves an entry read through policy: its value redacted, and
+// X-Redacted set when anything was masked.
+func serveKVEntry(w http.ResponseWriter, r *http.Request, policy *kvPolicy, key string, entry jetstream.KeyValueEntry, maxBytes int) {
+	value, redacted := policy.redact(entry.Value())
+	if redacted {
+		w.Header().Set("X-Redacted", "true")
+	}
+	serveKVValue(w, r, key, entry, value, redacted, maxBytes)
+}
+
+// serveKVValue writes a KV value honouring ?format= and ?pretty=:
+//
+//   - format=raw (default) serves the bytes with their sniffed content type
+//   - format=json requires a JSON value (406 otherwise)
+//   - format=text serves UTF-8 values as text/plain (406 for binary)
+//   - pretty=true indents JSON values
+//
+// Values go through http.ServeContent, so Range, If-Range and conditional
+// requests work. No response carries more than maxBytes of the value: a
+// bigger value is served as a 200 with its first maxBytes and
+// X-Value-Truncated: true (X-Value-Size has the full length), and ranges are
+// cut short at maxBytes. The ETag is the revision, strong when the stored
+// bytes are served unchanged and weak when they were indented or redacted.
+func serveKVValue(w http.ResponseWriter, r *http.Request, name string, entry jetstream.KeyValueEntry, value []byte, redacted bool, maxBytes int) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	contentType := sniffKVContentType(value)
+	isJSON := contentType == "application/json"
+
+	switch format {
+	case "", "raw":
+	case "json":
+		if !isJSON {
+			http.Error(w, "Value is not JSON (stored as "+contentType+")", http.StatusNotAcceptable)
+			return
+		}
+	case "text":
+		if !utf8.Valid(value) {
+			http.Error(w, "Value is binary", http.StatusNotAcceptable)
+			return
+		}
+		contentType = "text/plain; charset=utf-8"
+	default:
+		http.Error(w, "Invalid format (allowed: raw, json, text)", http.StatusBadRequest)
+		return
+	}
+
+	transformed := redacted
+	if q.Get("pretty") == "true" && isJSON {
+		var buf bytes.Buffer
+		if json.Indent(&buf, value, "", "  ") == nil && !bytes.Equal(buf.Bytes(), value) {
+			value, transformed = buf.Bytes(), true
+		}
+	}
+	etag := kvETag(entry.Revision())
+	if transformed {
+		etag = "W/" + etag
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	if len(value) > maxBytes {
+		r = r.Clone(r.Context())
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("X-Value-Size", strconv.Itoa(len(value)))
+		rangeHeader := r.Header.Get("Range")
+		// The revision names the value, so the weak ETag of a truncated
+		// response is as good as the strong one here
+		if ifRange := r.Header.Get("If-Range"); ifRange != "" && strings.TrimPrefix(ifRange, "W/") != strings.TrimPrefix(etag, "W/") {
+			rangeHeader = "" // changed since the client's last range: start over
+		}
+		r.Header.Del("If-Range")
+		if rangeHeader == "" {
+			// The body isn't the whole value, so it gets no strong validator
+			if !transformed {
+				w.Header().Set("ETag", "W/"+etag)
+			}
+			w.Header().Set("X-Value-Truncated", "true")
+			r.Header.Del("Range")
+			value = value[:maxBytes]
+		} else {
+			clamped, ok := clampKVRange(rangeHeader, len(value), maxBytes)
+			if !ok {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(value)))
+				http.Error(w, fmt.Sprintf("Request one range of at most %d bytes", maxBytes), http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			r.Header.Set("Range", clamped)
+		}
+	}
+	http.ServeContent(w, r, name, entry.Created().Truncate(time.Second), bytes.NewReader(value))
+}
+
+// clampKVRange rewrites a single-range Range header so it covers at most
+// maxBytes of a size-byte value. Multiple or malformed ranges aren't served.
+func clampKVRange(header string, size, maxBytes int) (string, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return "", false
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return "", false
+	}
+	if startStr == "" { // suffix range: the last n bytes
+		n, err := strconv.Atoi(endStr)
+		if err != nil || n <= 0 {
+			return "", false
+		}
+		return fmt.Sprintf("bytes=-%d", min(n, maxBytes)), true
+	}
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 || start >= size {
+		return "", false
+	}
+	end := size - 1
+	if endStr != "" {
+		if end, err = strconv.Atoi(endStr); err != nil || end < start {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("bytes=%d-%d", start, min(end, start+maxBytes-1)), true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestSniffKVContentType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`{"output": "done"}`, "application/json"},
+		{`"just a string"`, "application/json"},
+		{"# Report\n\nAll clear.", "text/markdown; charset=utf-8"},
+		{"---\ntags: [x]\n---\nbody", "text/markdown; charset=utf-8"},
+		{"Summary:\n```go\nfmt.Println()\n```", "text/markdown; charset=utf-8"},
+		{"plain words only", "text/plain; charset=utf-8"},
+		{"\x00\x01\x02binary", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := sniffKVContentType([]byte(tt.value)); got != tt.want {
+			t.Errorf("sniffKVContentType(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestServeKVValue(t *testing.T) {
+	serve := func(query, rangeHeader, value string, maxBytes int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/kv/chain-outputs/mychain.scan"+query, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		entry := fakeKVEntry{op: jetstream.KeyValuePut, value: []byte(value)}
+		serveKVValue(w, req, "mychain.scan", entry, []byte(value), false, maxBytes)
+		return w
+	}
+
+	w := serve("", "", "# Findings\n\nNone.", 1024)
+	if ct := w.Header().Get("Content-Type"); ct != "text/markdown; charset=utf-8" || w.Header().Get("ETag") != `"7"` {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+
+	w = serve("?format=json", "", "# Findings", 1024)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected 406 for non-JSON value, got %d", w.Code)
+	}
+
+	w = serve("?format=text", "", `{"a":1}`, 1024)
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected text/plain, got %s", ct)
+	}
+
+	w = serve("?format=text", "", "\xff\xfe\x00", 1024)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected 406 for binary as text, got %d", w.Code)
+	}
+
+	w = serve("?format=yaml", "", `{}`, 1024)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", w.Code)
+	}
+
+	w = serve("?format=json&pretty=true", "", `{"a":{"b":1}}`, 1024)
+	if w.Body.String() != "{\n  \"a\": {\n    \"b\": 1\n  }\n}" {
+		t.Errorf("expected indented JSON, got %q", w.Body.String())
+	}
+
+	if w.Header().Get("ETag") != `W/"7"` {
+		t.Errorf("expected a weak ETag for indented JSON, got %s", w.Header().Get("ETag"))
+	}
+	w = serve("?format=json", "", `{"a":1}`, 1024)
+	if w.Header().Get("ETag") != `"7"` {
+		t.Errorf("expected a strong ETag for the stored bytes, got %s", w.Header().Get("ETag"))
+	}
+
+	// Over the limit: truncated, and ranges cut short at the limit
+	large := strings.Repeat("x", 100)
+	w = serve("", "", large, 10)
+	if w.Code != http.StatusOK || w.Body.Len() != 10 || w.Header().Get("X-Value-Truncated") != "true" ||
+		w.Header().Get("X-Value-Size") != "100" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("expected the first 10 bytes flagged as truncated, got %d with %d bytes %v", w.Code, w.Body.Len(), w.Header())
+	}
+	if w.Header().Get("ETag") != `W/"7"` {
+		t.Errorf("expected a weak ETag on the truncated body, got %s", w.Header().Get("ETag"))
+	}
+	w = serve("", "bytes=0-9", large, 10)
+	if w.Code != http.StatusPartialContent || w.Body.Len() != 10 || w.Header().Get("ETag") != `"7"` {
+		t.Errorf("expected 10-byte partial content with the strong ETag, got %d with %d bytes %v", w.Code, w.Body.Len(), w.Header())
+	}
+
+	// Continuing from a truncated response with its weak ETag as If-Range
+	req := httptest.NewRequest("GET", "/api/kv/chain-outputs/mychain.scan", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	req.Header.Set("If-Range", `W/"7"`)
+	w = httptest.NewRecorder()
+	serveKVValue(w, req, "mychain.scan", fakeKVEntry{op: jetstream.KeyValuePut, value: []byte(large)}, []byte(large), false, 10)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "bytes 10-19/100" {
+		t.Errorf("expected the next range served, got %d %v", w.Code, w.Header())
+	}
+	w = serve("", "bytes=0-", large, 10)
+	if w.Code != http.StatusPartialContent || w.Body.Len() != 10 || w.Header().Get("Content-Range") != "bytes 0-9/100" {
+		t.Errorf("expected an open range clamped to the limit, got %d with %d bytes %v", w.Code, w.Body.Len(), w.Header())
+	}
+	w = serve("", "bytes=-50", large, 10)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "bytes 90-99/100" {
+		t.Errorf("expected a suffix range clamped to the limit, got %d %v", w.Code, w.Header())
+	}
+	w = serve("", "bytes=0-4,10-14", large, 10)
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("expected multiple ranges refused over the limit, got %d", w.Code)
+	}
+}
+
+func TestServeKVEntryRedactedETag(t *testing.T) {
+	policy, _ := loadKVPolicy("")
+	req := httptest.NewRequest("GET", "/api/kv/mission-results/op-1", nil)
+	w := httptest.NewRecorder()
+	entry := fakeKVEntry{op: jetstream.KeyValuePut, value: []byte(`{"apiKey":"abc123"}`)}
+	serveKVEntry(w, req, policy, "op-1", entry, 1024)
+	if w.Header().Get("X-Redacted") != "true" || w.Header().Get("ETag") != `W/"7"` {
+		t.Errorf("expected a redacted value with a weak ETag, got %v", w.Header())
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
@@ -106,7 +110,7 @@ type PlanningResult struct {
 
 // TaskEvent represents a NATS task/result/mission/chain event
 type TaskEvent struct {
-	Type      string          `json:"type"` // task, result, mission, chain
+	Type      string          `json:"type"` // task, result, mission, chain, briefing.created
 	Subject   string          `json:"subject"`
 	Data      json.RawMessage `json:"data"`
 	Timestamp time.Time       `json:"timestamp"`
@@ -137,18 +141,26 @@ var validSessionTypes = map[string]bool{"stats": true, "recent": true, "tree": t
 // the knight validates too).
 var validSessionID = regexp.MustCompile(`^[0-9a-fA-F-]{1,64}$`)
 
-// authMiddleware checks the DASHBOARD_API_KEY env var for API-key based auth (#68, #65)
+// authMiddleware checks the DASHBOARD_API_KEY env var for API-key based auth (#68, #65),
+// the named keys in apiKeys, and bearer JWTs from the OIDC provider when
+// oidcAuth is configured. The caller's Identity and role are stored in the
+// request context.
 func authMiddleware(next http.Handler) http.Handler {
 	apiKey := os.Getenv("DASHBOARD_API_KEY")
+	oidc, keys := oidcAuth, apiKeys
+	sharedKey := func(token string) bool {
+		return apiKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) == 1
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// If no API key is configured, auth is disabled (local dev)
-		if apiKey == "" {
-			next.ServeHTTP(w, r)
+		// If no API key, key store or OIDC is configured, auth is disabled (local dev)
+		// and every caller is an admin
+		if apiKey == "" && oidc == nil && keys == nil {
+			next.ServeHTTP(w, r.WithContext(withRole(r.Context(), roleAdmin)))
 			return
 		}
 
-		// Skip auth for health endpoint and static files
-		if r.URL.Path == "/api/health" || !strings.HasPrefix(r.URL.Path, "/api/") {
+		// Skip auth for health probes and static files
+		if probeRequest(r) || !strings.HasPrefix(r.URL.Path, "/api/") {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -162,7 +174,11 @@ func authMiddleware(next http.Handler) http.Handler {
 				http.Error(w, "Invalid request", http.StatusBadRequest)
 				return
 			}
-			if body.ApiKey != apiKey {
+			valid := sharedKey(body.ApiKey)
+			if !valid && keys != nil {
+				_, valid = keys.authenticate(body.ApiKey, time.Now())
+			}
+			if !valid {
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusUnauthorized)
 				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid API key"})
@@ -176,63 +192,63 @@ func authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// WebSocket connections from browsers, which can't set headers,
+		// authenticate with a ticket from POST /api/ws/ticket
+		if ticket := r.URL.Query().Get("ticket"); ticket != "" && r.URL.Path == "/api/ws" {
+			t, ok := wsTickets.redeem(ticket, time.Now())
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired ticket"})
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(t.context(r.Context())))
+			return
+		}
+
 		// Check Authorization header
-		auth := r.Header.Get("Authorization")
-		if auth == "" {
-			// Also check query param for WebSocket connections
-			if qKey := r.URL.Query().Get("api_key"); qKey != "" {
-				auth = "Bearer " + qKey
+		token, hasBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		var identity Identity
+		var role string
+		var check func(time.Time) bool
+		switch {
+		case !hasBearer || token == "":
+		case oidc != nil && looksLikeJWT(token):
+			id, err := oidc.verify(token)
+			if err != nil {
+				slog.Warn("Rejected bearer token", "error", err, "remote", r.RemoteAddr)
+				break
+			}
+			identity = id
+			if id.ExpiresAt != nil {
+				expires := *id.ExpiresAt
+				check = func(now time.Time) bool { return now.Before(expires) }
+			}
+		case sharedKey(token):
+			identity = apiKeyIdentity
+		case keys != nil:
+			if key, ok := keys.authenticate(token, time.Now()); ok {
+				identity, role = apiKeyIdentityFor(key), key.Role
+				check = func(now time.Time) bool { return keys.active(key.Hash, now) }
 			}
 		}
 
-		if !strings.HasPrefix(auth, "Bearer ") || strings.TrimPrefix(auth, "Bearer ") != apiKey {
+		if identity.Method == "" {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
 			return
 		}
 
-		next.ServeHTTP(w, r)
-	})
-}
-
-// rateLimiterT is a simple fixed-window rate limiter (#12): the token bucket
-// refills in full every interval. Note it is global — shared by all clients.
-type rateLimiterT struct {
-	mu       sync.Mutex
-	tokens   int
-	max      int
-	interval time.Duration
-	last     time.Time
-}
-
-func newRateLimiter(max int, interval time.Duration) *rateLimiterT {
-	return &rateLimiterT{tokens: max, max: max, interval: interval, last: time.Now()}
-}
-
-func (rl *rateLimiterT) allow() bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	now := time.Now()
-	elapsed := now.Sub(rl.last)
-	if elapsed >= rl.interval {
-		rl.tokens = rl.max
-		rl.last = now
-	}
-	if rl.tokens <= 0 {
-		return false
-	}
-	rl.tokens--
-	return true
-}
-
-func (rl *rateLimiterT) middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !rl.allow() {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
+		if role == "" {
+			role = roles.roleFor(identity)
 		}
-		next.ServeHTTP(w, r)
+		ctx := withRole(withIdentity(r.Context(), identity), role)
+		if check != nil {
+			ctx = withCredentialCheck(ctx, check)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -244,6 +260,13 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
 
+	// OpenTelemetry tracing, exported over OTLP when an endpoint is configured
+	shutdownTracer, err := initTracing(context.Background())
+	if err != nil {
+		slog.Error("Tracing init failed", "error", err)
+		os.Exit(1)
+	}
+
 	natsURL := envOr("NATS_URL", "nats://nats.database.svc:4222")
 	namespace := envOr("NAMESPACE", "roundtable")
 	port := envOr("PORT", "8080")
@@ -256,7 +279,6 @@ func main() {
 	// give up after ~2min and permanently close the connection — every WS
 	// then flaps "connected/disconnected" and introspect calls fail with
 	// "nats: connection closed" until the pod is manually restarted.
-	var err error
 	nc, err = nats.Connect(natsURL,
 		nats.MaxReconnects(-1), // never give up
 		nats.ReconnectWait(2*time.Second),
@@ -285,6 +307,20 @@ func main() {
 	}
 	slog.Info("NATS connected", "url", natsURL)
 
+	// Audit records go to their own stream; without it they are kept in memory
+	auditRetention, err := time.ParseDuration(envOr("AUDIT_RETENTION", "2160h"))
+	if err != nil || auditRetention <= 0 {
+		slog.Warn("Invalid AUDIT_RETENTION, using 90 days", "value", envOr("AUDIT_RETENTION", ""))
+		auditRetention = 2160 * time.Hour
+	}
+	auditCtx, cancelAudit := context.WithTimeout(context.Background(), 10*time.Second)
+	if log, err := openAuditStream(auditCtx, js, envOr("AUDIT_STREAM", "roundtable_ui_audit"), envOr("AUDIT_SUBJECT", "roundtable-ui.audit"), auditRetention); err != nil {
+		slog.Warn("Audit stream unavailable, keeping audit records in memory", "error", err)
+	} else {
+		audit = log
+	}
+	cancelAudit()
+
 	// Connect to Kubernetes (in-cluster, falling back to kubeconfig for local dev)
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -301,6 +337,7 @@ func main() {
 		}
 	}
 	if err == nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper { return tracingTransport{next: rt} })
 		// Assign globals only on success — a typed-nil client would defeat the
 		// k8sClient != nil availability checks in handlers
 		if cs, csErr := kubernetes.NewForConfig(config); csErr != nil {
@@ -316,14 +353,96 @@ func main() {
 		}
 	}
 
-	// Simple rate limiter (#12)
-	rateLimiter := newRateLimiter(100, time.Second) // 100 req/s
+	// Warm pool sampler — feeds /roundtables/{name}/warmpool/history and the
+	// roundtable_ui_warmpool_knights gauges
+	sampleInterval, err := time.ParseDuration(envOr("WARMPOOL_SAMPLE_INTERVAL", "30s"))
+	if err != nil || sampleInterval <= 0 {
+		slog.Warn("Invalid WARMPOOL_SAMPLE_INTERVAL, using 30s", "value", envOr("WARMPOOL_SAMPLE_INTERVAL", ""))
+		sampleInterval = 30 * time.Second
+	}
+	historySize, err := strconv.Atoi(envOr("WARMPOOL_HISTORY_SIZE", "2880")) // 24h at 30s
+	if err != nil || historySize <= 0 {
+		historySize = 2880
+	}
+	warmPools := newWarmPoolSampler(sampleInterval, historySize)
+	samplerCtx, stopSampler := context.WithCancel(context.Background())
+	defer stopSampler()
+	go warmPools.run(samplerCtx, namespace)
+
+	// KV browser access policy — buckets/keys exposed, roles, redaction
+	kvAccess, err := loadKVPolicy(envOr("KV_POLICY_FILE", ""))
+	if err != nil {
+		slog.Error("KV policy load failed", "error", err)
+		os.Exit(1)
+	}
+
+	// Bearer JWT validation against the OIDC provider (nil when OIDC_ISSUER is unset)
+	oidcAuth, err = loadOIDCVerifier()
+	if err != nil {
+		slog.Error("OIDC configuration invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Named API keys, reloaded when the file changes
+	apiKeys, err = loadAPIKeyStore()
+	if err != nil {
+		slog.Error("API key store invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Roles for API-key and OIDC callers, enforced per route by rbacMiddleware
+	roles, err = loadRoleMapping(envOr("RBAC_API_KEY_ROLE", roleAdmin), envOr("RBAC_GROUP_ROLES", ""), envOr("RBAC_DEFAULT_ROLE", roleViewer))
+	if err != nil {
+		slog.Error("RBAC configuration invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Vault folders served under /briefings/collections
+	briefingCollections, err := loadBriefingCollections(envOr("BRIEFING_COLLECTIONS", ""))
+	if err != nil {
+		slog.Error("Briefing collections invalid", "error", err)
+		os.Exit(1)
+	}
+	// Vault folders POST /vault/notes and saved mission reports may write to
+	vaultWriteDirs, err := loadVaultWriteDirs(envOr("VAULT_WRITE_DIRS", "Notes,Missions"))
+	if err != nil {
+		slog.Error("VAULT_WRITE_DIRS invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Components whose failure takes the pod out of rotation via /api/ready
+	readyRequired, err := parseReadyRequired(envOr("READY_REQUIRED", defaultReadyRequired))
+	if err != nil {
+		slog.Error("READY_REQUIRED invalid", "error", err)
+		os.Exit(1)
+	}
+	readiness := newReadinessChecker(fleetStream, vaultPath, namespace, readyRequired)
+
+	// Announce new briefings as briefing.created on /api/ws
+	go newBriefingWatcher(vaultPath, briefingCollections, uiEvents).run(samplerCtx, briefingPollInterval)
+
+	// Per-client, per-route-class rate limits (#12)
+	rateLimits, err := parseRateLimits(envOr("RATE_LIMITS", ""))
+	if err != nil {
+		slog.Error("RATE_LIMITS invalid", "error", err)
+		os.Exit(1)
+	}
+	rateLimiter := newRateLimiter(rateLimits)
+	// Proxies whose X-Forwarded-For names the client (rate limits, audit)
+	trustedProxies, err = parseTrustedProxies(envOr("TRUSTED_PROXIES", ""))
+	if err != nil {
+		slog.Error("TRUSTED_PROXIES invalid", "error", err)
+		os.Exit(1)
+	}
 
 	// Router
 	r := mux.NewRouter()
+	r.Use(tracingMiddleware)
 	r.Use(metricsMiddleware)
 	r.Use(authMiddleware)
 	r.Use(rateLimiter.middleware)
+	r.Use(auditMiddleware)
+	r.Use(rbacMiddleware)
 
 	// Prometheus metrics — root level so it stays outside /api auth
 	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
@@ -334,6 +453,9 @@ func main() {
 	api.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) {
 		// Handled by authMiddleware
 	}).Methods("POST")
+	api.HandleFunc("/auth/me", authMeHandler).Methods("GET")
+	api.HandleFunc("/auth/keys", apiKeysHandler).Methods("GET")
+	api.HandleFunc("/audit", auditHandler).Methods("GET")
 
 	// Fleet endpoints
 	api.HandleFunc("/fleet", fleetHandler(namespace)).Methods("GET")
@@ -354,23 +476,52 @@ func main() {
 	api.HandleFunc("/missions/{name}", missionDetailHandler(namespace)).Methods("GET")
 	api.HandleFunc("/missions", missionCreateHandler(namespace)).Methods("POST")
 	api.HandleFunc("/missions/{name}", missionDeleteHandler(namespace)).Methods("DELETE")
+	api.HandleFunc("/missions/{name}/timeline", missionTimelineHandler(namespace, fleetPrefix)).Methods("GET")
+	api.HandleFunc("/missions/{name}/events", missionEventsHandler(namespace, fleetPrefix)).Methods("GET")
+	api.HandleFunc("/missions/{name}/plan", missionPlanHandler(namespace)).Methods("GET")
+	api.HandleFunc("/missions/{name}/plan/{decision}", missionPlanDecisionHandler(namespace)).Methods("POST")
+	api.HandleFunc("/missions/{name}/report", missionReportHandler(namespace, kvAccess)).Methods("GET")
+	api.HandleFunc("/missions/{name}/report", missionReportSaveHandler(namespace, vaultPath, vaultWriteDirs, kvAccess)).Methods("POST")
 
 	// KV endpoints (NATS KV store)
-	api.HandleFunc("/missions/{name}/results", missionResultsHandler()).Methods("GET")
-	api.HandleFunc("/chains/{name}/steps/{step}/output", chainStepOutputHandler()).Methods("GET")
-	api.HandleFunc("/kv/{bucket}/keys", kvKeysHandler()).Methods("GET")
-	api.HandleFunc("/kv/{bucket}/{key}", kvGetHandler()).Methods("GET")
+	api.HandleFunc("/missions/{name}/results", missionResultsHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/chains/{name}/steps/{step}/output", chainStepOutputHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv", kvBucketsHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}", kvBucketHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/keys", kvKeysHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/watch", kvWatchHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/{key}", kvGetHandler(kvAccess)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/{key}", kvPutHandler(kvAccess)).Methods("PUT")
+	api.HandleFunc("/kv/{bucket}/{key}", kvDeleteHandler(kvAccess)).Methods("DELETE")
+	api.HandleFunc("/kv/{bucket}/{key}/history", kvHistoryHandler(kvAccess)).Methods("GET")
 
 	// RoundTable endpoints
 	api.HandleFunc("/roundtables", roundTablesHandler(namespace)).Methods("GET")
 	api.HandleFunc("/roundtables/{name}", roundTableDetailHandler(namespace)).Methods("GET")
+	api.HandleFunc("/roundtables", roundTableCreateHandler(namespace)).Methods("POST")
+	api.HandleFunc("/roundtables/{name}", roundTablePatchHandler(namespace)).Methods("PATCH")
+	api.HandleFunc("/roundtables/{name}/{action:suspend|resume}", roundTableSuspendHandler(namespace)).Methods("POST")
+	api.HandleFunc("/roundtables/{name}/members", roundTableMembersHandler(namespace)).Methods("GET")
+	api.HandleFunc("/roundtables/{name}/warmpool", warmPoolSizeHandler(namespace)).Methods("PATCH")
+	api.HandleFunc("/roundtables/{name}/warmpool/history", warmPoolHistoryHandler(namespace, warmPools)).Methods("GET")
 
 	// Briefing endpoints
 	api.HandleFunc("/briefings", briefingListHandler(vaultPath)).Methods("GET")
+	api.HandleFunc("/briefings/search", briefingSearchHandler(newBriefingIndex(filepath.Join(vaultPath, "Briefings", "Daily")))).Methods("GET")
+	api.HandleFunc("/briefings/feed.atom", briefingFeedHandler(vaultPath)).Methods("GET")
+	api.HandleFunc("/briefings/rollup", briefingRollupHandler(vaultPath)).Methods("GET")
+	api.HandleFunc("/briefings/rollup", briefingRollupDispatchHandler(vaultPath, fleetPrefix, briefingCollections)).Methods("POST")
+	api.HandleFunc("/briefings/rollup/{task_id}", briefingRollupStatusHandler).Methods("GET")
+	api.HandleFunc("/briefings/collections", briefingCollectionsHandler(vaultPath, briefingCollections)).Methods("GET")
+	api.HandleFunc("/briefings/collections/{collection}", briefingCollectionListHandler(vaultPath, briefingCollections)).Methods("GET")
+	api.HandleFunc("/briefings/collections/{collection}/{entry}", briefingCollectionEntryHandler(vaultPath, briefingCollections)).Methods("GET")
 	api.HandleFunc("/briefings/{date}", briefingHandler(vaultPath)).Methods("GET")
+	api.HandleFunc("/briefings/{date}/notes", briefingNoteHandler(vaultPath)).Methods("POST")
+	api.HandleFunc("/vault/notes", vaultNoteHandler(vaultPath, vaultWriteDirs)).Methods("POST")
 
 	// WebSocket for real-time NATS events
-	api.HandleFunc("/ws", wsHandler(fleetPrefix))
+	api.HandleFunc("/ws", wsHandler(fleetPrefix, rateLimiter))
+	api.HandleFunc("/ws/ticket", wsTicketHandler).Methods("POST")
 
 	// Config endpoint to expose fleet prefix to frontend (#60)
 	api.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
@@ -380,19 +531,19 @@ func main() {
 		})
 	}).Methods("GET")
 
-	// Health
-	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	}).Methods("GET")
+	// Health (liveness) and readiness with dependency checks
+	api.HandleFunc("/health", healthHandler(readiness)).Methods("GET")
+	api.HandleFunc("/health/dependencies", dependenciesHandler(readiness)).Methods("GET")
+	api.HandleFunc("/ready", readyHandler(readiness)).Methods("GET")
 
 	// Serve static UI files with SPA fallback
 	r.PathPrefix("/").HandlerFunc(spaHandler("./static"))
 
 	// CORS — defaults to same-origin (no origins = same-origin only) (#57)
 	corsOpts := cors.Options{
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"ETag", "X-Next-Cursor", "X-Total-Count", "X-Redacted", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: false,
 	}
 	if origins := envOr("ALLOWED_ORIGINS", ""); origins != "" {
@@ -414,6 +565,7 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		srv.Shutdown(ctx)
+		shutdownTracing(ctx, shutdownTracer)
 	}()
 
 	slog.Info("Round Table Dashboard API starting", "port", port)
@@ -869,7 +1021,7 @@ func knightSessionHandler(fleetPrefix string) http.HandlerFunc {
 		// Knight names in NATS are capitalized (e.g., "Galahad")
 		capitalName := capitalizeKnight(name)
 		subject := fmt.Sprintf("%s.introspect.%s", prefix, capitalName)
-		msg, err := nc.Request(subject, payload, timeout)
+		msg, err := natsRequest(r.Context(), subject, "{prefix}.introspect.{knight}", payload, timeout)
 		if err != nil {
 			slog.Warn("Knight introspect timeout", "knight", name, "subject", subject, "error", err)
 			http.Error(w, "Knight introspection timeout", http.StatusGatewayTimeout)
@@ -975,6 +1127,7 @@ func taskDispatchHandler(fleetPrefix string) http.HandlerFunc {
 
 		taskID := fmt.Sprintf("%s-ui-%d", req.Knight, time.Now().UnixMilli())
 		subject := fmt.Sprintf("%s.tasks.%s.%s", fleetPrefix, req.Domain, taskID)
+		setAuditTarget(r, "knights/"+req.Knight+"/tasks/"+taskID)
 
 		payload, _ := json.Marshal(map[string]interface{}{
 			"from":    "ui",
@@ -988,7 +1141,7 @@ func taskDispatchHandler(fleetPrefix string) http.HandlerFunc {
 			},
 		})
 
-		if err := nc.Publish(subject, payload); err != nil {
+		if err := natsPublish(r.Context(), subject, "{prefix}.tasks.{domain}.{task_id}", payload); err != nil {
 			slog.Error("NATS publish error", "error", err)
 			http.Error(w, "Failed to dispatch task", http.StatusInternalServerError)
 			return
@@ -1003,57 +1156,7 @@ func taskDispatchHandler(fleetPrefix string) http.HandlerFunc {
 	}
 }
 
-func briefingListHandler(vaultPath string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		dir := fmt.Sprintf("%s/Briefings/Daily", vaultPath)
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			http.Error(w, "Briefings directory not found", http.StatusNotFound)
-			return
-		}
-
-		briefings := []string{}
-		for _, e := range entries {
-			if !e.IsDir() {
-				briefings = append(briefings, e.Name())
-			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(briefings)
-	}
-}
-
-func briefingHandler(vaultPath string) http.HandlerFunc {
-	allowedDir := filepath.Clean(fmt.Sprintf("%s/Briefings/Daily", vaultPath))
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		date := vars["date"]
-
-		// Sanitize: only allow YYYY-MM-DD format to prevent path traversal
-		if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(date) {
-			http.Error(w, "Invalid date format", http.StatusBadRequest)
-			return
-		}
-
-		path := filepath.Clean(fmt.Sprintf("%s/%s.md", allowedDir, date))
-		if !strings.HasPrefix(path, allowedDir) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
-		content, err := os.ReadFile(path)
-		if err != nil {
-			http.Error(w, "Briefing not found", http.StatusNotFound)
-			return
-		}
-
-		w.Header().Set("Content-Type", "text/markdown")
-		w.Write(content)
-	}
-}
-
-func wsHandler(fleetPrefix string) http.HandlerFunc {
+func wsHandler(fleetPrefix string, rl *rateLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check NATS health BEFORE upgrading (#19). Upgrading first and then
 		// closing makes the client fire onopen (UI shows "Connected") before
@@ -1178,9 +1281,19 @@ func wsHandler(fleetPrefix string) http.HandlerFunc {
 			chainSub = nil
 		}
 
+		// Dashboard-originated events (e.g. briefing.created) from the in-process hub
+		hubEvents, unsubscribeHub := uiEvents.subscribe()
+		go func() {
+			for event := range hubEvents {
+				data, _ := json.Marshal(event)
+				safeWrite(data)
+			}
+		}()
+
 		// Cleanup on exit
 		defer func() {
 			close(done)
+			unsubscribeHub()
 			taskSub.Unsubscribe()
 			resultSub.Unsubscribe()
 			if missionSub != nil {
@@ -1202,8 +1315,9 @@ func wsHandler(fleetPrefix string) http.HandlerFunc {
 			return nil
 		})
 
-		// Ping ticker to detect dead connections
-		ticker := time.NewTicker(30 * time.Second)
+		// Ping ticker to detect dead connections; it also closes the socket
+		// once the caller's credentials are revoked or expire
+		ticker := time.NewTicker(wsAuthRecheckInterval)
 		defer ticker.Stop()
 		go func() {
 			for {
@@ -1211,6 +1325,14 @@ func wsHandler(fleetPrefix string) http.HandlerFunc {
 				case <-done:
 					return
 				case <-ticker.C:
+					if !credentialActive(r, time.Now()) {
+						slog.Info("WS credentials revoked, closing", "user", requestIdentity(r).Name)
+						conn.WriteControl(websocket.CloseMessage,
+							websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "credentials revoked"),
+							time.Now().Add(time.Second))
+						conn.Close()
+						return
+					}
 					writeMu.Lock()
 					conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 					err := conn.WriteMessage(websocket.PingMessage, nil)
@@ -1230,34 +1352,76 @@ func wsHandler(fleetPrefix string) http.HandlerFunc {
 			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 
 			// Client can dispatch tasks via WebSocket too
-			var cmd struct {
-				Action string `json:"action"`
-				Knight string `json:"knight"`
-				Domain string `json:"domain"`
-				Task   string `json:"task"`
-			}
+			var cmd wsCommand
 			if json.Unmarshal(msg, &cmd) == nil && cmd.Action == "dispatch" {
-				// Validate inputs
-				if !validKnightName.MatchString(cmd.Knight) || !validKnightName.MatchString(cmd.Domain) {
-					continue
+				if !wsDispatch(r, rl, fleetPrefix, cmd, msg, safeWrite) {
+					break
 				}
-				if len(cmd.Task) == 0 || len(cmd.Task) > 10000 {
-					continue
-				}
-				taskID := fmt.Sprintf("%s-ws-%d", cmd.Knight, time.Now().UnixMilli())
-				subject := fmt.Sprintf("%s.tasks.%s.%s", fleetPrefix, cmd.Domain, taskID)
-				payload, _ := json.Marshal(map[string]interface{}{
-					"from":    "dashboard-ws",
-					"task_id": taskID,
-					"domain":  cmd.Domain,
-					"task":    cmd.Task,
-				})
-				nc.Publish(subject, payload)
 			}
 		}
 	}
 }
 
+// wsCommand is a command sent by a WebSocket client.
+type wsCommand struct {
+	Action string `json:"action"`
+	Knight string `json:"knight"`
+	Domain string `json:"domain"`
+	Task   string `json:"task"`
+}
+
+// wsDispatch publishes a task dispatched over the WebSocket. Each command
+// spends the same dispatch budget as POST /api/tasks/dispatch; over budget,
+// the client gets an error frame instead. It returns false when the
+// connection's credentials have been revoked and it should close.
+func wsDispatch(r *http.Request, rl *rateLimiter, fleetPrefix string, cmd wsCommand, msg []byte, send func([]byte)) bool {
+	if !credentialActive(r, time.Now()) {
+		return false
+	}
+	if !roleAtLeast(requestRole(r), roleOperator) || !hasScope(requestIdentity(r), "tasks:dispatch") {
+		slog.Warn("WS dispatch denied", "user", requestIdentity(r).Name, "role", requestRole(r))
+		rec := newAuditRecord(r, "task.dispatch", "knights/"+cmd.Knight)
+		rec.Outcome = "denied"
+		audit.record(rec)
+		return true
+	}
+	// Validate inputs before charging the dispatch budget, so malformed
+	// commands don't use it up
+	if !validKnightName.MatchString(cmd.Knight) || !validKnightName.MatchString(cmd.Domain) {
+		return true
+	}
+	if len(cmd.Task) == 0 || len(cmd.Task) > 10000 {
+		return true
+	}
+	if d := rl.take(rateClassDispatch, rateClient(r)); !d.allowed {
+		rateLimitedTotal.WithLabelValues(rateClassDispatch).Inc()
+		frame, _ := json.Marshal(map[string]interface{}{
+			"type":       "error",
+			"action":     "dispatch",
+			"error":      "Rate limit exceeded",
+			"retryAfter": int(math.Ceil(d.retryAfter.Seconds())),
+		})
+		send(frame)
+		return true
+	}
+	taskID := fmt.Sprintf("%s-ws-%d", cmd.Knight, time.Now().UnixMilli())
+	subject := fmt.Sprintf("%s.tasks.%s.%s", fleetPrefix, cmd.Domain, taskID)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"from":    "dashboard-ws",
+		"task_id": taskID,
+		"domain":  cmd.Domain,
+		"task":    cmd.Task,
+	})
+	rec := newAuditRecord(r, "task.dispatch", "knights/"+cmd.Knight+"/tasks/"+taskID)
+	sum := sha256.Sum256(msg)
+	rec.BodySHA256, rec.Outcome = hex.EncodeToString(sum[:]), "success"
+	if err := natsPublish(r.Context(), subject, "{prefix}.tasks.{domain}.{task_id}", payload); err != nil {
+		rec.Outcome = "failed"
+	}
+	audit.record(rec)
+	return true
+}
+
 var (
 	chainGVR = schema.GroupVersionResource{
 		Group:    "ai.roundtable.io",
@@ -1870,6 +2034,7 @@ type WarmPoolStatusDTO struct {
 	Available    int `json:"available"`
 	Provisioning int `json:"provisioning"`
 	Claimed      int `json:"claimed"`
+	Target       int `json:"target,omitempty"` // spec.warmPool.size
 }
 
 // PoliciesDTO represents the policies spec fields from the CRD
@@ -2003,6 +2168,12 @@ func parseRoundTableResource(obj map[string]interface{}) RoundTableSummary {
 			Claimed:      getInt(wp, "claimed"),
 		}
 	}
+	if wp := getNestedMap(spec, "warmPool"); wp != nil {
+		if rt.WarmPool == nil {
+			rt.WarmPool = &WarmPoolStatusDTO{}
+		}
+		rt.WarmPool.Target = getInt(wp, "size")
+	}
 
 	return rt
 }
@@ -2010,6 +2181,7 @@ func parseRoundTableResource(obj map[string]interface{}) RoundTableSummary {
 // --- NATS KV helpers and handlers ---
 
 // getOrCreateKVBucket returns a NATS KV bucket handle, creating it if needed.
+// Only writes use it; reads go through getKVBucket so they can't create buckets.
 func getOrCreateKVBucket(ctx context.Context, bucket string) (jetstream.KeyValue, error) {
 	kv, err := js.KeyValue(ctx, bucket)
 	if err != nil {
@@ -2030,16 +2202,26 @@ func getOrCreateKVBucket(ctx context.Context, bucket string) (jetstream.KeyValue
 // validBucketName matches safe KV bucket names
 var validBucketName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,62}$`)
 
-func missionResultsHandler() http.HandlerFunc {
+// missionResultsHandler serves a mission's entry in the mission-results
+// bucket, under the same KV policy and redaction as the KV browser.
+func missionResultsHandler(policy *kvPolicy) http.HandlerFunc {
+	maxBytes := kvMaxValueBytes()
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := mux.Vars(r)["name"]
 		if !validKnightName.MatchString(name) {
 			http.Error(w, "Invalid mission name", http.StatusBadRequest)
 			return
 		}
-		kv, err := getOrCreateKVBucket(r.Context(), "mission-results")
+		if !policy.authorize(w, r, "mission-results", name, false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+		kv, err := getKVBucket(r.Context(), "mission-results")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			kvError(w, err, "Results not found")
 			return
 		}
 		entry, err := kv.Get(r.Context(), name)
@@ -2047,12 +2229,14 @@ func missionResultsHandler() http.HandlerFunc {
 			http.Error(w, "Results not found", http.StatusNotFound)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(entry.Value())
+		serveKVEntry(w, r, policy, name, entry, maxBytes)
 	}
 }
 
-func chainStepOutputHandler() http.HandlerFunc {
+// chainStepOutputHandler serves a chain step's entry in the chain-outputs
+// bucket, under the same KV policy and redaction as the KV browser.
+func chainStepOutputHandler(policy *kvPolicy) http.HandlerFunc {
+	maxBytes := kvMaxValueBytes()
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		name := vars["name"]
@@ -2061,47 +2245,78 @@ func chainStepOutputHandler() http.HandlerFunc {
 			http.Error(w, "Invalid name", http.StatusBadRequest)
 			return
 		}
-		kv, err := getOrCreateKVBucket(r.Context(), "chain-outputs")
+		key := name + "." + step
+		if !policy.authorize(w, r, "chain-outputs", key, false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+		kv, err := getKVBucket(r.Context(), "chain-outputs")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			kvError(w, err, "Output not found")
 			return
 		}
-		key := name + "." + step
 		entry, err := kv.Get(r.Context(), key)
 		if err != nil {
 			http.Error(w, "Output not found", http.StatusNotFound)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(entry.Value())
+		serveKVEntry(w, r, policy, key, entry, maxBytes)
 	}
 }
 
-func kvKeysHandler() http.HandlerFunc {
+func kvKeysHandler(policy *kvPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bucket := mux.Vars(r)["bucket"]
 		if !validBucketName.MatchString(bucket) {
 			http.Error(w, "Invalid bucket name", http.StatusBadRequest)
 			return
 		}
-		kv, err := getOrCreateKVBucket(r.Context(), bucket)
+		// ?prefix= filters, ?limit= and ?cursor= page through the sorted keys;
+		// the body stays a plain array and the next cursor comes back in
+		// X-Next-Cursor (absent on the last page)
+		q := r.URL.Query()
+		limit := defaultKVKeysLimit
+		if l := q.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 || n > maxKVKeysLimit {
+				http.Error(w, fmt.Sprintf("limit must be 1-%d", maxKVKeysLimit), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		if !policy.authorize(w, r, bucket, "", false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+		kv, err := getKVBucket(r.Context(), bucket)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			kvError(w, err, "Bucket not found")
 			return
 		}
-		keys, err := kv.Keys(r.Context())
+		keys, err := listKVKeys(r.Context(), kv, q.Get("prefix"), policy)
 		if err != nil {
-			// No keys found
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode([]string{})
+			kvError(w, err, "Bucket not found")
 			return
 		}
+		total := len(keys)
+		page, next := paginateKeys(keys, q.Get("cursor"), limit)
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(keys)
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		if next != "" {
+			w.Header().Set("X-Next-Cursor", next)
+		}
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
-func kvGetHandler() http.HandlerFunc {
+func kvGetHandler(policy *kvPolicy) http.HandlerFunc {
+	maxBytes := kvMaxValueBytes()
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		bucket := vars["bucket"]
@@ -2110,9 +2325,20 @@ func kvGetHandler() http.HandlerFunc {
 			http.Error(w, "Invalid bucket name", http.StatusBadRequest)
 			return
 		}
-		kv, err := getOrCreateKVBucket(r.Context(), bucket)
+		if !validKVKey.MatchString(key) {
+			http.Error(w, "Invalid key", http.StatusBadRequest)
+			return
+		}
+		if !policy.authorize(w, r, bucket, key, false) {
+			return
+		}
+		if js == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+		kv, err := getKVBucket(r.Context(), bucket)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			kvError(w, err, "Key not found")
 			return
 		}
 		entry, err := kv.Get(r.Context(), key)
@@ -2120,8 +2346,7 @@ func kvGetHandler() http.HandlerFunc {
 			http.Error(w, "Key not found", http.StatusNotFound)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(entry.Value())
+		serveKVEntry(w, r, policy, key, entry, maxBytes)
 	}
 }
 
@@ -24,11 +24,18 @@ import (
 // Global variable to hold fake client for tests
 var fakeK8sClient *k8sfake.Clientset
 
+// testWarmPools is the sampler behind the test router's warm pool history route
+var testWarmPools *warmPoolSampler
+
+// testKVPolicy is the KV access policy used by the test router's KV routes
+var testKVPolicy, _ = loadKVPolicy("")
+
 // setupTestRouter creates a test router with mocked dependencies
 func setupTestRouter() *mux.Router {
 	// Initialize mock K8s client and inject it into the handlers' global
 	fakeK8sClient = k8sfake.NewSimpleClientset()
 	k8sClient = fakeK8sClient
+	testWarmPools = newWarmPoolSampler(time.Minute, 3)
 
 	// Initialize mock dynamic client with proper scheme
 	scheme := runtime.NewScheme()
@@ -72,12 +79,14 @@ func setupTestRouter() *mux.Router {
 	
 	// Create router with test handlers
 	r := mux.NewRouter()
+	// With no credentials configured authMiddleware makes every caller an admin
+	r.Use(authMiddleware)
 	api := r.PathPrefix("/api").Subrouter()
 	
 	namespace := "test-namespace"
 	fleetPrefix := "fleet-a"
 	
-	// Register handlers (without auth middleware for testing)
+	// Register handlers
 	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 	}).Methods("GET")
@@ -95,9 +104,28 @@ func setupTestRouter() *mux.Router {
 	api.HandleFunc("/missions/{name}", missionDetailHandler(namespace)).Methods("GET")
 	api.HandleFunc("/missions", missionCreateHandler(namespace)).Methods("POST")
 	api.HandleFunc("/missions/{name}", missionDeleteHandler(namespace)).Methods("DELETE")
+	api.HandleFunc("/missions/{name}/timeline", missionTimelineHandler(namespace, fleetPrefix)).Methods("GET")
+	api.HandleFunc("/missions/{name}/events", missionEventsHandler(namespace, fleetPrefix)).Methods("GET")
+	api.HandleFunc("/missions/{name}/plan", missionPlanHandler(namespace)).Methods("GET")
+	api.HandleFunc("/missions/{name}/plan/{decision}", missionPlanDecisionHandler(namespace)).Methods("POST")
+	
+	api.HandleFunc("/kv", kvBucketsHandler(testKVPolicy)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}", kvBucketHandler(testKVPolicy)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/keys", kvKeysHandler(testKVPolicy)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/watch", kvWatchHandler(testKVPolicy)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/{key}", kvGetHandler(testKVPolicy)).Methods("GET")
+	api.HandleFunc("/kv/{bucket}/{key}", kvPutHandler(testKVPolicy)).Methods("PUT")
+	api.HandleFunc("/kv/{bucket}/{key}", kvDeleteHandler(testKVPolicy)).Methods("DELETE")
+	api.HandleFunc("/kv/{bucket}/{key}/history", kvHistoryHandler(testKVPolicy)).Methods("GET")
 	
 	api.HandleFunc("/roundtables", roundTablesHandler(namespace)).Methods("GET")
 	api.HandleFunc("/roundtables/{name}", roundTableDetailHandler(namespace)).Methods("GET")
+	api.HandleFunc("/roundtables", roundTableCreateHandler(namespace)).Methods("POST")
+	api.HandleFunc("/roundtables/{name}", roundTablePatchHandler(namespace)).Methods("PATCH")
+	api.HandleFunc("/roundtables/{name}/{action:suspend|resume}", roundTableSuspendHandler(namespace)).Methods("POST")
+	api.HandleFunc("/roundtables/{name}/members", roundTableMembersHandler(namespace)).Methods("GET")
+	api.HandleFunc("/roundtables/{name}/warmpool", warmPoolSizeHandler(namespace)).Methods("PATCH")
+	api.HandleFunc("/roundtables/{name}/warmpool/history", warmPoolHistoryHandler(namespace, testWarmPools)).Methods("GET")
 	
 	api.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -922,32 +950,6 @@ func TestRoundTablesHandler(t *testing.T) {
 	}
 }
 
-// TestRateLimiter tests the rate limiter
-func TestRateLimiter(t *testing.T) {
-	rl := newRateLimiter(2, 1*time.Second)
-	
-	// First two requests should succeed
-	if !rl.allow() {
-		t.Error("first request should be allowed")
-	}
-	if !rl.allow() {
-		t.Error("second request should be allowed")
-	}
-	
-	// Third request should be blocked
-	if rl.allow() {
-		t.Error("third request should be blocked")
-	}
-	
-	// Wait for window to reset
-	time.Sleep(1 * time.Second)
-	
-	// Should allow again
-	if !rl.allow() {
-		t.Error("request after reset should be allowed")
-	}
-}
-
 // TestCapitalizeKnight tests the knight name capitalization
 func TestCapitalizeKnight(t *testing.T) {
 	tests := []struct {
@@ -21,6 +21,16 @@ var (
 		Help:    "HTTP request latency, by route template and method.",
 		Buckets: []float64{0.005, 0.025, 0.1, 0.5, 1, 5, 30},
 	}, []string{"route", "method"})
+
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "roundtable_ui_rate_limited_total",
+		Help: "Requests rejected with 429, by rate limit class (read, dispatch, write, ws).",
+	}, []string{"class"})
+
+	warmPoolKnights = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "roundtable_ui_warmpool_knights",
+		Help: "Warm pool knights per RoundTable, by state (available, provisioning, claimed, target).",
+	}, []string{"roundtable", "state"})
 )
 
 // statusRecorder captures the response status code for metrics labels.
@@ -34,6 +44,12 @@ func (sr *statusRecorder) WriteHeader(code int) {
 	sr.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer's Flush —
+// SSE streams must flush each event through the recorder.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
 // routeLabel returns the mux route template (e.g. /api/fleet/{knight}) so
 // metric cardinality stays bounded; non-API paths collapse to "static".
 func routeLabel(r *http.Request) string {
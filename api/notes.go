@@ -0,0 +1,381 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"sigs.k8s.io/yaml"
+)
+
+// maxNoteBytes caps a note or annotation body.
+const maxNoteBytes = 1 << 20
+
+var validTaskID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// vaultWriteMu serializes the dashboard's own read-check-write cycles so two
+// requests can't both pass the same If-Match. Edits made in Obsidian are
+// caught by re-checking the ETag right before the atomic rename.
+var vaultWriteMu sync.Mutex
+
+// contentETag is a strong ETag over a note's bytes.
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatchSatisfied reports whether the If-Match header (if any) matches the
+// current ETag. exists is false when the file is missing, which only
+// satisfies an absent header.
+func ifMatchSatisfied(r *http.Request, etag string, exists bool) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	if !exists {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// loadVaultWriteDirs parses VAULT_WRITE_DIRS ("Notes,Incidents/2024"), the
+// vault-relative folders POST /vault/notes (and, when Missions is listed,
+// POST /missions/{name}/report) may write under.
+func loadVaultWriteDirs(spec string) ([]string, error) {
+	var dirs []string
+	for _, dir := range strings.Split(spec, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") || strings.HasPrefix(dir, ".") {
+			return nil, fmt.Errorf("vault write dir %q must be a folder inside the vault", dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// resolveVaultWritePath maps a requested folder and note name to an absolute
+// path, requiring the folder to be one of (or beneath one of) allowed.
+func resolveVaultWritePath(vaultPath string, allowed []string, dir, name string) (string, string, error) {
+	if !validNoteName.MatchString(name) || strings.Contains(name, "..") {
+		return "", "", fmt.Errorf("invalid note name")
+	}
+	rel := filepath.ToSlash(filepath.Clean(dir))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", "", fmt.Errorf("dir must be inside the vault")
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", "", fmt.Errorf("dir may not contain hidden folders")
+		}
+	}
+	for _, base := range allowed {
+		if rel == base || strings.HasPrefix(rel, base+"/") {
+			relPath := rel + "/" + name + ".md"
+			root := filepath.Clean(vaultPath)
+			path := filepath.Clean(filepath.Join(root, filepath.FromSlash(relPath)))
+			if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+				return "", "", fmt.Errorf("dir must be inside the vault")
+			}
+			return path, relPath, nil
+		}
+	}
+	return "", "", fmt.Errorf("dir %q is not writable (allowed: %s)", rel, strings.Join(allowed, ", "))
+}
+
+// appendBriefingNote adds a timestamped bullet to the briefing's "## Notes"
+// section, creating the section at the end if it doesn't exist.
+func appendBriefingNote(content []byte, text, author string, at time.Time) []byte {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	bulletLines := strings.Split(strings.TrimSpace(text), "\n")
+	bullet := fmt.Sprintf("- **%s** (%s): %s", at.UTC().Format("2006-01-02 15:04 UTC"), author, bulletLines[0])
+	for _, line := range bulletLines[1:] {
+		bullet += "\n  " + line
+	}
+
+	notes, end := -1, len(lines)
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil && len(m[1]) <= 2 {
+			if notes >= 0 {
+				end = i
+				break
+			}
+			if len(m[1]) == 2 && strings.EqualFold(m[2], "Notes") {
+				notes = i
+			}
+		}
+	}
+	if notes < 0 {
+		return []byte(strings.Join(lines, "\n") + "\n\n## Notes\n\n" + bullet + "\n")
+	}
+	// Insert after the last non-blank line of the Notes section
+	insert := end
+	for insert > notes+1 && strings.TrimSpace(lines[insert-1]) == "" {
+		insert--
+	}
+	out := append([]string{}, lines[:insert]...)
+	if insert == notes+1 {
+		out = append(out, "")
+	}
+	out = append(out, bullet)
+	if end < len(lines) {
+		out = append(out, "")
+		out = append(out, lines[end:]...)
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// writeNoteChecked re-reads path under vaultWriteMu and writes data only if
+// the file's ETag is still expected ("" meaning the file must not exist).
+func writeNoteChecked(path, expected string, data []byte) (bool, error) {
+	current, err := os.ReadFile(path)
+	switch {
+	case err == nil && contentETag(current) != expected:
+		return false, nil
+	case os.IsNotExist(err) && expected != "":
+		return false, nil
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(path, data, 0644)
+}
+
+// noteAuthor is who a note is attributed to: the caller's name, or subject
+// when the identity has none. A claimed author (the body's "author") must
+// be that same name, so notes can't be attributed to someone else.
+func noteAuthor(r *http.Request, claimed string) (string, error) {
+	id := requestIdentity(r)
+	author := id.Name
+	if author == "" {
+		author = id.Subject
+	}
+	// The name lands in Markdown; keep it on one line and out of the markup
+	author = strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) || strings.ContainsRune("*`[]<>|\\", c) {
+			return -1
+		}
+		return c
+	}, author)
+	if author == "" {
+		return "", fmt.Errorf("caller has no name to attribute the note to")
+	}
+	if claimed != "" && claimed != author {
+		return "", fmt.Errorf("author must be the caller (%s)", author)
+	}
+	return author, nil
+}
+
+// briefingNoteHandler serves POST /briefings/{date}/notes with
+// {"text": "..."}, attributed to the caller. Send the ETag from GET
+// /briefings/{date} as If-Match to fail with 412 if the briefing changed.
+func briefingNoteHandler(vaultPath string) http.HandlerFunc {
+	allowedDir := filepath.Clean(fmt.Sprintf("%s/Briefings/Daily", vaultPath))
+	return func(w http.ResponseWriter, r *http.Request) {
+		date := mux.Vars(r)["date"]
+		// Sanitize: only allow YYYY-MM-DD format to prevent path traversal
+		if !validBriefingDate.MatchString(date) {
+			http.Error(w, "Invalid date format", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Text   string `json:"text"`
+			Author string `json:"author,omitempty"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNoteBytes)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Text) == "" || len(req.Text) > 10000 {
+			http.Error(w, "Text must be 1-10000 characters", http.StatusBadRequest)
+			return
+		}
+		author, err := noteAuthor(r, req.Author)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		path := filepath.Clean(fmt.Sprintf("%s/%s.md", allowedDir, date))
+		if !strings.HasPrefix(path, allowedDir) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		vaultWriteMu.Lock()
+		defer vaultWriteMu.Unlock()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, "Briefing not found", http.StatusNotFound)
+			return
+		}
+		etag := contentETag(content)
+		if !ifMatchSatisfied(r, etag, true) {
+			w.Header().Set("ETag", etag)
+			http.Error(w, "Briefing changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+
+		updated := appendBriefingNote(content, req.Text, author, time.Now())
+		ok, err := writeNoteChecked(path, etag, updated)
+		if err != nil {
+			slog.Error("Vault write error", "path", path, "error", err)
+			http.Error(w, "Failed to write briefing", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Briefing changed while writing", http.StatusPreconditionFailed)
+			return
+		}
+
+		w.Header().Set("ETag", contentETag(updated))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"path": "Briefings/Daily/" + date + ".md",
+			"etag": contentETag(updated),
+		})
+	}
+}
+
+// vaultNoteRequest is the body of POST /vault/notes. Title, tags and taskId
+// become front-matter, so a note made from a task result links back to it;
+// the author is always the caller.
+type vaultNoteRequest struct {
+	Dir     string   `json:"dir"`
+	Name    string   `json:"name"`
+	Content string   `json:"content"`
+	Title   string   `json:"title,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	TaskID  string   `json:"taskId,omitempty"`
+	Author  string   `json:"author,omitempty"`
+}
+
+// render produces the note's Markdown.
+func (req vaultNoteRequest) render(at time.Time) []byte {
+	fm := map[string]interface{}{}
+	if req.Title != "" {
+		fm["title"] = req.Title
+	}
+	if len(req.Tags) > 0 {
+		fm["tags"] = req.Tags
+	}
+	if req.TaskID != "" {
+		fm["task_id"] = req.TaskID
+	}
+	body := strings.TrimRight(req.Content, "\n") + "\n"
+	if len(fm) == 0 {
+		return []byte(body)
+	}
+	fm["author"] = req.Author
+	fm["created"] = at.UTC().Format(time.RFC3339)
+	header, _ := yaml.Marshal(fm)
+	return []byte(fmt.Sprintf("---\n%s---\n%s", header, body))
+}
+
+// vaultNoteHandler serves POST /vault/notes: create a note under one of the
+// VAULT_WRITE_DIRS. An existing note is only replaced when If-Match carries
+// its current ETag (409 without, 412 on mismatch).
+func vaultNoteHandler(vaultPath string, writeDirs []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(writeDirs) == 0 {
+			http.Error(w, "Vault writes are disabled (set VAULT_WRITE_DIRS)", http.StatusForbidden)
+			return
+		}
+		var req vaultNoteRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNoteBytes+4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Content) == "" || len(req.Content) > maxNoteBytes {
+			http.Error(w, fmt.Sprintf("Content must be 1-%d bytes", maxNoteBytes), http.StatusBadRequest)
+			return
+		}
+		author, err := noteAuthor(r, req.Author)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Author = author
+		if req.TaskID != "" && !validTaskID.MatchString(req.TaskID) {
+			http.Error(w, "Invalid taskId", http.StatusBadRequest)
+			return
+		}
+		path, relPath, err := resolveVaultWritePath(vaultPath, writeDirs, req.Dir, req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vaultWriteMu.Lock()
+		defer vaultWriteMu.Unlock()
+		expected := ""
+		if current, err := os.ReadFile(path); err == nil {
+			expected = contentETag(current)
+			if r.Header.Get("If-Match") == "" {
+				w.Header().Set("ETag", expected)
+				http.Error(w, "Note already exists; send If-Match with its ETag to replace it", http.StatusConflict)
+				return
+			}
+		}
+		if !ifMatchSatisfied(r, expected, expected != "") {
+			if expected != "" {
+				w.Header().Set("ETag", expected)
+			}
+			http.Error(w, "Note changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+
+		data := req.render(time.Now())
+		ok, err := writeNoteChecked(path, expected, data)
+		if err != nil {
+			slog.Error("Vault write error", "path", path, "error", err)
+			http.Error(w, "Failed to write note", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Note changed while writing", http.StatusPreconditionFailed)
+			return
+		}
+
+		status := http.StatusCreated
+		if expected != "" {
+			status = http.StatusOK
+		}
+		w.Header().Set("ETag", contentETag(data))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"path": relPath,
+			"etag": contentETag(data),
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAppendBriefingNote(t *testing.T) {
+	at := time.Date(2024, 1, 2, 14, 3, 0, 0, time.UTC)
+
+	got := string(appendBriefingNote([]byte("# Day\n\nAll quiet.\n"), "incident caused by galahad", "ops", at))
+	want := "# Day\n\nAll quiet.\n\n## Notes\n\n- **2024-01-02 14:03 UTC** (ops): incident caused by galahad\n"
+	if got != want {
+		t.Errorf("new Notes section:\n%q\nwant\n%q", got, want)
+	}
+
+	// Existing Notes section followed by another section: the note lands at
+	// the end of Notes, multi-line text is indented under the bullet
+	existing := "# Day\n\n## Notes\n\n- earlier\n\n## Next\n\nTomorrow.\n"
+	got = string(appendBriefingNote([]byte(existing), "line one\nline two", "ops", at))
+	want = "# Day\n\n## Notes\n\n- earlier\n- **2024-01-02 14:03 UTC** (ops): line one\n  line two\n\n## Next\n\nTomorrow.\n"
+	if got != want {
+		t.Errorf("existing Notes section:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestResolveVaultWritePath(t *testing.T) {
+	vault := t.TempDir()
+	allowed, err := loadVaultWriteDirs("Notes, Incidents/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, rel, err := resolveVaultWritePath(vault, allowed, "Notes/Tasks", "result-1"); err != nil || rel != "Notes/Tasks/result-1.md" {
+		t.Errorf("expected nested write allowed, got %s %v", rel, err)
+	}
+	for _, tt := range []struct{ dir, name string }{
+		{"Notes/../Secrets", "x"},
+		{"../Notes", "x"},
+		{"/etc", "x"},
+		{"NotesExtra", "x"},
+		{"Incidents", "x"},
+		{"Notes/.obsidian", "x"},
+		{"Notes", "../x"},
+		{"Notes", "a/b"},
+		{"Notes", ""},
+	} {
+		if _, _, err := resolveVaultWritePath(vault, allowed, tt.dir, tt.name); err == nil {
+			t.Errorf("expected %s/%s to be rejected", tt.dir, tt.name)
+		}
+	}
+
+	for _, bad := range []string{"..", "../x", "/abs", ".obsidian"} {
+		if _, err := loadVaultWriteDirs(bad); err == nil {
+			t.Errorf("expected VAULT_WRITE_DIRS %q to be rejected", bad)
+		}
+	}
+}
+
+func TestNoteAuthor(t *testing.T) {
+	tests := []struct {
+		id              Identity
+		claimed, author string
+		ok              bool
+	}{
+		{anonymousIdentity, "", "dashboard", true},
+		{Identity{Subject: "u-1", Name: "arthur", Method: "oidc"}, "", "arthur", true},
+		{Identity{Subject: "u-1", Name: "arthur", Method: "oidc"}, "arthur", "arthur", true},
+		{Identity{Subject: "u-1", Name: "arthur", Method: "oidc"}, "lancelot", "", false},
+		{Identity{Subject: "u-2", Method: "oidc"}, "", "u-2", true},
+		{Identity{Subject: "u-3", Name: "**King**\nArthur", Method: "oidc"}, "", "KingArthur", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/vault/notes", nil)
+		req = req.WithContext(withIdentity(req.Context(), tt.id))
+		author, err := noteAuthor(req, tt.claimed)
+		if (err == nil) != tt.ok || author != tt.author {
+			t.Errorf("noteAuthor(%+v, %q) = %q, %v; want %q", tt.id, tt.claimed, author, err, tt.author)
+		}
+	}
+}
+
+func TestVaultNoteHandlers(t *testing.T) {
+	vault := t.TempDir()
+	daily := filepath.Join(vault, "Briefings", "Daily")
+	writeTestBriefing(t, daily, "2024-01-02.md", "# Day\n")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings/{date}", briefingHandler(vault)).Methods("GET")
+	router.HandleFunc("/api/briefings/{date}/notes", briefingNoteHandler(vault)).Methods("POST")
+	router.HandleFunc("/api/vault/notes", vaultNoteHandler(vault, []string{"Notes"})).Methods("POST")
+
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Annotate with the ETag from GET; a stale ETag is rejected
+	etag := do("GET", "/api/briefings/2024-01-02", "", "").Header().Get("ETag")
+	w := do("POST", "/api/briefings/2024-01-02/notes", `{"text":"incident caused by galahad","author":"ops"}`, etag)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a note attributed to someone else rejected, got %d", w.Code)
+	}
+	w = do("POST", "/api/briefings/2024-01-02/notes", `{"text":"incident caused by galahad","author":"dashboard"}`, etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected note appended with new ETag, got %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(daily, "2024-01-02.md"))
+	if !strings.Contains(string(content), "(dashboard): incident caused by galahad") {
+		t.Errorf("note missing from briefing:\n%s", content)
+	}
+	if w := do("POST", "/api/briefings/2024-01-02/notes", `{"text":"again"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale ETag, got %d", w.Code)
+	}
+	if w := do("POST", "/api/briefings/2024-01-02/notes", `{"text":"no precondition"}`, ""); w.Code != http.StatusOK {
+		t.Errorf("expected unconditional append, got %d", w.Code)
+	}
+
+	w = do("POST", "/api/vault/notes", `{"dir":"Notes/Tasks","name":"galahad-ui-1","content":"Scan output","title":"Scan","tags":["scan"],"taskId":"galahad-ui-1"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	created := w.Header().Get("ETag")
+	note, _ := os.ReadFile(filepath.Join(vault, "Notes", "Tasks", "galahad-ui-1.md"))
+	fm, body := splitFrontMatter(string(note))
+	if fm["task_id"] != "galahad-ui-1" || fm["title"] != "Scan" || fm["author"] != "dashboard" || body != "Scan output\n" {
+		t.Errorf("unexpected note:\n%s", note)
+	}
+
+	replace := `{"dir":"Notes/Tasks","name":"galahad-ui-1","content":"Updated"}`
+	if w := do("POST", "/api/vault/notes", replace, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for existing note without If-Match, got %d", w.Code)
+	}
+	if w := do("POST", "/api/vault/notes", replace, `"stale"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale If-Match, got %d", w.Code)
+	}
+	if w := do("POST", "/api/vault/notes", replace, created); w.Code != http.StatusOK {
+		t.Errorf("expected replace with current ETag, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/vault/notes", `{"dir":"Notes","name":"fresh","content":"x"}`, `"abc"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for If-Match on a missing note, got %d", w.Code)
+	}
+
+	statusTests := []struct {
+		path, body string
+		expected   int
+	}{
+		{"/api/briefings/2024-13/notes", `{"text":"x"}`, http.StatusBadRequest},
+		{"/api/briefings/2024-01-03/notes", `{"text":"x"}`, http.StatusNotFound},
+		{"/api/briefings/2024-01-02/notes", `{"text":"  "}`, http.StatusBadRequest},
+		{"/api/briefings/2024-01-02/notes", `{"text":"x","author":"a b"}`, http.StatusBadRequest},
+		{"/api/vault/notes", `{"dir":"Secrets","name":"x","content":"x"}`, http.StatusBadRequest},
+		{"/api/vault/notes", `{"dir":"Notes/../..","name":"x","content":"x"}`, http.StatusBadRequest},
+		{"/api/vault/notes", `{"dir":"Notes","name":"x","content":""}`, http.StatusBadRequest},
+		{"/api/vault/notes", `{"dir":"Notes","name":"x","content":"x","taskId":"../x"}`, http.StatusBadRequest},
+		{"/api/vault/notes", `{"dir":"Notes","name":"x","content":"x","author":"galahad"}`, http.StatusBadRequest},
+	}
+	for _, tt := range statusTests {
+		if w := do("POST", tt.path, tt.body, ""); w.Code != tt.expected {
+			t.Errorf("POST %s %s: expected %d, got %d: %s", tt.path, tt.body, tt.expected, w.Code, w.Body.String())
+		}
+	}
+
+	disabled := httptest.NewRecorder()
+	vaultNoteHandler(vault, nil)(disabled, httptest.NewRequest("POST", "/api/vault/notes", strings.NewReader(`{}`)))
+	if disabled.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with no write dirs, got %d", disabled.Code)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksMaxAge is how long fetched signing keys are trusted before a
+	// refetch, so rotated-out keys stop validating.
+	jwksMaxAge = time.Hour
+	// jwksMaxStale is how long cached keys keep validating while refetches
+	// fail. Past it tokens are rejected, so a key rotated out because it
+	// leaked doesn't outlive the provider being unreachable.
+	jwksMaxStale = 2 * jwksMaxAge
+	// jwksMinRefresh throttles refetches triggered by an unknown kid, so
+	// tokens with made-up kids can't hammer the identity provider.
+	jwksMinRefresh = 30 * time.Second
+	// oidcLeeway tolerates clock skew between us and the identity provider.
+	oidcLeeway = 30 * time.Second
+)
+
+// Identity is the authenticated caller of an API request.
+type Identity struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email,omitempty"`
+	Groups  []string `json:"groups"`
+	Scopes  []string `json:"scopes,omitempty"` // named API keys only; nil means unrestricted
+	Method  string   `json:"method"`           // "oidc", "api-key" or "anonymous"
+
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // when the JWT expires
+}
+
+// Identities for callers that can't be told apart: the shared
+// DASHBOARD_API_KEY, and everyone when auth is disabled.
+var (
+	apiKeyIdentity    = Identity{Subject: "dashboard", Name: "dashboard", Groups: []string{}, Method: "api-key"}
+	anonymousIdentity = Identity{Subject: "dashboard", Name: "dashboard", Groups: []string{}, Method: "anonymous"}
+)
+
+type identityContextKey struct{}
+
+// withIdentity returns a context carrying the caller's identity.
+func withIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, id)
+}
+
+// requestIdentity returns the caller set by authMiddleware, or the anonymous
+// identity when auth is disabled.
+func requestIdentity(r *http.Request) Identity {
+	if id, ok := r.Context().Value(identityContextKey{}).(Identity); ok {
+		return id
+	}
+	return anonymousIdentity
+}
+
+// authMeHandler serves GET /auth/me, telling the caller who the API thinks
+// they are and which role they hold.
+func authMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Identity
+		Role string `json:"role"`
+	}{requestIdentity(r), requestRole(r)})
+}
+
+// oidcAuth validates bearer JWTs in authMiddleware; nil unless OIDC_ISSUER
+// is set.
+var oidcAuth *oidcVerifier
+
+// oidcVerifier checks ID/access tokens issued by one OIDC provider against
+// its published signing keys.
+type oidcVerifier struct {
+	issuer        string
+	audience      string
+	jwksURL       string // from discovery when not configured
+	usernameClaim string
+	groupsClaim   string
+	client        *http.Client
+	parser        *jwt.Parser
+
+	mu       sync.Mutex
+	keys     map[string]interface{} // kid -> *rsa.PublicKey / *ecdsa.PublicKey
+	loaded   time.Time              // when keys were last fetched
+	fetched  time.Time              // the last fetch attempt, successful or not
+	inflight *jwksFetch             // the refetch other requests wait on, if any
+}
+
+// jwksFetch is one JWKS refetch; done is closed once err is set.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// newOIDCVerifier builds a verifier. An empty jwksURL is looked up from the
+// issuer's discovery document on first use.
+func newOIDCVerifier(issuer, audience, jwksURL, usernameClaim, groupsClaim string) (*oidcVerifier, error) {
+	if !strings.HasPrefix(issuer, "https://") && !strings.HasPrefix(issuer, "http://") {
+		return nil, fmt.Errorf("issuer %q must be an http(s) URL", issuer)
+	}
+	if audience == "" {
+		return nil, errors.New("an audience (the client ID tokens are issued for) is required")
+	}
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	return &oidcVerifier{
+		issuer:        issuer,
+		audience:      audience,
+		jwksURL:       jwksURL,
+		usernameClaim: usernameClaim,
+		groupsClaim:   groupsClaim,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(oidcLeeway),
+		),
+	}, nil
+}
+
+// loadOIDCVerifier reads the OIDC_* env vars; OIDC is off (nil, nil) when
+// OIDC_ISSUER is unset.
+func loadOIDCVerifier() (*oidcVerifier, error) {
+	issuer := envOr("OIDC_ISSUER", "")
+	if issuer == "" {
+		return nil, nil
+	}
+	return newOIDCVerifier(issuer, envOr("OIDC_AUDIENCE", ""), envOr("OIDC_JWKS_URL", ""),
+		envOr("OIDC_USERNAME_CLAIM", ""), envOr("OIDC_GROUPS_CLAIM", ""))
+}
+
+// looksLikeJWT tells a compact JWS apart from an opaque API key.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// verify validates a token's signature, issuer, audience and lifetime and
+// maps its claims to an Identity.
+func (v *oidcVerifier) verify(token string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return Identity{}, err
+	}
+
+	id := Identity{Groups: []string{}, Method: "oidc"}
+	id.Subject, _ = claims["sub"].(string)
+	if id.Subject == "" {
+		return Identity{}, errors.New("token has no subject")
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		id.ExpiresAt = &exp.Time
+	}
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = lookupClaim(claims, v.usernameClaim).(string)
+	if id.Name == "" {
+		id.Name = id.Email
+	}
+	if id.Name == "" {
+		id.Name = id.Subject
+	}
+	switch groups := lookupClaim(claims, v.groupsClaim).(type) {
+	case string:
+		id.Groups = append(id.Groups, groups)
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+	return id, nil
+}
+
+// lookupClaim resolves a dotted claim path such as "realm_access.roles".
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var cur interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// keyFunc picks the signing key named by the token's kid, refetching the
+// JWKS when the keys are stale or the kid is new (the provider rotated).
+// The fetch runs without v.mu held; concurrent requests wait for it instead
+// of fetching again. While the provider is unreachable the cached keys keep
+// validating, but only for up to jwksMaxStale.
+func (v *oidcVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	if ok && time.Since(v.loaded) < jwksMaxAge {
+		v.mu.Unlock()
+		return key, nil
+	}
+	fetch := v.inflight
+	if fetch == nil {
+		if time.Since(v.fetched) < jwksMinRefresh {
+			// A refetch just ran (and, for a known kid, failed)
+			stale := time.Since(v.loaded) >= jwksMaxStale
+			v.mu.Unlock()
+			if ok && !stale {
+				return key, nil
+			}
+			if ok {
+				return nil, fmt.Errorf("signing keys expired and the JWKS can't be fetched")
+			}
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		fetch = &jwksFetch{done: make(chan struct{})}
+		v.inflight = fetch
+		v.fetched = time.Now()
+		jwksURL := v.jwksURL
+		v.mu.Unlock()
+
+		keys, jwksURL, err := v.refresh(jwksURL)
+		v.mu.Lock()
+		v.jwksURL = jwksURL
+		switch {
+		case err == nil:
+			v.keys, v.loaded = keys, time.Now()
+		case time.Since(v.loaded) >= jwksMaxStale:
+			slog.Error("OIDC signing keys expired and the JWKS can't be refetched, rejecting tokens", "error", err)
+		default:
+			slog.Warn("JWKS refetch failed, keeping cached signing keys", "error", err)
+		}
+		fetch.err = err
+		v.inflight = nil
+		close(fetch.done)
+	} else {
+		v.mu.Unlock()
+		<-fetch.done
+		v.mu.Lock()
+	}
+	fresh, found := v.keys[kid]
+	stale := time.Since(v.loaded) >= jwksMaxStale
+	v.mu.Unlock()
+
+	if fetch.err != nil {
+		if ok && !stale {
+			// Provider unreachable: keep trusting the last known key
+			return key, nil
+		}
+		if ok {
+			return nil, fmt.Errorf("signing keys expired and the JWKS can't be fetched: %w", fetch.err)
+		}
+		return nil, fetch.err
+	}
+	if found {
+		return fresh, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refresh fetches the signing keys, looking up the JWKS URL from discovery
+// when it isn't known yet, and returns them with the URL used. Failed
+// attempts count towards the throttle too, so an unreachable provider isn't
+// retried on every request.
+func (v *oidcVerifier) refresh(jwksURL string) (map[string]interface{}, string, error) {
+	if jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(strings.TrimSuffix(v.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, "", fmt.Errorf("OIDC discovery: %w", err)
+		}
+		if discovery.Issuer != v.issuer || discovery.JWKSURI == "" {
+			return nil, "", fmt.Errorf("OIDC discovery: issuer %q does not match or no jwks_uri", discovery.Issuer)
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(jwksURL, &set); err != nil {
+		return nil, jwksURL, fmt.Errorf("JWKS fetch: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, jwksURL, nil
+}
+
+func (v *oidcVerifier) getJSON(url string, into interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(into)
+}
+
+// jsonWebKey is an RFC 7517 public key; only RSA and EC signing keys are
+// understood.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	b64 := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := b64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key shorter than 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// testIdP is a minimal OIDC provider: discovery plus a JWKS with one RSA
+// and one EC signing key.
+type testIdP struct {
+	*httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	jwksHits  atomic.Int32
+	extraKeys []map[string]string
+	hold      chan struct{} // when set, JWKS responses wait for it to close
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{rsaKey: rsaKey, ecKey: ecKey}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "jwks_uri": idp.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksHits.Add(1)
+		if idp.hold != nil {
+			<-idp.hold
+		}
+		keys := []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": append(keys, idp.extraKeys...)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) token(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (idp *testIdP) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                "roundtable-ui",
+		"sub":                "u-123",
+		"preferred_username": "arthur",
+		"email":              "arthur@camelot.example",
+		"groups":             []string{"knights", "admins"},
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestAuthMiddlewareOIDC(t *testing.T) {
+	idp := newTestIdP(t)
+	verifier, err := newOIDCVerifier(idp.URL, "roundtable-ui", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidcAuth = verifier
+	defer func() { oidcAuth = nil }()
+	os.Setenv("DASHBOARD_API_KEY", "shared-key")
+	defer os.Unsetenv("DASHBOARD_API_KEY")
+
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.HandleFunc("/api/auth/me", authMeHandler).Methods("GET")
+
+	me := func(auth string) (int, Identity) {
+		req := httptest.NewRequest("GET", "/api/auth/me", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var id Identity
+		json.Unmarshal(w.Body.Bytes(), &id)
+		return w.Code, id
+	}
+
+	code, id := me("Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(nil)))
+	if code != http.StatusOK || id.Subject != "u-123" || id.Name != "arthur" || id.Method != "oidc" || len(id.Groups) != 2 || id.Groups[1] != "admins" {
+		t.Errorf("expected RSA-signed token accepted as arthur, got %d %+v", code, id)
+	}
+	code, id = me("Bearer " + idp.token(t, jwt.SigningMethodES256, "ec-1", idp.ecKey, idp.claims(jwt.MapClaims{"preferred_username": nil})))
+	if code != http.StatusOK || id.Name != "arthur@camelot.example" {
+		t.Errorf("expected EC-signed token accepted with email as name, got %d %+v", code, id)
+	}
+	if code, id := me("Bearer shared-key"); code != http.StatusOK || id.Method != "api-key" {
+		t.Errorf("expected shared API key still accepted, got %d %+v", code, id)
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"no header":      "",
+		"wrong key":      "Bearer shared-kez",
+		"expired":        "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no expiry":      "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"exp": nil})),
+		"wrong audience": "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"aud": "grafana"})),
+		"wrong issuer":   "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"iss": "https://evil.example"})),
+		"no subject":     "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"sub": ""})),
+		"forged":         "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", otherKey, idp.claims(nil)),
+		"encryption key": "Bearer " + idp.token(t, jwt.SigningMethodRS256, "enc-1", idp.rsaKey, idp.claims(nil)),
+		"hmac":           "Bearer " + idp.token(t, jwt.SigningMethodHS256, "rsa-1", []byte("shared-key"), idp.claims(nil)),
+	}
+	for name, auth := range rejected {
+		if code, _ := me(auth); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, code)
+		}
+	}
+}
+
+func TestOIDCVerifierKeyRefresh(t *testing.T) {
+	idp := newTestIdP(t)
+	verifier, _ := newOIDCVerifier(idp.URL, "roundtable-ui", idp.URL+"/jwks", "", "realm_access.roles")
+
+	claims := idp.claims(jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{"operators"}}})
+	id, err := verifier.verify(idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, claims))
+	if err != nil || len(id.Groups) != 1 || id.Groups[0] != "operators" {
+		t.Fatalf("expected nested groups claim, got %+v %v", id, err)
+	}
+
+	// An unknown kid within the throttle window doesn't refetch
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.extraKeys = []map[string]string{{
+		"kty": "RSA", "kid": "rsa-2",
+		"n": base64.RawURLEncoding.EncodeToString(rotated.N.Bytes()), "e": "AQAB",
+	}}
+	rotatedToken := idp.token(t, jwt.SigningMethodRS256, "rsa-2", rotated, idp.claims(nil))
+	if _, err := verifier.verify(rotatedToken); err == nil {
+		t.Error("expected unknown kid rejected inside the refresh throttle")
+	}
+	if hits := idp.jwksHits.Load(); hits != 1 {
+		t.Errorf("expected 1 JWKS fetch, got %d", hits)
+	}
+
+	// Once the throttle has passed, the rotated key is picked up
+	verifier.fetched = time.Now().Add(-jwksMinRefresh)
+	if _, err := verifier.verify(rotatedToken); err != nil {
+		t.Errorf("expected rotated key accepted after refetch, got %v", err)
+	}
+	if hits := idp.jwksHits.Load(); hits != 2 {
+		t.Errorf("expected 2 JWKS fetches, got %d", hits)
+	}
+
+	// A slow refetch neither blocks tokens signed by known keys nor is
+	// repeated by the requests waiting on it
+	rotated3, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.extraKeys = append(idp.extraKeys, map[string]string{
+		"kty": "RSA", "kid": "rsa-3",
+		"n": base64.RawURLEncoding.EncodeToString(rotated3.N.Bytes()), "e": "AQAB",
+	})
+	idp.hold = make(chan struct{})
+	verifier.fetched = time.Now().Add(-jwksMinRefresh)
+	rotated3Token := idp.token(t, jwt.SigningMethodRS256, "rsa-3", rotated3, idp.claims(nil))
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := verifier.verify(rotated3Token)
+			errs <- err
+		}()
+	}
+	for idp.jwksHits.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := verifier.verify(rotatedToken); err != nil {
+		t.Errorf("expected a known key usable during the refetch, got %v", err)
+	}
+	close(idp.hold)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("expected the waiting requests to get the new key, got %v", err)
+		}
+	}
+	if hits := idp.jwksHits.Load(); hits != 3 {
+		t.Errorf("expected one shared refetch, got %d JWKS fetches", hits)
+	}
+
+	// With the provider unreachable, cached keys validate until they are
+	// jwksMaxStale old, then tokens are rejected
+	idp.hold = nil
+	verifier.jwksURL = idp.URL + "/gone"
+	rsaToken := idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(nil))
+	verifier.loaded = time.Now().Add(-jwksMaxAge)
+	verifier.fetched = verifier.loaded
+	if _, err := verifier.verify(rsaToken); err != nil {
+		t.Errorf("expected cached keys trusted while the provider is down, got %v", err)
+	}
+	if _, err := verifier.verify(rsaToken); err != nil {
+		t.Errorf("expected cached keys trusted inside the refresh throttle, got %v", err)
+	}
+	verifier.loaded = time.Now().Add(-jwksMaxStale)
+	if _, err := verifier.verify(rsaToken); err == nil {
+		t.Error("expected tokens rejected once the cached keys are too stale")
+	}
+	verifier.fetched = time.Now().Add(-jwksMinRefresh)
+	if _, err := verifier.verify(rsaToken); err == nil {
+		t.Error("expected tokens rejected when the refetch fails again")
+	}
+
+	for _, bad := range [][2]string{{"ftp://idp", "aud"}, {"https://idp", ""}} {
+		if _, err := newOIDCVerifier(bad[0], bad[1], "", "", ""); err == nil {
+			t.Errorf("expected issuer %q audience %q rejected", bad[0], bad[1])
+		}
+	}
+}
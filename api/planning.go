@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Plan approval annotations on the Mission CR. The operator holds a
+// meta-mission in Planning until plan-approval is "approved" (materializes
+// the generated resources) or "rejected" (fails the mission).
+const (
+	planApprovalAnnotation       = "ai.roundtable.io/plan-approval"
+	planApprovedByAnnotation     = "ai.roundtable.io/plan-approved-by"
+	planApprovalTimeAnnotation   = "ai.roundtable.io/plan-approval-time"
+	planApprovalReasonAnnotation = "ai.roundtable.io/plan-approval-reason"
+	missionLabel                 = "ai.roundtable.io/mission"
+)
+
+// PlannedStep is a step of a planner-generated chain
+type PlannedStep struct {
+	Name      string   `json:"name"`
+	KnightRef string   `json:"knightRef"`
+	Task      string   `json:"task,omitempty"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// PlannedChain is a chain the planner generated (or the operator created)
+type PlannedChain struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Steps       []PlannedStep `json:"steps"`
+}
+
+// PlannedKnight is a knight the planner generated (or the operator created)
+type PlannedKnight struct {
+	Name        string   `json:"name"`
+	Domain      string   `json:"domain,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Skills      []string `json:"skills,omitempty"`
+	NixPackages []string `json:"nixPackages,omitempty"`
+}
+
+// PlanDiffEntry describes what materializing the plan will do to one resource
+type PlanDiffEntry struct {
+	Kind   string `json:"kind"` // Knight, Chain, Skill
+	Name   string `json:"name"`
+	Action string `json:"action"` // create, exists, conflict
+}
+
+// PlanApproval is the approval state recorded on the Mission CR annotations
+type PlanApproval struct {
+	State  string `json:"state"` // pending, approved, rejected
+	By     string `json:"by,omitempty"`
+	At     string `json:"at,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MissionPlan is the API response for a meta-mission's planner output
+type MissionPlan struct {
+	Mission        string           `json:"mission"`
+	MetaMission    bool             `json:"metaMission"`
+	Phase          string           `json:"phase"`
+	Source         string           `json:"source"` // planner-output, owned-resources, none
+	PlanningResult *PlanningResult  `json:"planningResult,omitempty"`
+	Chains         []PlannedChain   `json:"chains"`
+	Knights        []PlannedKnight  `json:"knights"`
+	Skills         []GeneratedSkill `json:"skills"`
+	Diff           []PlanDiffEntry  `json:"diff"`
+	Approval       PlanApproval     `json:"approval"`
+}
+
+// plannerOutput is the JSON document the planner knight emits. It may be
+// wrapped in prose or a ```json fence inside rawOutput.
+type plannerOutput struct {
+	Chains  []PlannedChain   `json:"chains"`
+	Knights []PlannedKnight  `json:"knights"`
+	Skills  []GeneratedSkill `json:"skills"`
+}
+
+// parsePlannerOutput extracts the outermost JSON object from the planner's raw
+// output. ok is false when no plan document can be found.
+func parsePlannerOutput(raw string) (plannerOutput, bool) {
+	var out plannerOutput
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		return out, false
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &out); err != nil {
+		return out, false
+	}
+	return out, len(out.Chains)+len(out.Knights)+len(out.Skills) > 0
+}
+
+// ownedByMission reports whether a CR was generated for the given mission,
+// either via an owner reference or the mission label.
+func ownedByMission(obj *unstructured.Unstructured, mission string) bool {
+	if obj.GetLabels()[missionLabel] == mission {
+		return true
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == "Mission" && ref.Name == mission {
+			return true
+		}
+	}
+	return false
+}
+
+func stringSlice(obj map[string]interface{}, key string) []string {
+	var out []string
+	for _, v := range getSlice(obj, key) {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func plannedKnightFromCR(obj *unstructured.Unstructured) (PlannedKnight, []GeneratedSkill) {
+	spec := getNestedMap(obj.Object, "spec")
+	k := PlannedKnight{
+		Name:        obj.GetName(),
+		Domain:      getStr(spec, "domain"),
+		Model:       getStr(spec, "model"),
+		Skills:      stringSlice(spec, "skills"),
+		NixPackages: stringSlice(spec, "nixPackages"),
+	}
+	var skills []GeneratedSkill
+	for _, s := range getSlice(spec, "generatedSkills") {
+		if sm, ok := s.(map[string]interface{}); ok {
+			skills = append(skills, GeneratedSkill{Name: getStr(sm, "name"), Content: getStr(sm, "content")})
+		}
+	}
+	return k, skills
+}
+
+func plannedChainFromCR(obj *unstructured.Unstructured) PlannedChain {
+	spec := getNestedMap(obj.Object, "spec")
+	c := PlannedChain{Name: obj.GetName(), Description: getStr(spec, "description"), Steps: []PlannedStep{}}
+	for _, s := range getSlice(spec, "steps") {
+		if sm, ok := s.(map[string]interface{}); ok {
+			c.Steps = append(c.Steps, PlannedStep{
+				Name:      getStr(sm, "name"),
+				KnightRef: getStr(sm, "knightRef"),
+				Task:      getStr(sm, "task"),
+				DependsOn: stringSlice(sm, "dependsOn"),
+			})
+		}
+	}
+	return c
+}
+
+func planApprovalFromCR(obj *unstructured.Unstructured) PlanApproval {
+	ann := obj.GetAnnotations()
+	state := ann[planApprovalAnnotation]
+	if state == "" {
+		state = "pending"
+	}
+	return PlanApproval{
+		State:  state,
+		By:     ann[planApprovedByAnnotation],
+		At:     ann[planApprovalTimeAnnotation],
+		Reason: ann[planApprovalReasonAnnotation],
+	}
+}
+
+// buildMissionPlan assembles the plan for a mission from the planner output
+// (what will be created) and the CRs already generated for it (what exists).
+func buildMissionPlan(ctx context.Context, namespace string, obj *unstructured.Unstructured) (*MissionPlan, error) {
+	mission := parseMissionResource(obj.Object)
+	plan := &MissionPlan{
+		Mission:        mission.Name,
+		MetaMission:    mission.MetaMission,
+		Phase:          mission.Phase,
+		Source:         "none",
+		PlanningResult: mission.PlanningResult,
+		Chains:         []PlannedChain{},
+		Knights:        []PlannedKnight{},
+		Skills:         []GeneratedSkill{},
+		Diff:           []PlanDiffEntry{},
+		Approval:       planApprovalFromCR(obj),
+	}
+
+	knightList, err := dynClient.Resource(knightGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list knights: %w", err)
+	}
+	chainList, err := dynClient.Resource(chainGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list chains: %w", err)
+	}
+
+	// existing[kind][name] = owned by this mission?
+	existing := map[string]map[string]bool{"Knight": {}, "Chain": {}}
+	var ownedKnights []PlannedKnight
+	var ownedChains []PlannedChain
+	var ownedSkills []GeneratedSkill
+	for i := range knightList.Items {
+		item := &knightList.Items[i]
+		owned := ownedByMission(item, mission.Name)
+		existing["Knight"][item.GetName()] = owned
+		if owned {
+			k, skills := plannedKnightFromCR(item)
+			ownedKnights = append(ownedKnights, k)
+			ownedSkills = append(ownedSkills, skills...)
+		}
+	}
+	for i := range chainList.Items {
+		item := &chainList.Items[i]
+		owned := ownedByMission(item, mission.Name)
+		existing["Chain"][item.GetName()] = owned
+		if owned {
+			ownedChains = append(ownedChains, plannedChainFromCR(item))
+		}
+	}
+
+	diffAction := func(kind, name string) string {
+		owned, ok := existing[kind][name]
+		switch {
+		case !ok:
+			return "create"
+		case owned:
+			return "exists"
+		default:
+			return "conflict" // a CR with this name belongs to something else
+		}
+	}
+
+	if mission.PlanningResult != nil {
+		if out, ok := parsePlannerOutput(mission.PlanningResult.RawOutput); ok {
+			plan.Source = "planner-output"
+			plan.Knights = append(plan.Knights, out.Knights...)
+			plan.Chains = append(plan.Chains, out.Chains...)
+			plan.Skills = append(plan.Skills, out.Skills...)
+		}
+	}
+	if plan.Source == "none" && len(ownedKnights)+len(ownedChains) > 0 {
+		plan.Source = "owned-resources"
+		plan.Knights = append(plan.Knights, ownedKnights...)
+		plan.Chains = append(plan.Chains, ownedChains...)
+		plan.Skills = append(plan.Skills, ownedSkills...)
+	}
+
+	for _, k := range plan.Knights {
+		plan.Diff = append(plan.Diff, PlanDiffEntry{Kind: "Knight", Name: k.Name, Action: diffAction("Knight", k.Name)})
+	}
+	for _, c := range plan.Chains {
+		plan.Diff = append(plan.Diff, PlanDiffEntry{Kind: "Chain", Name: c.Name, Action: diffAction("Chain", c.Name)})
+	}
+	// Skills are embedded in Knight specs — they exist once the knight does
+	ownedSkillNames := map[string]bool{}
+	for _, s := range ownedSkills {
+		ownedSkillNames[s.Name] = true
+	}
+	for _, s := range plan.Skills {
+		action := "create"
+		if ownedSkillNames[s.Name] {
+			action = "exists"
+		}
+		plan.Diff = append(plan.Diff, PlanDiffEntry{Kind: "Skill", Name: s.Name, Action: action})
+	}
+	sort.SliceStable(plan.Diff, func(i, j int) bool { return plan.Diff[i].Kind < plan.Diff[j].Kind })
+
+	return plan, nil
+}
+
+func missionPlanHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+
+		obj, err := dynClient.Resource(missionGVR).Namespace(namespace).Get(r.Context(), name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+
+		plan, err := buildMissionPlan(r.Context(), namespace, obj)
+		if err != nil {
+			slog.Error("Mission plan error", "mission", name, "error", err)
+			http.Error(w, "Failed to build plan", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+	}
+}
+
+// missionPlanDecisionHandler records an approve/reject decision on a
+// meta-mission's plan. Decisions are final: the operator may already be
+// materializing an approved plan.
+func missionPlanDecisionHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		vars := mux.Vars(r)
+		name := vars["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+		var state string
+		switch vars["decision"] {
+		case "approve":
+			state = "approved"
+		case "reject":
+			state = "rejected"
+		default:
+			http.Error(w, "Invalid decision (allowed: approve, reject)", http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if len(body.Reason) > 1000 {
+			http.Error(w, "Reason must be at most 1000 characters", http.StatusBadRequest)
+			return
+		}
+
+		obj, err := dynClient.Resource(missionGVR).Namespace(namespace).Get(r.Context(), name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+		if !getBool(getNestedMap(obj.Object, "spec"), "metaMission") {
+			http.Error(w, "Only meta-missions have a plan to approve", http.StatusConflict)
+			return
+		}
+		if getNestedMap(getNestedMap(obj.Object, "status"), "planningResult") == nil {
+			http.Error(w, "Planning has not completed yet", http.StatusConflict)
+			return
+		}
+		if current := planApprovalFromCR(obj); current.State != "pending" {
+			http.Error(w, fmt.Sprintf("Plan already %s", current.State), http.StatusConflict)
+			return
+		}
+
+		approval := PlanApproval{
+			State:  state,
+			By:     requestIdentity(r).Name,
+			At:     time.Now().UTC().Format(time.RFC3339),
+			Reason: body.Reason,
+		}
+		// The resourceVersion makes the patch conditional on the mission
+		// being unchanged since the pending check above, so of two
+		// concurrent decisions only the first lands.
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": obj.GetResourceVersion(),
+				"annotations": map[string]interface{}{
+					planApprovalAnnotation:       approval.State,
+					planApprovedByAnnotation:     approval.By,
+					planApprovalTimeAnnotation:   approval.At,
+					planApprovalReasonAnnotation: approval.Reason,
+				},
+			},
+		})
+		_, err = dynClient.Resource(missionGVR).Namespace(namespace).Patch(
+			r.Context(), name, types.MergePatchType, patch, metav1.PatchOptions{},
+		)
+		if apierrors.IsConflict(err) {
+			http.Error(w, "Plan decision already made", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			slog.Error("Mission plan decision patch error", "mission", name, "error", err)
+			http.Error(w, "Failed to record decision", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mission":  name,
+			"approval": approval,
+		})
+	}
+}
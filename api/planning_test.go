@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// makeTestMetaMission builds a meta-mission whose planner produced one knight,
+// one chain and one skill.
+func makeTestMetaMission(name string, planned bool) *unstructured.Unstructured {
+	status := map[string]interface{}{"phase": "Planning"}
+	if planned {
+		status["planningResult"] = map[string]interface{}{
+			"completedAt":      "2024-01-01T10:05:00Z",
+			"chainsGenerated":  int64(1),
+			"knightsGenerated": int64(1),
+			"skillsGenerated":  int64(1),
+			"rawOutput": "Here is the plan:\n```json\n" + `{
+				"knights": [{"name": "scout", "domain": "recon", "skills": ["nmap"]}],
+				"chains": [{"name": "sweep", "steps": [{"name": "scan", "knightRef": "scout"}]}],
+				"skills": [{"name": "nmap", "content": "# nmap"}]
+			}` + "\n```",
+		}
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"objective":   "Plan a sweep",
+				"metaMission": true,
+			},
+			"status": status,
+		},
+	}
+}
+
+// TestMissionPlanHandler verifies planner output is parsed and diffed against
+// existing CRs.
+func TestMissionPlanHandler(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, makeTestMetaMission("sweep-op", true), metav1.CreateOptions{})
+
+	// A chain with the planned name already exists and belongs to someone else
+	chain := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Chain",
+			"metadata": map[string]interface{}{
+				"name":      "sweep",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{},
+		},
+	}
+	dynClient.Resource(chainGVR).Namespace("test-namespace").Create(ctx, chain, metav1.CreateOptions{})
+
+	req := httptest.NewRequest("GET", "/api/missions/sweep-op/plan", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var plan MissionPlan
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if plan.Source != "planner-output" {
+		t.Errorf("expected source planner-output, got %s", plan.Source)
+	}
+	if len(plan.Knights) != 1 || plan.Knights[0].Name != "scout" {
+		t.Errorf("expected planned knight scout, got %+v", plan.Knights)
+	}
+	if len(plan.Chains) != 1 || len(plan.Chains[0].Steps) != 1 {
+		t.Errorf("expected one planned chain with one step, got %+v", plan.Chains)
+	}
+	if plan.Approval.State != "pending" {
+		t.Errorf("expected approval pending, got %s", plan.Approval.State)
+	}
+
+	actions := map[string]string{}
+	for _, d := range plan.Diff {
+		actions[d.Kind+"/"+d.Name] = d.Action
+	}
+	expected := map[string]string{"Knight/scout": "create", "Chain/sweep": "conflict", "Skill/nmap": "create"}
+	for k, v := range expected {
+		if actions[k] != v {
+			t.Errorf("expected %s action %s, got %s", k, v, actions[k])
+		}
+	}
+}
+
+// TestMissionPlanFromOwnedResources falls back to CRs labelled for the mission
+// when the planner output is not parseable.
+func TestMissionPlanFromOwnedResources(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	mission := makeTestMetaMission("owned-op", true)
+	unstructured.SetNestedField(mission.Object, "no json here", "status", "planningResult", "rawOutput")
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, mission, metav1.CreateOptions{})
+
+	knight := makeTestKnightCR("scout", "test-namespace", "recon")
+	knight.SetLabels(map[string]string{missionLabel: "owned-op"})
+	dynClient.Resource(knightGVR).Namespace("test-namespace").Create(ctx, knight, metav1.CreateOptions{})
+	dynClient.Resource(knightGVR).Namespace("test-namespace").Create(ctx, makeTestKnightCR("galahad", "test-namespace", "security"), metav1.CreateOptions{})
+
+	req := httptest.NewRequest("GET", "/api/missions/owned-op/plan", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var plan MissionPlan
+	json.Unmarshal(w.Body.Bytes(), &plan)
+	if plan.Source != "owned-resources" {
+		t.Fatalf("expected source owned-resources, got %s", plan.Source)
+	}
+	if len(plan.Knights) != 1 || plan.Knights[0].Name != "scout" {
+		t.Errorf("expected only the owned knight, got %+v", plan.Knights)
+	}
+	if len(plan.Diff) != 1 || plan.Diff[0].Action != "exists" {
+		t.Errorf("expected owned knight to diff as exists, got %+v", plan.Diff)
+	}
+}
+
+// TestMissionPlanDecision covers approve/reject and their preconditions
+func TestMissionPlanDecision(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, makeTestMetaMission("ready-op", true), metav1.CreateOptions{})
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, makeTestMetaMission("early-op", false), metav1.CreateOptions{})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"planning not finished", "/api/missions/early-op/plan/approve", http.StatusConflict},
+		{"unknown decision", "/api/missions/ready-op/plan/maybe", http.StatusBadRequest},
+		{"approve", "/api/missions/ready-op/plan/approve", http.StatusOK},
+		{"decision is final", "/api/missions/ready-op/plan/reject", http.StatusConflict},
+		{"unknown mission", "/api/missions/missing/plan/approve", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewReader([]byte(`{"reason": "looks good"}`))
+			req := httptest.NewRequest("POST", tt.path, body)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	obj, _ := dynClient.Resource(missionGVR).Namespace("test-namespace").Get(ctx, "ready-op", metav1.GetOptions{})
+	ann := obj.GetAnnotations()
+	if ann[planApprovalAnnotation] != "approved" {
+		t.Errorf("expected approval annotation 'approved', got %q", ann[planApprovalAnnotation])
+	}
+	if ann[planApprovalReasonAnnotation] != "looks good" {
+		t.Errorf("expected reason annotation, got %q", ann[planApprovalReasonAnnotation])
+	}
+}
+
+// TestMissionPlanDecisionRace makes two decisions against the same
+// resourceVersion, as two reviewers clicking at once would: the second
+// patch fails its precondition and is reported as already decided.
+func TestMissionPlanDecisionRace(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+	stale := makeTestMetaMission("race-op", true)
+	stale.SetResourceVersion("7")
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, stale, metav1.CreateOptions{})
+
+	// Both requests read the mission while it was still pending, and the
+	// fake client gets the API server's precondition check: a patch naming
+	// a resourceVersion other than the stored one is a conflict.
+	dc := dynClient.(*dynamicfake.FakeDynamicClient)
+	current := "7"
+	var patched []string
+	dc.PrependReactor("get", "missions", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, stale.DeepCopy(), nil
+	})
+	dc.PrependReactor("patch", "missions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var patch struct {
+			Metadata struct {
+				ResourceVersion string `json:"resourceVersion"`
+			} `json:"metadata"`
+		}
+		json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &patch)
+		patched = append(patched, patch.Metadata.ResourceVersion)
+		if patch.Metadata.ResourceVersion != current {
+			return true, nil, apierrors.NewConflict(missionGVR.GroupResource(), "race-op", nil)
+		}
+		current = "8"
+		return false, nil, nil
+	})
+
+	decide := func(decision string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/missions/race-op/plan/"+decision, nil))
+		return w
+	}
+	if w := decide("approve"); w.Code != http.StatusOK {
+		t.Fatalf("expected the first decision recorded, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := decide("reject"); w.Code != http.StatusConflict || w.Body.String() != "Plan decision already made\n" {
+		t.Errorf("expected the second decision refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(patched) != 2 || patched[0] != "7" || patched[1] != "7" {
+		t.Errorf("expected both patches conditional on the version read, got %v", patched)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit classes: each caller gets a separate budget per class, so a
+// script hammering reads can't use up anyone's dispatches.
+const (
+	rateClassRead     = "read"
+	rateClassDispatch = "dispatch"
+	rateClassWrite    = "write" // missions and every other mutation
+	rateClassWS       = "ws"    // WebSocket tickets and connects
+)
+
+// defaultRateLimits apply to classes RATE_LIMITS doesn't mention.
+const defaultRateLimits = "read=50/s,dispatch=60/m,write=120/m,ws=60/m"
+
+// rateBucketIdle is how long an untouched bucket is kept; by then it has
+// refilled anyway.
+const rateBucketIdle = 10 * time.Minute
+
+// rateBudget is a token bucket's refill rate and capacity.
+type rateBudget struct {
+	perSecond float64
+	burst     float64
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a per-client, per-route-class token bucket limiter (#12).
+type rateLimiter struct {
+	budgets map[string]rateBudget
+	now     func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket // class + "|" + client
+	lastSweep time.Time
+}
+
+func newRateLimiter(budgets map[string]rateBudget) *rateLimiter {
+	return &rateLimiter{budgets: budgets, now: time.Now, buckets: map[string]*tokenBucket{}}
+}
+
+// parseRateLimits parses "class=N/unit,..." (unit s, m or h) over the
+// defaults; the burst is N.
+func parseRateLimits(spec string) (map[string]rateBudget, error) {
+	budgets := map[string]rateBudget{}
+	units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	for _, s := range []string{defaultRateLimits, spec} {
+		for _, entry := range strings.Split(s, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			class, limit, ok := strings.Cut(entry, "=")
+			count, unit, ok2 := strings.Cut(limit, "/")
+			n, err := strconv.Atoi(count)
+			if !ok || !ok2 || err != nil || n < 1 || units[unit] == 0 {
+				return nil, fmt.Errorf("invalid rate limit %q (want class=N/s|m|h)", entry)
+			}
+			switch class {
+			case rateClassRead, rateClassDispatch, rateClassWrite, rateClassWS:
+			default:
+				return nil, fmt.Errorf("unknown rate limit class %q (read, dispatch, write, ws)", class)
+			}
+			budgets[class] = rateBudget{perSecond: float64(n) / units[unit].Seconds(), burst: float64(n)}
+		}
+	}
+	return budgets, nil
+}
+
+// rateClass buckets a request by what it costs the fleet.
+func rateClass(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/api/ws" || r.URL.Path == "/api/ws/ticket":
+		return rateClassWS
+	case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+		return rateClassRead
+	case r.Method == http.MethodPost && taskDispatchRoutes[r.URL.Path]:
+		return rateClassDispatch
+	}
+	return rateClassWrite
+}
+
+// rateClient identifies whose budget a request spends: the JWT subject or
+// named API key, or the client IP for callers who share an identity (the
+// shared DASHBOARD_API_KEY, or everyone with auth disabled).
+func rateClient(r *http.Request) string {
+	id := requestIdentity(r)
+	if id.Method == "oidc" || (id.Method == "api-key" && id.Scopes != nil) {
+		return id.Subject
+	}
+	return "ip:" + clientIP(r)
+}
+
+// rateDecision is the outcome of take, for the X-RateLimit headers.
+type rateDecision struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	retryAfter time.Duration // until a token is available
+	reset      time.Duration // until the bucket is full
+}
+
+// take spends a token from the client's bucket for class.
+func (rl *rateLimiter) take(class, client string) rateDecision {
+	budget := rl.budgets[class]
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if now.Sub(rl.lastSweep) > rateBucketIdle {
+		for key, b := range rl.buckets {
+			if now.Sub(b.last) > rateBucketIdle {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	key := class + "|" + client
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: budget.burst, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(budget.burst, b.tokens+now.Sub(b.last).Seconds()*budget.perSecond)
+	b.last = now
+
+	d := rateDecision{limit: int(budget.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = time.Duration((1 - b.tokens) / budget.perSecond * float64(time.Second))
+	}
+	d.remaining = int(b.tokens)
+	d.reset = time.Duration((budget.burst - b.tokens) / budget.perSecond * float64(time.Second))
+	return d
+}
+
+// ceilSeconds rounds up to whole seconds for the rate limit headers.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// middleware rate limits /api requests (except health probes) and reports
+// the caller's budget in X-RateLimit-* headers. It runs after
+// authMiddleware so callers are told apart by identity.
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probeRequest(r) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		class := rateClass(r)
+		d := rl.take(class, rateClient(r))
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+		w.Header().Set("X-RateLimit-Reset", ceilSeconds(d.reset))
+		if !d.allowed {
+			rateLimitedTotal.WithLabelValues(class).Inc()
+			w.Header().Set("Retry-After", ceilSeconds(d.retryAfter))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedProxies are the networks whose X-Forwarded-For is believed, e.g.
+// the ingress controller's pod CIDR.
+var trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses TRUSTED_PROXIES, comma-separated CIDRs or IPs.
+func parseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the caller's address. When the peer is a trusted proxy it is
+// the rightmost X-Forwarded-For entry that isn't one, since everything to
+// its left could have been sent by the client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !isTrustedProxy(peer) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+	return host
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	budgets, err := parseRateLimits("dispatch=5/m, ws=2/s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b := budgets[rateClassDispatch]; b.burst != 5 || b.perSecond != 5.0/60 {
+		t.Errorf("unexpected dispatch budget %+v", b)
+	}
+	if b := budgets[rateClassRead]; b.burst != 50 || b.perSecond != 50 {
+		t.Errorf("expected default read budget, got %+v", b)
+	}
+	for _, bad := range []string{"dispatch", "dispatch=5", "dispatch=0/s", "dispatch=5/d", "health=5/s", "read=x/s"} {
+		if _, err := parseRateLimits(bad); err == nil {
+			t.Errorf("expected %q rejected", bad)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	budgets, _ := parseRateLimits("dispatch=2/s")
+	rl := newRateLimiter(budgets)
+	now := time.Unix(1700000000, 0)
+	rl.now = func() time.Time { return now }
+
+	// The burst is spent, then refused with the wait until the next token
+	for i := 0; i < 2; i++ {
+		if d := rl.take(rateClassDispatch, "a"); !d.allowed || d.remaining != 1-i {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i, 1-i, d)
+		}
+	}
+	d := rl.take(rateClassDispatch, "a")
+	if d.allowed || d.retryAfter != 500*time.Millisecond || d.reset != time.Second {
+		t.Errorf("expected refusal with 0.5s retry, got %+v", d)
+	}
+
+	// Other clients and classes have their own buckets
+	if !rl.take(rateClassDispatch, "b").allowed || !rl.take(rateClassRead, "a").allowed {
+		t.Error("expected separate budgets per client and class")
+	}
+
+	// Tokens refill at the configured rate
+	now = now.Add(500 * time.Millisecond)
+	if !rl.take(rateClassDispatch, "a").allowed {
+		t.Error("expected a token after 0.5s")
+	}
+	if rl.take(rateClassDispatch, "a").allowed {
+		t.Error("expected only one token after 0.5s")
+	}
+
+	// Idle buckets are swept
+	now = now.Add(rateBucketIdle + time.Second)
+	rl.take(rateClassRead, "c")
+	if len(rl.buckets) != 1 {
+		t.Errorf("expected idle buckets swept, have %d", len(rl.buckets))
+	}
+}
+
+func TestRateClass(t *testing.T) {
+	tests := []struct{ method, path, class string }{
+		{"GET", "/api/fleet", rateClassRead},
+		{"GET", "/api/ws", rateClassWS},
+		{"POST", "/api/tasks/dispatch", rateClassDispatch},
+		{"POST", "/api/briefings/rollup", rateClassDispatch},
+		{"POST", "/api/missions", rateClassWrite},
+		{"DELETE", "/api/missions/m1", rateClassWrite},
+	}
+	for _, tt := range tests {
+		if got := rateClass(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.class {
+			t.Errorf("rateClass(%s %s) = %s, want %s", tt.method, tt.path, got, tt.class)
+		}
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	budgets, _ := parseRateLimits("dispatch=1/m")
+	rl := newRateLimiter(budgets)
+	r := mux.NewRouter()
+	r.Use(rl.middleware)
+	r.HandleFunc("/api/tasks/dispatch", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	r.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	dispatch := func(remoteAddr string, id *Identity) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tasks/dispatch", nil)
+		req.RemoteAddr = remoteAddr
+		if id != nil {
+			req = req.WithContext(withIdentity(req.Context(), *id))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	before := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateClassDispatch))
+	if w := dispatch("10.0.0.1:1234", nil); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("expected first dispatch allowed with headers, got %d %v", w.Code, w.Header())
+	}
+	w := dispatch("10.0.0.1:5678", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected 429 with Retry-After 60, got %d %v", w.Code, w.Header())
+	}
+	if got := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateClassDispatch)) - before; got != 1 {
+		t.Errorf("expected one rejection counted, got %v", got)
+	}
+
+	// Different IPs, and named identities behind the same IP, have their own budgets
+	if w := dispatch("10.0.0.2:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("expected another client allowed, got %d", w.Code)
+	}
+	alice := Identity{Subject: "alice", Method: "oidc"}
+	if w := dispatch("10.0.0.1:1234", &alice); w.Code != http.StatusOK {
+		t.Errorf("expected an OIDC user keyed by subject, got %d", w.Code)
+	}
+	if w := dispatch("10.0.0.1:1234", &apiKeyIdentity); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected shared-key callers keyed by IP, got %d", w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/health", nil))
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("expected health checks exempt, got %d", w.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	var err error
+	trustedProxies, err = parseTrustedProxies("10.42.0.0/16, 192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { trustedProxies = nil }()
+
+	tests := []struct{ remote, xff, want string }{
+		{"198.51.100.4:1234", "1.2.3.4", "198.51.100.4"},
+		{"10.42.1.7:1234", "203.0.113.9", "203.0.113.9"},
+		{"10.42.1.7:1234", "1.2.3.4, 203.0.113.9, 192.0.2.10", "203.0.113.9"},
+		{"10.42.1.7:1234", "", "10.42.1.7"},
+		{"10.42.1.7:1234", "garbage", "10.42.1.7"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("clientIP(%s, XFF %q) = %s, want %s", tt.remote, tt.xff, got, tt.want)
+		}
+	}
+	if _, err := parseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected invalid CIDR rejected")
+	}
+}
+
+// TestWSDispatchRateLimited charges WebSocket dispatches to the same budget
+// as POST /api/tasks/dispatch, answering with an error frame when it's spent.
+func TestWSDispatchRateLimited(t *testing.T) {
+	budgets, _ := parseRateLimits("dispatch=1/m")
+	rl := newRateLimiter(budgets)
+	req := httptest.NewRequest("GET", "/api/ws", nil)
+	req = req.WithContext(withRole(withIdentity(req.Context(), Identity{Subject: "alice", Method: "oidc"}), roleOperator))
+
+	// The HTTP dispatch spent the budget
+	if !rl.take(rateClassDispatch, rateClient(req)).allowed {
+		t.Fatal("expected the first dispatch allowed")
+	}
+
+	var frames []string
+	cmd := wsCommand{Action: "dispatch", Knight: "galahad", Domain: "security", Task: "scan"}
+	before := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateClassDispatch))
+	if !wsDispatch(req, rl, "fleet-a", cmd, []byte(`{}`), func(b []byte) { frames = append(frames, string(b)) }) {
+		t.Fatal("expected the connection kept open")
+	}
+	if len(frames) != 1 || frames[0] != `{"action":"dispatch","error":"Rate limit exceeded","retryAfter":60,"type":"error"}` {
+		t.Errorf("expected a rate limit error frame, got %v", frames)
+	}
+	if got := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateClassDispatch)) - before; got != 1 {
+		t.Errorf("expected the rejection counted, got %v", got)
+	}
+}
+
+// TestWSDispatchValidatesBeforeCharging checks malformed commands are
+// dropped without spending the caller's dispatch budget.
+func TestWSDispatchValidatesBeforeCharging(t *testing.T) {
+	budgets, _ := parseRateLimits("dispatch=1/m")
+	rl := newRateLimiter(budgets)
+	req := httptest.NewRequest("GET", "/api/ws", nil)
+	req = req.WithContext(withRole(withIdentity(req.Context(), Identity{Subject: "alice", Method: "oidc"}), roleOperator))
+
+	var frames []string
+	send := func(b []byte) { frames = append(frames, string(b)) }
+	for _, cmd := range []wsCommand{
+		{Action: "dispatch", Knight: "../x", Domain: "security", Task: "scan"},
+		{Action: "dispatch", Knight: "galahad", Domain: "security"},
+	} {
+		if !wsDispatch(req, rl, "fleet-a", cmd, []byte(`{}`), send) {
+			t.Fatal("expected the connection kept open")
+		}
+	}
+	if len(frames) != 0 {
+		t.Errorf("expected malformed commands dropped silently, got %v", frames)
+	}
+	if !rl.take(rateClassDispatch, rateClient(req)).allowed {
+		t.Error("expected the dispatch budget untouched by malformed commands")
+	}
+}
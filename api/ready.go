@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// readyCheckTimeout bounds each dependency check, so a hung API server
+	// can't stall a kubelet probe.
+	readyCheckTimeout = 2 * time.Second
+	// readyCacheTTL is how long a report is reused; probes from every
+	// replica plus the UI banner would otherwise each hit the API server.
+	readyCacheTTL = 5 * time.Second
+)
+
+// readyComponents are the dependencies /api/ready checks, in report order.
+var readyComponents = []string{"nats", "jetstream", "kubernetes", "crds", "vault"}
+
+// defaultReadyRequired are the components without which the dashboard is
+// useless; the rest degrade single pages.
+const defaultReadyRequired = "nats,jetstream"
+
+// ComponentStatus is one dependency's result in a ReadinessReport.
+type ComponentStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // ok or down
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReadinessReport is the full dependency report served to authenticated
+// callers by /api/health?verbose=1; /api/ready only reveals its Status.
+type ReadinessReport struct {
+	Status     string            `json:"status"` // ok, degraded (optional component down) or unavailable
+	CheckedAt  time.Time         `json:"checkedAt"`
+	Components []ComponentStatus `json:"components"`
+}
+
+// readinessChecker probes the API's dependencies.
+type readinessChecker struct {
+	fleetStream string
+	vaultPath   string
+	namespace   string
+	required    map[string]bool
+	now         func() time.Time
+
+	mu     sync.Mutex
+	last   ReadinessReport
+	cached bool
+}
+
+func newReadinessChecker(fleetStream, vaultPath, namespace string, required map[string]bool) *readinessChecker {
+	return &readinessChecker{fleetStream: fleetStream, vaultPath: vaultPath, namespace: namespace, required: required, now: time.Now}
+}
+
+// parseReadyRequired parses READY_REQUIRED, the comma-separated components
+// whose failure makes /api/ready return 503.
+func parseReadyRequired(spec string) (map[string]bool, error) {
+	required := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, c := range readyComponents {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown component %q (%s)", name, strings.Join(readyComponents, ", "))
+		}
+		required[name] = true
+	}
+	return required, nil
+}
+
+// checkNATS reports the connection state; the client reconnects on its own.
+func checkNATS(ctx context.Context) error {
+	if nc == nil {
+		return errors.New("not connected")
+	}
+	if !nc.IsConnected() {
+		return fmt.Errorf("connection %s", strings.ToLower(nc.Status().String()))
+	}
+	return nil
+}
+
+// checkJetStream looks up the fleet results stream the task history and
+// timelines read from.
+func (rc *readinessChecker) checkJetStream(ctx context.Context) error {
+	if js == nil {
+		return errors.New("not configured")
+	}
+	if _, err := js.Stream(ctx, rc.fleetStream); err != nil {
+		return fmt.Errorf("stream %s: %w", rc.fleetStream, err)
+	}
+	return nil
+}
+
+// checkKubernetes lists a pod, which the dashboard's service account may
+// do anyway, to check the API server answers within ctx.
+func (rc *readinessChecker) checkKubernetes(ctx context.Context) error {
+	if k8sClient == nil {
+		return errors.New("client not configured")
+	}
+	_, err := k8sClient.CoreV1().Pods(rc.namespace).List(ctx, metav1.ListOptions{Limit: 1})
+	return err
+}
+
+// checkCRDs verifies the API server serves every custom resource the
+// dashboard reads: listing one that isn't installed is a 404.
+func (rc *readinessChecker) checkCRDs(ctx context.Context) error {
+	if dynClient == nil {
+		return errors.New("client not configured")
+	}
+	var missing []string
+	for _, gvr := range []schema.GroupVersionResource{chainGVR, missionGVR, roundTableGVR, knightGVR} {
+		_, err := dynClient.Resource(gvr).Namespace(rc.namespace).List(ctx, metav1.ListOptions{Limit: 1})
+		switch {
+		case apierrors.IsNotFound(err):
+			missing = append(missing, gvr.Resource+"."+gvr.Group)
+		case err != nil:
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing CRDs: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkVault verifies the vault directory is mounted and readable.
+func (rc *readinessChecker) checkVault(ctx context.Context) error {
+	f, err := os.Open(rc.vaultPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// runReadyCheck times one check, giving up when ctx expires.
+func runReadyCheck(ctx context.Context, check func(context.Context) error) (time.Duration, error) {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return time.Since(start), err
+	case <-ctx.Done():
+		return time.Since(start), fmt.Errorf("timed out after %s", readyCheckTimeout)
+	}
+}
+
+// report runs every check concurrently, or returns the cached report.
+func (rc *readinessChecker) report(ctx context.Context) ReadinessReport {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	now := rc.now()
+	if rc.cached && now.Sub(rc.last.CheckedAt) < readyCacheTTL {
+		return rc.last
+	}
+
+	checks := map[string]func(context.Context) error{
+		"nats":       checkNATS,
+		"jetstream":  rc.checkJetStream,
+		"kubernetes": rc.checkKubernetes,
+		"crds":       rc.checkCRDs,
+		"vault":      rc.checkVault,
+	}
+	// The report is shared with concurrent callers, so one client hanging
+	// up mustn't fail it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readyCheckTimeout)
+	defer cancel()
+	results := make([]ComponentStatus, len(readyComponents))
+	var wg sync.WaitGroup
+	for i, name := range readyComponents {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			latency, err := runReadyCheck(ctx, checks[name])
+			c := ComponentStatus{Name: name, Status: "ok", Required: rc.required[name], LatencyMs: math.Round(latency.Seconds()*1e4) / 10}
+			if err != nil {
+				c.Status, c.Error = "down", err.Error()
+			}
+			results[i] = c
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := ReadinessReport{Status: "ok", CheckedAt: now, Components: results}
+	for _, c := range results {
+		switch {
+		case c.Status == "ok":
+		case c.Required:
+			report.Status = "unavailable"
+		case report.Status == "ok":
+			report.Status = "degraded"
+		}
+	}
+	rc.last, rc.cached = report, true
+	return report
+}
+
+// probeRequest reports whether r is a health probe, which bypasses auth,
+// RBAC and rate limiting: kubelets and load balancers carry no credentials.
+// The verbose health report names internal endpoints, so it is not one.
+func probeRequest(r *http.Request) bool {
+	return r.URL.Path == "/api/ready" || (r.URL.Path == "/api/health" && !verboseHealth(r))
+}
+
+// verboseHealth reports whether GET /health asks for the component report.
+func verboseHealth(r *http.Request) bool {
+	v := r.URL.Query().Get("verbose")
+	return v == "1" || v == "true"
+}
+
+// readyHandler serves GET /ready for kubelets and load balancers: the
+// overall status only, with a 503 when a required component is down.
+// Component errors name paths, streams and API server responses, so they
+// stay behind authentication.
+func readyHandler(rc *readinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := rc.report(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status == "unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": report.Status})
+	}
+}
+
+// healthHandler serves GET /health: liveness, always {"status":"ok"} while
+// the process serves, or with verbose=1 the per-component report, always
+// with a 200 so the UI banner can show what is down.
+func healthHandler(rc *readinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !verboseHealth(r) {
+			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(rc.report(r.Context()))
+	}
+}
+
+// dependenciesHandler serves GET /health/dependencies, an alias of
+// GET /health?verbose=1.
+func dependenciesHandler(rc *readinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(rc.report(r.Context()))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// setupReadyClients installs fake Kubernetes clients serving the given
+// ai.roundtable.io resources, the rest answering 404; NATS stays
+// disconnected.
+func setupReadyClients(t *testing.T, resources ...string) *k8sfake.Clientset {
+	cs := k8sfake.NewSimpleClientset()
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		chainGVR: "ChainList", missionGVR: "MissionList", roundTableGVR: "RoundTableList", knightGVR: "KnightList",
+	})
+	served := map[string]bool{}
+	for _, res := range resources {
+		served[res] = true
+	}
+	dc.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gvr := action.GetResource()
+		if served[gvr.Resource] {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewNotFound(gvr.GroupResource(), "")
+	})
+	k8sClient, dynClient = cs, dc
+	t.Cleanup(func() { k8sClient, dynClient = nil, nil })
+	return cs
+}
+
+func componentByName(report ReadinessReport, name string) ComponentStatus {
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	return ComponentStatus{}
+}
+
+func TestReadinessReport(t *testing.T) {
+	setupReadyClients(t, "chains", "missions", "roundtables", "knights")
+	rc := newReadinessChecker("fleet_a_results", t.TempDir(), "roundtable", map[string]bool{"nats": true})
+
+	report := rc.report(context.Background())
+	if report.Status != "unavailable" || len(report.Components) != len(readyComponents) {
+		t.Fatalf("expected unavailable with every component, got %+v", report)
+	}
+	for _, name := range []string{"kubernetes", "crds", "vault"} {
+		if c := componentByName(report, name); c.Status != "ok" || c.Required {
+			t.Errorf("expected %s ok and optional, got %+v", name, c)
+		}
+	}
+	if c := componentByName(report, "nats"); c.Status != "down" || !c.Required || c.Error != "not connected" {
+		t.Errorf("expected nats down and required, got %+v", c)
+	}
+	if c := componentByName(report, "jetstream"); c.Status != "down" || c.Required {
+		t.Errorf("expected jetstream down and optional, got %+v", c)
+	}
+}
+
+func TestReadinessReportDegraded(t *testing.T) {
+	cs := setupReadyClients(t, "chains", "missions")
+	cs.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	rc := newReadinessChecker("fleet_a_results", "/nonexistent/vault", "roundtable", map[string]bool{})
+
+	report := rc.report(context.Background())
+	if report.Status != "degraded" {
+		t.Errorf("expected degraded with only optional components down, got %s", report.Status)
+	}
+	if c := componentByName(report, "kubernetes"); c.Status != "down" || c.Error != "connection refused" {
+		t.Errorf("expected kubernetes down, got %+v", c)
+	}
+	c := componentByName(report, "crds")
+	if c.Status != "down" || !strings.Contains(c.Error, "roundtables.ai.roundtable.io, knights.ai.roundtable.io") || strings.Contains(c.Error, "chains") {
+		t.Errorf("expected the missing CRDs named, got %+v", c)
+	}
+	if c := componentByName(report, "vault"); c.Status != "down" {
+		t.Errorf("expected vault down, got %+v", c)
+	}
+
+	k8sClient, dynClient = nil, nil
+	rc = newReadinessChecker("fleet_a_results", t.TempDir(), "roundtable", map[string]bool{})
+	report = rc.report(context.Background())
+	if c := componentByName(report, "kubernetes"); c.Error != "client not configured" {
+		t.Errorf("expected nil clients reported, got %+v", c)
+	}
+}
+
+func TestReadinessReportCached(t *testing.T) {
+	setupReadyClients(t, "chains", "missions", "roundtables", "knights")
+	vault := t.TempDir()
+	rc := newReadinessChecker("fleet_a_results", vault, "roundtable", map[string]bool{})
+	now := time.Now()
+	rc.now = func() time.Time { return now }
+
+	first := rc.report(context.Background())
+	os.Remove(vault)
+	if got := rc.report(context.Background()); !got.CheckedAt.Equal(first.CheckedAt) || componentByName(got, "vault").Status != "ok" {
+		t.Error("expected the report reused within the cache TTL")
+	}
+	now = now.Add(readyCacheTTL)
+	if got := rc.report(context.Background()); componentByName(got, "vault").Status != "down" {
+		t.Error("expected a fresh report after the cache TTL")
+	}
+}
+
+func TestRunReadyCheckTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	block := make(chan struct{})
+	defer close(block)
+	_, err := runReadyCheck(ctx, func(context.Context) error { <-block; return nil })
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a hung check to time out, got %v", err)
+	}
+}
+
+func TestParseReadyRequired(t *testing.T) {
+	required, err := parseReadyRequired("nats, crds")
+	if err != nil || !required["nats"] || !required["crds"] || len(required) != 2 {
+		t.Errorf("unexpected %v %v", required, err)
+	}
+	if _, err := parseReadyRequired("nats,redis"); err == nil {
+		t.Error("expected unknown component rejected")
+	}
+}
+
+func TestReadyEndpoints(t *testing.T) {
+	os.Setenv("DASHBOARD_API_KEY", "secret")
+	defer os.Unsetenv("DASHBOARD_API_KEY")
+	setupReadyClients(t, "chains", "missions", "roundtables", "knights")
+
+	get := func(rc *readinessChecker, target, auth string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := mux.NewRouter()
+		r.Use(authMiddleware)
+		r.Use(rbacMiddleware)
+		r.HandleFunc("/api/ready", readyHandler(rc)).Methods("GET")
+		r.HandleFunc("/api/health", healthHandler(rc)).Methods("GET")
+		r.HandleFunc("/api/health/dependencies", dependenciesHandler(rc)).Methods("GET")
+		req := httptest.NewRequest("GET", target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	required := newReadinessChecker("fleet_a_results", t.TempDir(), "roundtable", map[string]bool{"nats": true})
+	if w, body := get(required, "/api/ready", ""); w.Code != http.StatusServiceUnavailable || len(body) != 1 || body["status"] != "unavailable" {
+		t.Errorf("expected a bare 503 without NATS, got %d %v", w.Code, body)
+	}
+	if w, body := get(required, "/api/health", ""); w.Code != http.StatusOK || len(body) != 1 || body["status"] != "ok" {
+		t.Errorf("expected bare liveness without auth, got %d %v", w.Code, body)
+	}
+	for _, target := range []string{"/api/health?verbose=1", "/api/health/dependencies"} {
+		if w, _ := get(required, target, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected component detail to require auth, got %d", target, w.Code)
+		}
+		if w, body := get(required, target, "secret"); w.Code != http.StatusOK || body["status"] != "unavailable" || body["components"] == nil {
+			t.Errorf("%s: expected the report with 200 when authenticated, got %d %v", target, w.Code, body)
+		}
+	}
+
+	optional := newReadinessChecker("fleet_a_results", t.TempDir(), "roundtable", map[string]bool{})
+	if w, body := get(optional, "/api/ready", ""); w.Code != http.StatusOK || body["status"] != "degraded" {
+		t.Errorf("expected 200 with only optional components down, got %d %v", w.Code, body)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MissionReport is the post-mission summary assembled from the Mission CR, its
+// chains, the chain-outputs and mission-results KV buckets.
+type MissionReport struct {
+	Name            string        `json:"name"`
+	Phase           string        `json:"phase"`
+	Objective       string        `json:"objective"`
+	SuccessCriteria string        `json:"successCriteria,omitempty"`
+	RoundTableRef   string        `json:"roundTableRef,omitempty"`
+	StartedAt       *string       `json:"startedAt"`
+	CompletedAt     *string       `json:"completedAt"`
+	CostBudgetUSD   string        `json:"costBudgetUSD"`
+	TotalCost       string        `json:"totalCost"`
+	Knights         []string      `json:"knights"`
+	Chains          []ReportChain `json:"chains"`
+	Results         string        `json:"results,omitempty"`
+	GeneratedAt     time.Time     `json:"generatedAt"`
+}
+
+// ReportChain is one chain of a mission with the output of every step.
+type ReportChain struct {
+	Name        string       `json:"name"`
+	ChainCRName string       `json:"chainCRName"`
+	Phase       string       `json:"phase"`
+	Steps       []ReportStep `json:"steps"`
+}
+
+// ReportStep is a chain step and its full (untruncated) output.
+type ReportStep struct {
+	Name   string `json:"name"`
+	Knight string `json:"knight,omitempty"`
+	Phase  string `json:"phase"`
+	Output string `json:"output,omitempty"`
+}
+
+// kvString reads a single KV value without creating the bucket, as role
+// under policy: values are redacted, and hidden or unreadable keys, missing
+// buckets/keys and an unavailable JetStream all read as "absent".
+func kvString(ctx context.Context, policy *kvPolicy, role, bucket, key string) (string, bool) {
+	if js == nil || !policy.canReadKey(role, bucket, key) {
+		return "", false
+	}
+	kv, err := js.KeyValue(ctx, bucket)
+	if err != nil {
+		return "", false
+	}
+	entry, err := kv.Get(ctx, key)
+	if err != nil {
+		return "", false
+	}
+	return string(policy.redactValue(entry.Value())), true
+}
+
+// buildMissionReport assembles the report for a mission. Chain outputs come
+// from the chain-outputs bucket (key <chainCR>.<step>), falling back to the
+// step output recorded on the Chain CR status. KV values are read as role
+// under policy, like the KV browser would serve them.
+func buildMissionReport(ctx context.Context, namespace, name string, policy *kvPolicy, role string) (*MissionReport, error) {
+	obj, err := dynClient.Resource(missionGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	mission := parseMissionResource(obj.Object)
+
+	report := &MissionReport{
+		Name:            mission.Name,
+		Phase:           mission.Phase,
+		Objective:       mission.Objective,
+		SuccessCriteria: mission.SuccessCriteria,
+		RoundTableRef:   mission.RoundTableRef,
+		StartedAt:       mission.StartedAt,
+		CompletedAt:     mission.CompletedAt,
+		CostBudgetUSD:   mission.CostBudgetUSD,
+		TotalCost:       mission.TotalCost,
+		Knights:         mission.Knights,
+		Chains:          []ReportChain{},
+		GeneratedAt:     time.Now().UTC(),
+	}
+
+	knightDomains := getKnightDomainMap(ctx, namespace)
+	for _, cs := range mission.ChainStatuses {
+		rc := ReportChain{Name: cs.Name, ChainCRName: cs.ChainCRName, Phase: cs.Phase, Steps: []ReportStep{}}
+		if cs.ChainCRName != "" {
+			if chainObj, cerr := dynClient.Resource(chainGVR).Namespace(namespace).Get(ctx, cs.ChainCRName, metav1.GetOptions{}); cerr == nil {
+				// Full outputs from the CR status — parseChainResource truncates them
+				statusOutputs := map[string]string{}
+				for _, s := range getSlice(getNestedMap(chainObj.Object, "status"), "stepStatuses") {
+					if sm, ok := s.(map[string]interface{}); ok {
+						statusOutputs[getStr(sm, "name")] = getStr(sm, "output")
+					}
+				}
+				for _, step := range parseChainResource(chainObj.Object, knightDomains).Steps {
+					output, ok := kvString(ctx, policy, role, "chain-outputs", cs.ChainCRName+"."+step.Name)
+					if !ok {
+						output = statusOutputs[step.Name]
+					}
+					rc.Steps = append(rc.Steps, ReportStep{
+						Name: step.Name, Knight: step.Knight, Phase: step.Phase, Output: output,
+					})
+				}
+			}
+		}
+		report.Chains = append(report.Chains, rc)
+	}
+
+	if results, ok := kvString(ctx, policy, role, "mission-results", name); ok {
+		report.Results = results
+	}
+	return report, nil
+}
+
+var reportFuncs = template.FuncMap{
+	"deref": func(s *string) string {
+		if s == nil {
+			return "—"
+		}
+		return *s
+	},
+	"fence": func(s string) string {
+		// Pick a fence longer than any backtick run in the output
+		fence := "```"
+		for strings.Contains(s, fence) {
+			fence += "`"
+		}
+		return fence
+	},
+}
+
+var reportMarkdownTmpl = template.Must(template.New("report.md").Funcs(reportFuncs).Parse(`---
+mission: {{.Name}}
+phase: {{.Phase}}
+{{- if .RoundTableRef}}
+roundTable: {{.RoundTableRef}}
+{{- end}}
+totalCost: "{{.TotalCost}}"
+generated: {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}
+tags: [mission-report]
+---
+
+# Mission Report: {{.Name}}
+
+| | |
+|---|---|
+| **Phase** | {{.Phase}} |
+| **Started** | {{deref .StartedAt}} |
+| **Completed** | {{deref .CompletedAt}} |
+| **Cost** | {{.TotalCost}}{{if .CostBudgetUSD}} of {{.CostBudgetUSD}} budget{{end}} |
+{{- if .Knights}}
+| **Knights** | {{range $i, $k := .Knights}}{{if $i}}, {{end}}{{$k}}{{end}} |
+{{- end}}
+
+## Objective
+
+{{.Objective}}
+{{if .SuccessCriteria}}
+## Success Criteria
+
+{{.SuccessCriteria}}
+{{end}}
+## Chains
+{{range .Chains}}
+### {{.Name}} ({{.Phase}})
+{{range .Steps}}
+#### {{.Name}}{{if .Knight}} — {{.Knight}}{{end}} ({{.Phase}})
+{{if .Output}}
+{{fence .Output}}
+{{.Output}}
+{{fence .Output}}
+{{end}}{{end}}{{else}}
+_No chains._
+{{end}}
+{{- if .Results}}
+## Results
+
+{{fence .Results}}
+{{.Results}}
+{{fence .Results}}
+{{end}}`))
+
+var reportHTMLTmpl = htmltemplate.Must(htmltemplate.New("report.html").Funcs(htmltemplate.FuncMap{
+	"deref": reportFuncs["deref"],
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Mission Report: {{.Name}}</title>
+</head>
+<body>
+<h1>Mission Report: {{.Name}}</h1>
+<table>
+<tr><th>Phase</th><td>{{.Phase}}</td></tr>
+<tr><th>Started</th><td>{{deref .StartedAt}}</td></tr>
+<tr><th>Completed</th><td>{{deref .CompletedAt}}</td></tr>
+<tr><th>Cost</th><td>{{.TotalCost}}{{if .CostBudgetUSD}} of {{.CostBudgetUSD}} budget{{end}}</td></tr>
+{{- if .Knights}}
+<tr><th>Knights</th><td>{{range $i, $k := .Knights}}{{if $i}}, {{end}}{{$k}}{{end}}</td></tr>
+{{- end}}
+</table>
+<h2>Objective</h2>
+<p>{{.Objective}}</p>
+{{- if .SuccessCriteria}}
+<h2>Success Criteria</h2>
+<p>{{.SuccessCriteria}}</p>
+{{- end}}
+<h2>Chains</h2>
+{{- range .Chains}}
+<h3>{{.Name}} ({{.Phase}})</h3>
+{{- range .Steps}}
+<h4>{{.Name}}{{if .Knight}} — {{.Knight}}{{end}} ({{.Phase}})</h4>
+{{- if .Output}}
+<pre>{{.Output}}</pre>
+{{- end}}
+{{- end}}
+{{- else}}
+<p><em>No chains.</em></p>
+{{- end}}
+{{- if .Results}}
+<h2>Results</h2>
+<pre>{{.Results}}</pre>
+{{- end}}
+<footer>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</footer>
+</body>
+</html>
+`))
+
+// renderMissionReport renders a report as md (default), html or json.
+func renderMissionReport(report *MissionReport, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "", "md", "markdown":
+		if err := reportMarkdownTmpl.Execute(&buf, report); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/markdown; charset=utf-8", nil
+	case "html":
+		if err := reportHTMLTmpl.Execute(&buf, report); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	case "json":
+		data, err := json.Marshal(report)
+		return data, "application/json", err
+	}
+	return nil, "", fmt.Errorf("unsupported format %q (allowed: md, html, json)", format)
+}
+
+// writeFileAtomic writes data to a temp file in the target directory and
+// renames it into place, so Obsidian (or a concurrent reader) never sees a
+// half-written note.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+func missionReportHandler(namespace string, policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "md" && format != "markdown" && format != "html" && format != "json" {
+			http.Error(w, "Invalid format (allowed: md, html, json)", http.StatusBadRequest)
+			return
+		}
+
+		report, err := buildMissionReport(r.Context(), namespace, name, policy, requestRole(r))
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+
+		body, contentType, err := renderMissionReport(report, format)
+		if err != nil {
+			slog.Error("Mission report render error", "mission", name, "error", err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}
+}
+
+// missionReportSaveHandler writes the Markdown report into the vault under
+// Missions/ (next to Briefings/) so it shows up in Obsidian. Missions must be
+// one of the VAULT_WRITE_DIRS, and an existing report is only replaced with
+// ?overwrite=true or If-Match carrying its ETag, so edits made in Obsidian
+// aren't lost.
+func missionReportSaveHandler(namespace, vaultPath string, writeDirs []string, policy *kvPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+		path, relPath, err := resolveVaultWritePath(vaultPath, writeDirs, "Missions", name)
+		if err != nil {
+			http.Error(w, "Vault writes to Missions are disabled (add it to VAULT_WRITE_DIRS)", http.StatusForbidden)
+			return
+		}
+
+		report, err := buildMissionReport(r.Context(), namespace, name, policy, requestRole(r))
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+		body, _, err := renderMissionReport(report, "md")
+		if err != nil {
+			slog.Error("Mission report render error", "mission", name, "error", err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+
+		vaultWriteMu.Lock()
+		defer vaultWriteMu.Unlock()
+		expected := ""
+		if current, err := os.ReadFile(path); err == nil {
+			expected = contentETag(current)
+			if r.Header.Get("If-Match") == "" && r.URL.Query().Get("overwrite") != "true" {
+				w.Header().Set("ETag", expected)
+				http.Error(w, "Report already exists; send If-Match with its ETag or ?overwrite=true to replace it", http.StatusConflict)
+				return
+			}
+		}
+		if !ifMatchSatisfied(r, expected, expected != "") {
+			if expected != "" {
+				w.Header().Set("ETag", expected)
+			}
+			http.Error(w, "Report changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+		ok, err := writeNoteChecked(path, expected, body)
+		if err != nil {
+			slog.Error("Vault write error", "path", path, "error", err)
+			http.Error(w, "Failed to write report", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Report changed while writing", http.StatusPreconditionFailed)
+			return
+		}
+
+		status := http.StatusCreated
+		if expected != "" {
+			status = http.StatusOK
+		}
+		w.Header().Set("ETag", contentETag(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mission": name,
+			"path":    relPath,
+			"saved":   true,
+		})
+	}
+}
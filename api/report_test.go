@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// setupReportRouter seeds a completed mission with one chain and returns a
+// router serving the report endpoints against a temporary vault.
+func setupReportRouter(t *testing.T) (*mux.Router, string) {
+	setupTestRouter() // initializes the fake K8s clients
+	ctx := context.Background()
+
+	mission := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata": map[string]interface{}{
+				"name":      "recon",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"objective":       "Map the <perimeter>",
+				"successCriteria": "All gates documented",
+				"costBudgetUSD":   "10.00",
+				"knights": []interface{}{
+					map[string]interface{}{"name": "galahad"},
+				},
+			},
+			"status": map[string]interface{}{
+				"phase":       "Succeeded",
+				"totalCost":   "2.50",
+				"startedAt":   "2024-01-01T10:00:00Z",
+				"completedAt": "2024-01-01T11:00:00Z",
+				"chainStatuses": []interface{}{
+					map[string]interface{}{
+						"name":        "sweep",
+						"chainCRName": "recon-sweep",
+						"phase":       "Succeeded",
+					},
+				},
+			},
+		},
+	}
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, mission, metav1.CreateOptions{})
+
+	chain := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Chain",
+			"metadata": map[string]interface{}{
+				"name":      "recon-sweep",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"steps": []interface{}{
+					map[string]interface{}{"name": "scan", "knightRef": "galahad"},
+				},
+			},
+			"status": map[string]interface{}{
+				"phase": "Succeeded",
+				"stepStatuses": []interface{}{
+					map[string]interface{}{
+						"name":   "scan",
+						"phase":  "Succeeded",
+						"output": "North gate open " + strings.Repeat("x", 600),
+					},
+				},
+			},
+		},
+	}
+	dynClient.Resource(chainGVR).Namespace("test-namespace").Create(ctx, chain, metav1.CreateOptions{})
+
+	vault := t.TempDir()
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/missions/{name}/report", missionReportHandler("test-namespace", testKVPolicy)).Methods("GET")
+	api.HandleFunc("/missions/{name}/report", missionReportSaveHandler("test-namespace", vault, []string{"Missions"}, testKVPolicy)).Methods("POST")
+	return router, vault
+}
+
+// TestMissionReportFormats tests the md, html and json renderings
+func TestMissionReportFormats(t *testing.T) {
+	router, _ := setupReportRouter(t)
+
+	tests := []struct {
+		format      string
+		contentType string
+		contains    []string
+	}{
+		{"md", "text/markdown", []string{"# Mission Report: recon", "All gates documented", "#### scan — galahad (Succeeded)", "North gate open"}},
+		{"html", "text/html", []string{"<h1>Mission Report: recon</h1>", "Map the &lt;perimeter&gt;", "<pre>North gate open"}},
+		{"json", "application/json", []string{`"name":"recon"`, `"totalCost":"2.50"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/missions/recon/report?format="+tt.format, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("expected Content-Type %s, got %s", tt.contentType, ct)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("expected report to contain %q", want)
+				}
+			}
+		})
+	}
+
+	// Step outputs must not be truncated like the chain list view
+	req := httptest.NewRequest("GET", "/api/missions/recon/report?format=json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var report MissionReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if len(report.Chains) != 1 || len(report.Chains[0].Steps) != 1 {
+		t.Fatalf("expected one chain with one step, got %+v", report.Chains)
+	}
+	if len(report.Chains[0].Steps[0].Output) <= 500 {
+		t.Error("expected full step output in report")
+	}
+}
+
+// TestMissionReportErrors covers bad formats, names and unknown missions
+func TestMissionReportErrors(t *testing.T) {
+	router, _ := setupReportRouter(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"invalid format", "/api/missions/recon/report?format=pdf", http.StatusBadRequest},
+		{"invalid name", "/api/missions/1bad/report", http.StatusBadRequest},
+		{"unknown mission", "/api/missions/missing/report", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+// TestMissionReportSave verifies the report is written into the vault
+func TestMissionReportSave(t *testing.T) {
+	router, vault := setupReportRouter(t)
+
+	req := httptest.NewRequest("POST", "/api/missions/recon/report", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(vault, "Missions", "recon.md"))
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "---\nmission: recon\n") {
+		t.Errorf("expected front-matter at top of saved report, got %q", string(content)[:40])
+	}
+
+	// No temp files left behind by the atomic write
+	entries, _ := os.ReadDir(filepath.Join(vault, "Missions"))
+	if len(entries) != 1 {
+		t.Errorf("expected only the report in Missions/, got %d entries", len(entries))
+	}
+
+	// An existing report, e.g. edited in Obsidian, is only replaced on request
+	etag := w.Header().Get("ETag")
+	save := func(target, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := save("/api/missions/recon/report", ""); w.Code != http.StatusConflict || w.Header().Get("ETag") != etag {
+		t.Errorf("expected 409 with the current ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+	if w := save("/api/missions/recon/report", `"stale"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale If-Match, got %d", w.Code)
+	}
+	if w := save("/api/missions/recon/report", etag); w.Code != http.StatusOK {
+		t.Errorf("expected replace with the current ETag, got %d", w.Code)
+	}
+	if w := save("/api/missions/recon/report?overwrite=true", ""); w.Code != http.StatusOK {
+		t.Errorf("expected replace with overwrite=true, got %d", w.Code)
+	}
+
+	// Missions must be an allowed write dir
+	denied := httptest.NewRecorder()
+	handler := missionReportSaveHandler("test-namespace", vault, []string{"Notes"}, testKVPolicy)
+	handler(denied, mux.SetURLVars(httptest.NewRequest("POST", "/api/missions/recon/report", nil), map[string]string{"name": "recon"}))
+	if denied.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without Missions in VAULT_WRITE_DIRS, got %d", denied.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Caller roles, least to most privileged. roleNone is what OIDC users
+// outside every mapped group get when RBAC_DEFAULT_ROLE=none.
+const (
+	roleNone     = "none"
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+	roleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{
+	roleViewer:   1,
+	roleOperator: 2,
+	roleAdmin:    3,
+}
+
+type roleContextKey struct{}
+
+// withRole returns a context carrying the caller's role.
+func withRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleContextKey{}, role)
+}
+
+// requestRole returns the caller's role, or "" — which no check accepts —
+// for a request authMiddleware didn't authenticate. With auth disabled
+// authMiddleware grants admin explicitly.
+func requestRole(r *http.Request) string {
+	role, _ := r.Context().Value(roleContextKey{}).(string)
+	return role
+}
+
+// roleAtLeast reports whether role grants at least the privileges of min.
+func roleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+// forbidden writes a 403 naming the role the caller would need.
+func forbidden(w http.ResponseWriter, required string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":        "Forbidden",
+		"requiredRole": required,
+	})
+}
+
+// roleMapping assigns roles to authenticated identities.
+type roleMapping struct {
+	apiKeyRole  string            // callers using DASHBOARD_API_KEY
+	groupRoles  map[string]string // OIDC group -> role
+	defaultRole string            // OIDC users in no mapped group
+}
+
+// roles is the mapping authMiddleware applies. The default keeps the shared
+// API key at full access and makes OIDC users viewers.
+var roles = &roleMapping{apiKeyRole: roleAdmin, groupRoles: map[string]string{}, defaultRole: roleViewer}
+
+// loadRoleMapping parses RBAC_API_KEY_ROLE, RBAC_GROUP_ROLES
+// ("group=role,...") and RBAC_DEFAULT_ROLE.
+func loadRoleMapping(apiKeyRole, groupRoles, defaultRole string) (*roleMapping, error) {
+	validRole := func(role string, allowNone bool) bool {
+		return roleRank[role] > 0 || (allowNone && role == roleNone)
+	}
+	m := &roleMapping{apiKeyRole: apiKeyRole, groupRoles: map[string]string{}, defaultRole: defaultRole}
+	if !validRole(apiKeyRole, false) {
+		return nil, fmt.Errorf("invalid API key role %q", apiKeyRole)
+	}
+	if !validRole(defaultRole, true) {
+		return nil, fmt.Errorf("invalid default role %q", defaultRole)
+	}
+	for _, entry := range strings.Split(groupRoles, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !validRole(role, false) {
+			return nil, fmt.Errorf("invalid group mapping %q (want group=viewer|operator|admin)", entry)
+		}
+		if _, dup := m.groupRoles[group]; dup {
+			return nil, fmt.Errorf("group %q mapped twice", group)
+		}
+		m.groupRoles[group] = role
+	}
+	return m, nil
+}
+
+// roleFor returns the most privileged role the identity's groups map to.
+func (m *roleMapping) roleFor(id Identity) string {
+	switch id.Method {
+	case "api-key":
+		return m.apiKeyRole
+	case "oidc":
+		role := m.defaultRole
+		for _, g := range id.Groups {
+			if r, ok := m.groupRoles[g]; ok && roleRank[r] > roleRank[role] {
+				role = r
+			}
+		}
+		return role
+	case "anonymous":
+		return roleAdmin // auth disabled
+	}
+	return ""
+}
+
+// routeRoles overrides the per-method default (viewer to read, operator to
+// change anything) for routes, keyed by "METHOD /api/path-template".
+var routeRoles = map[string]string{
+	"DELETE /api/missions/{name}":   roleAdmin,
+	"POST /api/roundtables":         roleAdmin,
+	"PATCH /api/roundtables/{name}": roleAdmin,
+	"GET /api/auth/keys":            roleAdmin,
+	"GET /api/audit":                roleAdmin,
+	"POST /api/ws/ticket":           roleViewer,
+
+	// KV writes are gated per bucket by the KV policy's permissions
+	"PUT /api/kv/{bucket}/{key}":    roleViewer,
+	"DELETE /api/kv/{bucket}/{key}": roleViewer,
+}
+
+// requiredRole returns the minimum role for a method on a route template.
+func requiredRole(method, template string) string {
+	if role, ok := routeRoles[method+" "+template]; ok {
+		return role
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return roleViewer
+	}
+	return roleOperator
+}
+
+// rbacMiddleware rejects requests whose caller's role is below the matched
+// route's requirement with a 403 naming the required role, and requests
+// from named API keys lacking the route's scope with a 403 naming the scope.
+func rbacMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probeRequest(r) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if required := requiredRole(r.Method, template); !roleAtLeast(requestRole(r), required) {
+			forbidden(w, required)
+			return
+		}
+		if scope := routeScope(r.Method, template); !hasScope(requestIdentity(r), scope) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":         "Forbidden",
+				"requiredScope": scope,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+func TestLoadRoleMapping(t *testing.T) {
+	m, err := loadRoleMapping(roleOperator, "roundtable-admins=admin, roundtable-ops=operator", roleNone)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		id   Identity
+		role string
+	}{
+		{Identity{Method: "api-key"}, roleOperator},
+		{Identity{Method: "anonymous"}, roleAdmin},
+		{Identity{Method: "oidc", Groups: []string{"roundtable-ops", "roundtable-admins"}}, roleAdmin},
+		{Identity{Method: "oidc", Groups: []string{"roundtable-ops"}}, roleOperator},
+		{Identity{Method: "oidc", Groups: []string{"staff"}}, roleNone},
+	}
+	for _, tt := range tests {
+		if got := m.roleFor(tt.id); got != tt.role {
+			t.Errorf("roleFor(%+v) = %s, want %s", tt.id, got, tt.role)
+		}
+	}
+
+	for _, bad := range [][3]string{
+		{"root", "", "viewer"},
+		{"admin", "", "root"},
+		{"none", "", "viewer"},
+		{"admin", "ops", "viewer"},
+		{"admin", "ops=superuser", "viewer"},
+		{"admin", "=admin", "viewer"},
+		{"admin", "ops=admin,ops=viewer", "viewer"},
+	} {
+		if _, err := loadRoleMapping(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("expected %q rejected", bad)
+		}
+	}
+}
+
+func TestRequiredRole(t *testing.T) {
+	tests := []struct{ method, template, role string }{
+		{"GET", "/api/fleet", roleViewer},
+		{"GET", "/api/missions/{name}", roleViewer},
+		{"POST", "/api/tasks/dispatch", roleOperator},
+		{"POST", "/api/missions", roleOperator},
+		{"DELETE", "/api/missions/{name}", roleAdmin},
+		{"POST", "/api/roundtables", roleAdmin},
+		{"PATCH", "/api/roundtables/{name}/warmpool", roleOperator},
+		{"PUT", "/api/kv/{bucket}/{key}", roleViewer},
+	}
+	for _, tt := range tests {
+		if got := requiredRole(tt.method, tt.template); got != tt.role {
+			t.Errorf("requiredRole(%s %s) = %s, want %s", tt.method, tt.template, got, tt.role)
+		}
+	}
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	idp := newTestIdP(t)
+	oidcAuth, _ = newOIDCVerifier(idp.URL, "roundtable-ui", "", "", "")
+	roles, _ = loadRoleMapping(roleViewer, "knights=operator,admins=admin", roleViewer)
+	os.Setenv("DASHBOARD_API_KEY", "shared-key")
+	defer func() {
+		oidcAuth = nil
+		roles, _ = loadRoleMapping(roleAdmin, "", roleViewer)
+		os.Unsetenv("DASHBOARD_API_KEY")
+	}()
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.Use(rbacMiddleware)
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/fleet", ok).Methods("GET")
+	api.HandleFunc("/tasks/dispatch", ok).Methods("POST")
+	api.HandleFunc("/missions/{name}", ok).Methods("DELETE")
+	api.HandleFunc("/auth/me", authMeHandler).Methods("GET")
+
+	token := func(groups ...string) string {
+		return "Bearer " + idp.token(t, jwt.SigningMethodRS256, "rsa-1", idp.rsaKey, idp.claims(jwt.MapClaims{"groups": groups}))
+	}
+	viewer, operator, admin := token("staff"), token("knights"), token("knights", "admins")
+
+	tests := []struct {
+		auth, method, path string
+		expected           int
+		required           string
+	}{
+		{viewer, "GET", "/api/fleet", http.StatusOK, ""},
+		{viewer, "POST", "/api/tasks/dispatch", http.StatusForbidden, roleOperator},
+		{operator, "POST", "/api/tasks/dispatch", http.StatusOK, ""},
+		{operator, "DELETE", "/api/missions/m1", http.StatusForbidden, roleAdmin},
+		{admin, "DELETE", "/api/missions/m1", http.StatusOK, ""},
+		{"Bearer shared-key", "GET", "/api/fleet", http.StatusOK, ""},
+		{"Bearer shared-key", "POST", "/api/tasks/dispatch", http.StatusForbidden, roleOperator},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", tt.auth)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.expected, w.Code)
+			continue
+		}
+		if tt.required != "" {
+			var body map[string]string
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body["requiredRole"] != tt.required {
+				t.Errorf("%s %s: expected requiredRole %s, got %v", tt.method, tt.path, tt.required, body)
+			}
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/auth/me", nil)
+	req.Header.Set("Authorization", operator)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var me struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &me)
+	if me.Name != "arthur" || me.Role != roleOperator {
+		t.Errorf("expected /auth/me to report arthur as operator, got %s", w.Body.String())
+	}
+}
+
+// TestRBACFailsClosed denies requests that reach rbacMiddleware without a
+// role, while auth disabled still grants admin explicitly.
+func TestRBACFailsClosed(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	bare := mux.NewRouter()
+	bare.Use(rbacMiddleware)
+	bare.HandleFunc("/api/fleet", ok).Methods("GET")
+	w := httptest.NewRecorder()
+	bare.ServeHTTP(w, httptest.NewRequest("GET", "/api/fleet", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected a request without a role denied, got %d", w.Code)
+	}
+
+	open := mux.NewRouter()
+	open.Use(authMiddleware)
+	open.Use(rbacMiddleware)
+	open.HandleFunc("/api/missions/{name}", ok).Methods("DELETE")
+	w = httptest.NewRecorder()
+	open.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/missions/m1", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected admin access with auth disabled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxRollupDays bounds a rollup's date range (about a quarter).
+	maxRollupDays = 93
+	// maxRollupBytes keeps a dispatched rollup well under the NATS payload limit.
+	maxRollupBytes = 512 << 10
+
+	defaultRollupTimeout = 10 * time.Minute
+	maxRollupTimeout     = 30 * time.Minute
+	// rollupJobTTL is how long a rollup's outcome stays queryable.
+	rollupJobTTL = time.Hour
+)
+
+var (
+	validBriefingWeek   = regexp.MustCompile(`^\d{4}-W\d{2}$`)
+	validBriefingMonth  = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	validNoteName       = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+	validCollectionName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+)
+
+// briefingCollection is a vault folder of notes served under
+// /briefings/collections/{name}. Entry names must match pattern, which also
+// keeps them free of path separators.
+type briefingCollection struct {
+	Name    string `json:"name"`
+	Dir     string `json:"dir"`
+	Count   int    `json:"count"`
+	pattern *regexp.Regexp
+}
+
+// defaultBriefingCollections are always available; BRIEFING_COLLECTIONS adds
+// more.
+var defaultBriefingCollections = []briefingCollection{
+	{Name: "daily", Dir: "Briefings/Daily", pattern: validBriefingDate},
+	{Name: "weekly", Dir: "Briefings/Weekly", pattern: validBriefingWeek},
+	{Name: "monthly", Dir: "Briefings/Monthly", pattern: validBriefingMonth},
+}
+
+// loadBriefingCollections parses BRIEFING_COLLECTIONS
+// ("research=Research/Notes,standups=Team/Standups") on top of the defaults.
+// Directories are vault-relative and may not leave the vault.
+func loadBriefingCollections(spec string) ([]briefingCollection, error) {
+	collections := append([]briefingCollection{}, defaultBriefingCollections...)
+	seen := map[string]bool{}
+	for _, c := range collections {
+		seen[c.Name] = true
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, dir, ok := strings.Cut(item, "=")
+		if !ok || !validCollectionName.MatchString(name) {
+			return nil, fmt.Errorf("invalid briefing collection %q (want name=Vault/Dir)", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate briefing collection %q", name)
+		}
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("briefing collection %q must be a directory inside the vault", name)
+		}
+		seen[name] = true
+		collections = append(collections, briefingCollection{Name: name, Dir: dir, pattern: validNoteName})
+	}
+	return collections, nil
+}
+
+func findBriefingCollection(collections []briefingCollection, name string) (briefingCollection, bool) {
+	for _, c := range collections {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return briefingCollection{}, false
+}
+
+// briefingCollectionsHandler serves GET /briefings/collections with the
+// number of notes in each.
+func briefingCollectionsHandler(vaultPath string, collections []briefingCollection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := make([]briefingCollection, 0, len(collections))
+		for _, c := range collections {
+			if entries, err := os.ReadDir(filepath.Join(vaultPath, c.Dir)); err == nil {
+				for _, e := range entries {
+					if !e.IsDir() && strings.HasSuffix(e.Name(), ".md") {
+						c.Count++
+					}
+				}
+			}
+			out = append(out, c)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+// briefingCollectionListHandler serves GET /briefings/collections/{collection}.
+// A collection whose folder doesn't exist yet is empty rather than missing.
+func briefingCollectionListHandler(vaultPath string, collections []briefingCollection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := findBriefingCollection(collections, mux.Vars(r)["collection"])
+		if !ok {
+			http.Error(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		briefings, err := listBriefings(filepath.Join(vaultPath, c.Dir))
+		if err != nil && !os.IsNotExist(err) {
+			http.Error(w, "Collection unreadable", http.StatusInternalServerError)
+			return
+		}
+		if briefings == nil {
+			briefings = []BriefingSummary{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(briefings)
+	}
+}
+
+// briefingCollectionEntryHandler serves GET
+// /briefings/collections/{collection}/{entry} like briefingHandler.
+func briefingCollectionEntryHandler(vaultPath string, collections []briefingCollection) http.HandlerFunc {
+	links := newVaultLinkResolver(vaultPath, time.Minute)
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		c, ok := findBriefingCollection(collections, vars["collection"])
+		if !ok {
+			http.Error(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		entry := vars["entry"]
+		if !c.pattern.MatchString(entry) || strings.Contains(entry, "..") {
+			http.Error(w, "Invalid entry name", http.StatusBadRequest)
+			return
+		}
+		serveBriefing(w, r, filepath.Clean(filepath.Join(vaultPath, c.Dir)), entry, links)
+	}
+}
+
+// parseRollupRange validates a from/to pair of YYYY-MM-DD dates.
+func parseRollupRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01-02", fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from date (want YYYY-MM-DD)")
+	}
+	to, err := time.Parse("2006-01-02", toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to date (want YYYY-MM-DD)")
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to is before from")
+	}
+	if to.Sub(from) >= maxRollupDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range exceeds %d days", maxRollupDays)
+	}
+	return from, to, nil
+}
+
+// demoteHeadings pushes Markdown headings down by levels (capped at h6),
+// leaving fenced code alone, so daily briefings nest under the rollup's
+// per-day headings.
+func demoteHeadings(body string, levels int) string {
+	lines := strings.Split(body, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence || !headingPattern.MatchString(line) {
+			continue
+		}
+		depth := len(line) - len(strings.TrimLeft(line, "#"))
+		lines[i] = strings.Repeat("#", min(6, depth+levels)) + line[depth:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// buildRollup concatenates the daily briefings from..to (oldest first) into
+// one Markdown document, returning the dates included.
+func buildRollup(dailyDir string, from, to time.Time) ([]byte, []string, error) {
+	var days bytes.Buffer
+	dates := []string{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		content, err := os.ReadFile(filepath.Join(dailyDir, date+".md"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		_, body := splitFrontMatter(string(content))
+		fmt.Fprintf(&days, "\n## %s\n\n%s\n", date, strings.TrimSpace(demoteHeadings(body, 2)))
+		dates = append(dates, date)
+	}
+
+	title := fmt.Sprintf("Briefings %s to %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	fm, _ := yaml.Marshal(map[string]interface{}{
+		"title":     title,
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"briefings": dates,
+		"tags":      []string{"rollup"},
+	})
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "---\n%s---\n# %s\n", fm, title)
+	doc.Write(days.Bytes())
+	return doc.Bytes(), dates, nil
+}
+
+// briefingRollupHandler serves GET /briefings/rollup?from=&to=: the daily
+// briefings in the range as one Markdown document.
+func briefingRollupHandler(vaultPath string) http.HandlerFunc {
+	dailyDir := filepath.Join(vaultPath, "Briefings", "Daily")
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseRollupRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+		if err != nil {
+			http.Error(w, "Invalid range: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc, dates, err := buildRollup(dailyDir, from, to)
+		if err != nil {
+			slog.Error("Briefing rollup error", "error", err)
+			http.Error(w, "Failed to read briefings", http.StatusInternalServerError)
+			return
+		}
+		if len(dates) == 0 {
+			http.Error(w, "No briefings in range", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown")
+		w.Write(doc)
+	}
+}
+
+// rollupRequest is the body of POST /briefings/rollup. Collection and name
+// default to the ISO week or the month the range falls in. An existing note
+// is only replaced with overwrite set.
+type rollupRequest struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Knight       string `json:"knight"`
+	Domain       string `json:"domain"`
+	Collection   string `json:"collection,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	Timeout      int    `json:"timeout_ms,omitempty"`
+	Overwrite    bool   `json:"overwrite,omitempty"`
+}
+
+// rollupTarget resolves where a summarized rollup is saved. Without a name,
+// weekly and monthly rollups are named after the one week or month the
+// range falls in; ranges spanning more need a name or another collection.
+func rollupTarget(req rollupRequest, collections []briefingCollection, from, to time.Time) (briefingCollection, string, error) {
+	fromYear, fromWeek := from.ISOWeek()
+	toYear, toWeek := to.ISOWeek()
+	sameWeek := fromYear == toYear && fromWeek == toWeek
+	sameMonth := from.Format("2006-01") == to.Format("2006-01")
+
+	collection := req.Collection
+	if collection == "" {
+		switch {
+		case sameWeek:
+			collection = "weekly"
+		case sameMonth:
+			collection = "monthly"
+		default:
+			return briefingCollection{}, "", fmt.Errorf("range spans more than one month; pick a collection and name")
+		}
+	}
+	c, ok := findBriefingCollection(collections, collection)
+	if !ok || c.Name == "daily" {
+		return c, "", fmt.Errorf("unknown or read-only collection %q", collection)
+	}
+	name := req.Name
+	if name == "" {
+		switch {
+		case c.Name == "weekly" && sameWeek:
+			name = fmt.Sprintf("%d-W%02d", fromYear, fromWeek)
+		case c.Name == "monthly" && sameMonth:
+			name = from.Format("2006-01")
+		case c.Name == "weekly" || c.Name == "monthly":
+			return c, "", fmt.Errorf("range is not a single %s period; give the rollup a name", strings.TrimSuffix(c.Name, "ly"))
+		default:
+			name = fmt.Sprintf("rollup-%s-to-%s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+		}
+	}
+	if !c.pattern.MatchString(name) || strings.Contains(name, "..") {
+		return c, "", fmt.Errorf("invalid name %q for collection %s", name, c.Name)
+	}
+	return c, name, nil
+}
+
+// rollupNote wraps a knight's summary in front-matter recording where it
+// came from. A failed or empty result is an error.
+func rollupNote(result []byte, req rollupRequest, taskID string, generated time.Time) ([]byte, error) {
+	var payload struct {
+		Success *bool  `json:"success"`
+		Result  string `json:"result"`
+	}
+	summary := string(result)
+	if err := json.Unmarshal(result, &payload); err == nil {
+		if payload.Success != nil && !*payload.Success {
+			return nil, fmt.Errorf("knight reported failure: %s", payload.Result)
+		}
+		summary = payload.Result
+	}
+	if strings.TrimSpace(summary) == "" {
+		return nil, fmt.Errorf("empty summary")
+	}
+	fm, _ := yaml.Marshal(map[string]interface{}{
+		"title":     fmt.Sprintf("Briefing rollup %s to %s", req.From, req.To),
+		"from":      req.From,
+		"to":        req.To,
+		"knight":    req.Knight,
+		"task_id":   taskID,
+		"generated": generated.UTC().Format(time.RFC3339),
+		"tags":      []string{"rollup", "summary"},
+	})
+	return []byte(fmt.Sprintf("---\n%s---\n%s\n", fm, strings.TrimSpace(summary))), nil
+}
+
+// briefingRollupDispatchHandler serves POST /briefings/rollup: it sends the
+// rollup to a knight for summarization and returns 202 straight away. A
+// background wait picks up <prefix>.results.<task_id> and writes the summary
+// into the target collection.
+func briefingRollupDispatchHandler(vaultPath, fleetPrefix string, collections []briefingCollection) http.HandlerFunc {
+	dailyDir := filepath.Join(vaultPath, "Briefings", "Daily")
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req rollupRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		from, to, err := parseRollupRange(req.From, req.To)
+		if err != nil {
+			http.Error(w, "Invalid range: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Validate inputs to prevent NATS subject injection
+		if !validKnightName.MatchString(req.Knight) || !validKnightName.MatchString(req.Domain) {
+			http.Error(w, "Invalid knight or domain name", http.StatusBadRequest)
+			return
+		}
+		if len(req.Instructions) > 10000 {
+			http.Error(w, "Instructions must be at most 10000 characters", http.StatusBadRequest)
+			return
+		}
+		timeout := defaultRollupTimeout
+		if req.Timeout > 0 {
+			timeout = time.Duration(req.Timeout) * time.Millisecond
+		}
+		if timeout > maxRollupTimeout {
+			http.Error(w, fmt.Sprintf("timeout_ms may not exceed %d", maxRollupTimeout.Milliseconds()), http.StatusBadRequest)
+			return
+		}
+		c, name, err := rollupTarget(req, collections, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		relPath := c.Dir + "/" + name + ".md"
+		path := filepath.Join(vaultPath, filepath.FromSlash(relPath))
+		// The note the result may replace, checked again before writing so
+		// an edit made while the knight works isn't lost
+		expected := ""
+		current, err := os.ReadFile(path)
+		switch {
+		case err == nil && !req.Overwrite:
+			http.Error(w, fmt.Sprintf("%s already exists; send \"overwrite\": true to replace it", relPath), http.StatusConflict)
+			return
+		case err == nil:
+			expected = contentETag(current)
+		case !os.IsNotExist(err):
+			slog.Error("Vault read error", "path", path, "error", err)
+			http.Error(w, "Failed to read the vault", http.StatusInternalServerError)
+			return
+		}
+
+		if nc == nil {
+			http.Error(w, "NATS not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		doc, dates, err := buildRollup(dailyDir, from, to)
+		if err != nil {
+			slog.Error("Briefing rollup error", "error", err)
+			http.Error(w, "Failed to read briefings", http.StatusInternalServerError)
+			return
+		}
+		if len(dates) == 0 {
+			http.Error(w, "No briefings in range", http.StatusNotFound)
+			return
+		}
+		if len(doc) > maxRollupBytes {
+			http.Error(w, fmt.Sprintf("Rollup is %d bytes (limit %d); use a shorter range", len(doc), maxRollupBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		instructions := req.Instructions
+		if instructions == "" {
+			instructions = "Summarize these daily briefings into one Markdown rollup: key events, recurring issues, decisions and open follow-ups. Reply with the Markdown only."
+		}
+		taskID := fmt.Sprintf("%s-rollup-%d", req.Knight, time.Now().UnixMilli())
+		subject := fmt.Sprintf("%s.tasks.%s.%s", fleetPrefix, req.Domain, taskID)
+		payload, _ := json.Marshal(map[string]interface{}{
+			"from":    "ui",
+			"task_id": taskID,
+			"domain":  req.Domain,
+			"task":    instructions + "\n\n" + string(doc),
+			"metadata": map[string]interface{}{
+				"type":       "briefing-rollup",
+				"source":     "dashboard",
+				"timeout_ms": timeout.Milliseconds(),
+			},
+		})
+
+		// Subscribe before publishing so a fast knight can't beat us to it
+		sub, err := nc.SubscribeSync(fmt.Sprintf("%s.results.%s", fleetPrefix, taskID))
+		if err != nil {
+			slog.Error("NATS subscribe error", "error", err)
+			http.Error(w, "Failed to dispatch rollup", http.StatusInternalServerError)
+			return
+		}
+		if err := natsPublish(r.Context(), subject, "{prefix}.tasks.{domain}.{task_id}", payload); err != nil {
+			sub.Unsubscribe()
+			slog.Error("NATS publish error", "error", err)
+			http.Error(w, "Failed to dispatch rollup", http.StatusInternalServerError)
+			return
+		}
+
+		rollups.set(rollupJob{TaskID: taskID, Path: relPath, Status: "dispatched"})
+		go saveRollupResult(sub, timeout, path, expected, relPath, req, taskID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"task_id":    taskID,
+			"subject":    subject,
+			"briefings":  dates,
+			"path":       relPath,
+			"status":     "dispatched",
+			"status_url": "/api/briefings/rollup/" + taskID,
+		})
+	}
+}
+
+// saveRollupResult waits for the knight's result and writes it to path,
+// recording the outcome in rollups.
+func saveRollupResult(sub *nats.Subscription, timeout time.Duration, path, expected, relPath string, req rollupRequest, taskID string) {
+	defer sub.Unsubscribe()
+	job := rollupJob{TaskID: taskID, Path: relPath, Status: "failed"}
+	defer func() { rollups.set(job) }()
+
+	msg, err := sub.NextMsg(timeout)
+	if err != nil {
+		slog.Warn("Briefing rollup result not received", "task_id", taskID, "error", err)
+		job.Error = "no result within the timeout"
+		return
+	}
+	note, err := rollupNote(msg.Data, req, taskID, time.Now())
+	if err != nil {
+		slog.Warn("Briefing rollup not saved", "task_id", taskID, "error", err)
+		job.Error = err.Error()
+		return
+	}
+	job.Status, job.Error = storeRollup(path, expected, note)
+	if job.Status == "saved" {
+		slog.Info("Briefing rollup saved", "task_id", taskID, "path", path)
+	}
+}
+
+// storeRollup writes a rollup note unless the file changed since dispatch,
+// returning the job status and, if it wasn't saved, why.
+func storeRollup(path, expected string, note []byte) (string, string) {
+	vaultWriteMu.Lock()
+	defer vaultWriteMu.Unlock()
+	written, err := writeNoteChecked(path, expected, note)
+	switch {
+	case err != nil:
+		slog.Error("Vault write error", "path", path, "error", err)
+		return "failed", "vault write failed"
+	case !written:
+		slog.Warn("Briefing rollup not saved, note changed since dispatch", "path", path)
+		return "conflict", "the note changed since the rollup was dispatched"
+	}
+	return "saved", ""
+}
+
+// rollupJob is the outcome of a dispatched rollup, served by
+// GET /briefings/rollup/{task_id}.
+type rollupJob struct {
+	TaskID  string    `json:"task_id"`
+	Path    string    `json:"path"`
+	Status  string    `json:"status"` // dispatched, saved, conflict or failed
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// rollupJobs keeps recent rollup outcomes in memory.
+type rollupJobs struct {
+	mu   sync.Mutex
+	jobs map[string]rollupJob
+}
+
+var rollups = &rollupJobs{jobs: map[string]rollupJob{}}
+
+// set records a job's state, dropping outcomes older than rollupJobTTL.
+func (j *rollupJobs) set(job rollupJob) {
+	job.Updated = time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, old := range j.jobs {
+		if job.Updated.Sub(old.Updated) > rollupJobTTL {
+			delete(j.jobs, id)
+		}
+	}
+	j.jobs[job.TaskID] = job
+}
+
+func (j *rollupJobs) get(taskID string) (rollupJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[taskID]
+	return job, ok
+}
+
+// briefingRollupStatusHandler serves GET /briefings/rollup/{task_id}: whether
+// a dispatched rollup was saved, and why not otherwise.
+func briefingRollupStatusHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["task_id"]
+	if !validTaskID.MatchString(taskID) {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+	job, ok := rollups.get(taskID)
+	if !ok {
+		http.Error(w, "Rollup not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
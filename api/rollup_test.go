@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLoadBriefingCollections(t *testing.T) {
+	collections, err := loadBriefingCollections("research=Research/Notes/, standups=Team/Standups")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collections) != 5 {
+		t.Fatalf("expected 3 defaults + 2 configured, got %d", len(collections))
+	}
+	if c, ok := findBriefingCollection(collections, "research"); !ok || c.Dir != "Research/Notes" {
+		t.Errorf("unexpected research collection %+v", c)
+	}
+
+	for _, bad := range []string{
+		"research",
+		"Research=Notes",
+		"daily=Other",
+		"a=Notes,a=More",
+		"escape=../etc",
+		"root=.",
+		"abs=/etc",
+	} {
+		if _, err := loadBriefingCollections(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestDemoteHeadings(t *testing.T) {
+	in := "# Title\n## Section\n```\n# comment\n```\n###### Deep\n#tag"
+	want := "### Title\n#### Section\n```\n# comment\n```\n###### Deep\n#tag"
+	if got := demoteHeadings(in, 2); got != want {
+		t.Errorf("demoteHeadings() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBriefingCollectionsAndRollup(t *testing.T) {
+	vault := t.TempDir()
+	daily := filepath.Join(vault, "Briefings", "Daily")
+	writeTestBriefing(t, daily, "2024-01-01.md", "---\ntags: [x]\n---\n# Monday\n\nAll quiet.")
+	writeTestBriefing(t, daily, "2024-01-03.md", "# Wednesday\n\n## Incidents\n\nGalahad timed out.")
+	writeTestBriefing(t, daily, "2024-02-01.md", "# February")
+	writeTestBriefing(t, filepath.Join(vault, "Briefings", "Weekly"), "2024-W01.md", "# Week one")
+	writeTestBriefing(t, filepath.Join(vault, "Research"), "llm-notes.md", "# Notes")
+
+	collections, _ := loadBriefingCollections("research=Research")
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings/rollup", briefingRollupHandler(vault)).Methods("GET")
+	router.HandleFunc("/api/briefings/rollup", briefingRollupDispatchHandler(vault, "fleet-a", collections)).Methods("POST")
+	router.HandleFunc("/api/briefings/collections", briefingCollectionsHandler(vault, collections)).Methods("GET")
+	router.HandleFunc("/api/briefings/collections/{collection}", briefingCollectionListHandler(vault, collections)).Methods("GET")
+	router.HandleFunc("/api/briefings/collections/{collection}/{entry}", briefingCollectionEntryHandler(vault, collections)).Methods("GET")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("GET", "/api/briefings/collections", "")
+	var listed []briefingCollection
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	counts := map[string]int{}
+	for _, c := range listed {
+		counts[c.Name] = c.Count
+	}
+	if counts["daily"] != 3 || counts["weekly"] != 1 || counts["monthly"] != 0 || counts["research"] != 1 {
+		t.Errorf("unexpected collection counts %v", counts)
+	}
+
+	if w := do("GET", "/api/briefings/collections/monthly", ""); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected empty monthly collection, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/briefings/collections/weekly/2024-W01", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Week one") {
+		t.Errorf("expected weekly note, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/briefings/collections/research/llm-notes?format=json", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"title":"Notes"`) {
+		t.Errorf("expected parsed research note, got %d %s", w.Code, w.Body.String())
+	}
+
+	statusTests := []struct {
+		method, path, body string
+		expected           int
+	}{
+		{"GET", "/api/briefings/collections/unknown", "", http.StatusNotFound},
+		{"GET", "/api/briefings/collections/weekly/2024-01-01", "", http.StatusBadRequest},
+		{"GET", "/api/briefings/collections/research/notes..md", "", http.StatusBadRequest},
+		{"GET", "/api/briefings/collections/research/missing", "", http.StatusNotFound},
+		{"GET", "/api/briefings/rollup?from=2024-01-05&to=2024-01-01", "", http.StatusBadRequest},
+		{"GET", "/api/briefings/rollup?from=2024-01-01&to=2024-12-31", "", http.StatusBadRequest},
+		{"GET", "/api/briefings/rollup?from=2023-01-01&to=2023-01-07", "", http.StatusNotFound},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad"}`, http.StatusBadRequest},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad","domain":"ops","collection":"daily"}`, http.StatusBadRequest},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad","domain":"ops","name":"../x"}`, http.StatusBadRequest},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad","domain":"ops","timeout_ms":99999999}`, http.StatusBadRequest},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-02-29","knight":"galahad","domain":"ops"}`, http.StatusBadRequest},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad","domain":"ops"}`, http.StatusConflict},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-07","knight":"galahad","domain":"ops","overwrite":true}`, http.StatusServiceUnavailable},
+		{"POST", "/api/briefings/rollup", `{"from":"2024-01-01","to":"2024-01-31","knight":"galahad","domain":"ops"}`, http.StatusServiceUnavailable},
+	}
+	nc = nil
+	for _, tt := range statusTests {
+		if w := do(tt.method, tt.path, tt.body); w.Code != tt.expected {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tt.method, tt.path, tt.body, tt.expected, w.Code, w.Body.String())
+		}
+	}
+
+	w = do("GET", "/api/briefings/rollup?from=2024-01-01&to=2024-01-31", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected rollup, got %d %s", w.Code, w.Body.String())
+	}
+	fm, body := splitFrontMatter(w.Body.String())
+	if dates, _ := fm["briefings"].([]interface{}); len(dates) != 2 {
+		t.Errorf("expected two briefings in front-matter, got %v", fm)
+	}
+	if !strings.Contains(body, "## 2024-01-01\n\n### Monday") || !strings.Contains(body, "#### Incidents") || strings.Contains(body, "tags: [x]") {
+		t.Errorf("unexpected rollup body:\n%s", body)
+	}
+	if strings.Index(body, "2024-01-01") > strings.Index(body, "2024-01-03") || strings.Contains(body, "February") {
+		t.Errorf("expected only January briefings, oldest first:\n%s", body)
+	}
+}
+
+func TestRollupTargetAndNote(t *testing.T) {
+	collections, _ := loadBriefingCollections("research=Research")
+	day := func(s string) time.Time { d, _ := time.Parse("2006-01-02", s); return d }
+
+	tests := []struct {
+		req              rollupRequest
+		from, to         string
+		collection, name string
+	}{
+		{rollupRequest{}, "2024-01-01", "2024-01-07", "weekly", "2024-W01"},
+		{rollupRequest{}, "2024-01-01", "2024-01-31", "monthly", "2024-01"},
+		{rollupRequest{Collection: "research"}, "2024-01-01", "2024-01-02", "research", "rollup-2024-01-01-to-2024-01-02"},
+		{rollupRequest{Collection: "weekly", Name: "2024-W09"}, "2024-01-01", "2024-01-02", "weekly", "2024-W09"},
+		{rollupRequest{Collection: "monthly", Name: "2024-01"}, "2024-01-15", "2024-03-10", "monthly", "2024-01"},
+	}
+	for _, tt := range tests {
+		c, name, err := rollupTarget(tt.req, collections, day(tt.from), day(tt.to))
+		if err != nil || c.Name != tt.collection || name != tt.name {
+			t.Errorf("rollupTarget(%+v, %s..%s) = %s/%s, %v; want %s/%s", tt.req, tt.from, tt.to, c.Name, name, err, tt.collection, tt.name)
+		}
+	}
+
+	// Ranges spanning several periods aren't named after their first one
+	for _, tt := range []struct {
+		req      rollupRequest
+		from, to string
+	}{
+		{rollupRequest{}, "2024-01-15", "2024-03-10"},
+		{rollupRequest{Collection: "monthly"}, "2024-01-15", "2024-02-10"},
+		{rollupRequest{Collection: "weekly"}, "2024-01-01", "2024-01-14"},
+	} {
+		if _, name, err := rollupTarget(tt.req, collections, day(tt.from), day(tt.to)); err == nil {
+			t.Errorf("rollupTarget(%+v, %s..%s) = %s, expected an error", tt.req, tt.from, tt.to, name)
+		}
+	}
+
+	req := rollupRequest{From: "2024-01-01", To: "2024-01-07", Knight: "galahad"}
+	note, err := rollupNote([]byte(`{"knight":"galahad","success":true,"result":"# Week\n\nQuiet."}`), req, "galahad-rollup-1", time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fm, body := splitFrontMatter(string(note))
+	if fm["knight"] != "galahad" || fm["task_id"] != "galahad-rollup-1" || body != "# Week\n\nQuiet.\n" {
+		t.Errorf("unexpected note:\n%s", note)
+	}
+	if _, err := rollupNote([]byte(`{"success":false,"result":"boom"}`), req, "t", time.Now()); err == nil {
+		t.Error("expected failed result to be rejected")
+	}
+	if _, err := rollupNote([]byte(`{"success":true,"result":"  "}`), req, "t", time.Now()); err == nil {
+		t.Error("expected empty result to be rejected")
+	}
+	if note, err := rollupNote([]byte("plain markdown"), req, "t", time.Now()); err != nil || !strings.HasSuffix(string(note), "plain markdown\n") {
+		t.Errorf("expected non-JSON result used verbatim, got %q %v", note, err)
+	}
+}
+
+// TestStoreRollup checks the result isn't written over a note edited since
+// dispatch, and that the outcome is served by the status route.
+func TestStoreRollup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Briefings", "Monthly", "2024-01.md")
+	if status, _ := storeRollup(path, "", []byte("# First")); status != "saved" {
+		t.Fatalf("expected a new note saved, got %s", status)
+	}
+	if status, _ := storeRollup(path, "", []byte("# Second")); status != "conflict" {
+		t.Errorf("expected a conflict when the note appeared, got %s", status)
+	}
+	if status, _ := storeRollup(path, contentETag([]byte("# Other")), []byte("# Second")); status != "conflict" {
+		t.Errorf("expected a conflict when the note changed, got %s", status)
+	}
+	if status, _ := storeRollup(path, contentETag([]byte("# First")), []byte("# Second")); status != "saved" {
+		t.Errorf("expected the unchanged note replaced, got %s", status)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "# Second" {
+		t.Errorf("unexpected note %q", data)
+	}
+
+	rollups.set(rollupJob{TaskID: "galahad-rollup-1", Path: "Briefings/Monthly/2024-01.md", Status: "conflict"})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/briefings/rollup/{task_id}", briefingRollupStatusHandler).Methods("GET")
+	for path, expected := range map[string]int{
+		"/api/briefings/rollup/galahad-rollup-1": http.StatusOK,
+		"/api/briefings/rollup/unknown":          http.StatusNotFound,
+		"/api/briefings/rollup/..x":              http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != expected {
+			t.Errorf("GET %s: expected %d, got %d", path, expected, w.Code)
+		}
+		if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), `"status":"conflict"`) {
+			t.Errorf("unexpected status body %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// roundTableLabel is set by the operator on knights it provisions for a table.
+const roundTableLabel = "ai.roundtable.io/roundtable"
+
+// validSubjectPrefix is a single NATS subject token — no dots or wildcards,
+// since the operator builds <prefix>.tasks.> etc. from it.
+var validSubjectPrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,62}$`)
+
+// validCostBudget matches the decimal-string budgets the CRDs use.
+var validCostBudget = regexp.MustCompile(`^\d{1,7}(\.\d{1,2})?$`)
+
+// roundTableRequest is the create/patch body. Pointer fields distinguish
+// "not provided" from zero values so PATCH only touches what was sent.
+// spec.suspended is deliberately absent — use the suspend/resume endpoints.
+type roundTableRequest struct {
+	Name        string  `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	NATS        *struct {
+		SubjectPrefix *string `json:"subjectPrefix,omitempty"`
+	} `json:"nats,omitempty"`
+	Policies *struct {
+		MaxConcurrentTasks *int    `json:"maxConcurrentTasks,omitempty"`
+		CostBudgetUSD      *string `json:"costBudgetUSD,omitempty"`
+		MaxKnights         *int    `json:"maxKnights,omitempty"`
+		MaxMissions        *int    `json:"maxMissions,omitempty"`
+	} `json:"policies,omitempty"`
+	Ephemeral *bool `json:"ephemeral,omitempty"`
+}
+
+// validate checks field bounds and returns the spec fragment to write.
+func (req *roundTableRequest) validate(create bool) (map[string]interface{}, error) {
+	spec := map[string]interface{}{}
+
+	if req.Description != nil {
+		if len(*req.Description) > 500 {
+			return nil, fmt.Errorf("description must be at most 500 characters")
+		}
+		spec["description"] = *req.Description
+	}
+
+	if req.NATS != nil && req.NATS.SubjectPrefix != nil {
+		if !validSubjectPrefix.MatchString(*req.NATS.SubjectPrefix) {
+			return nil, fmt.Errorf("nats.subjectPrefix must be a single NATS token (letters, digits, - and _)")
+		}
+		spec["nats"] = map[string]interface{}{"subjectPrefix": *req.NATS.SubjectPrefix}
+	} else if create {
+		return nil, fmt.Errorf("nats.subjectPrefix is required")
+	}
+
+	if p := req.Policies; p != nil {
+		policies := map[string]interface{}{}
+		bounded := []struct {
+			field string
+			value *int
+			max   int
+		}{
+			{"maxConcurrentTasks", p.MaxConcurrentTasks, 1000},
+			{"maxKnights", p.MaxKnights, 500},
+			{"maxMissions", p.MaxMissions, 500},
+		}
+		for _, b := range bounded {
+			if b.value == nil {
+				continue
+			}
+			if *b.value < 1 || *b.value > b.max {
+				return nil, fmt.Errorf("policies.%s must be 1-%d", b.field, b.max)
+			}
+			policies[b.field] = int64(*b.value)
+		}
+		if p.CostBudgetUSD != nil {
+			if !validCostBudget.MatchString(*p.CostBudgetUSD) {
+				return nil, fmt.Errorf("policies.costBudgetUSD must be a decimal amount like \"100.00\"")
+			}
+			policies["costBudgetUSD"] = *p.CostBudgetUSD
+		}
+		if len(policies) > 0 {
+			spec["policies"] = policies
+		}
+	}
+
+	if req.Ephemeral != nil {
+		spec["ephemeral"] = *req.Ephemeral
+	}
+	return spec, nil
+}
+
+// decodeRoundTableRequest strictly decodes a create/patch body — unknown
+// fields (typos, spec.suspended) are rejected rather than silently dropped.
+func decodeRoundTableRequest(w http.ResponseWriter, r *http.Request) (*roundTableRequest, error) {
+	var req roundTableRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	return &req, nil
+}
+
+func roundTableCreateHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		req, err := decodeRoundTableRequest(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validK8sName.MatchString(req.Name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+		spec, err := req.validate(true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		roundTable := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "RoundTable",
+			"metadata": map[string]interface{}{
+				"name":      req.Name,
+				"namespace": namespace,
+			},
+			"spec": spec,
+		}}
+		obj, err := dynClient.Resource(roundTableGVR).Namespace(namespace).Create(r.Context(), roundTable, metav1.CreateOptions{})
+		switch {
+		case apierrors.IsAlreadyExists(err):
+			http.Error(w, "RoundTable already exists", http.StatusConflict)
+			return
+		case apierrors.IsInvalid(err):
+			slog.Warn("RoundTable rejected by the API server", "roundtable", req.Name, "error", err)
+			http.Error(w, "Invalid roundtable", http.StatusBadRequest)
+			return
+		case err != nil:
+			slog.Error("RoundTable create error", "roundtable", req.Name, "error", err)
+			http.Error(w, "Failed to create roundtable", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(parseRoundTableResource(obj.Object))
+	}
+}
+
+func roundTablePatchHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validK8sName.MatchString(name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+
+		req, err := decodeRoundTableRequest(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name != "" && req.Name != name {
+			http.Error(w, "RoundTables cannot be renamed", http.StatusBadRequest)
+			return
+		}
+		spec, err := req.validate(false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(spec) == 0 {
+			http.Error(w, "No fields to update", http.StatusBadRequest)
+			return
+		}
+
+		rts := dynClient.Resource(roundTableGVR).Namespace(namespace)
+		if _, err := rts.Get(r.Context(), name, metav1.GetOptions{}); err != nil {
+			http.Error(w, "RoundTable not found", http.StatusNotFound)
+			return
+		}
+		patch, _ := json.Marshal(map[string]interface{}{"spec": spec})
+		obj, err := rts.Patch(r.Context(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			slog.Error("RoundTable patch error", "roundtable", name, "error", err)
+			http.Error(w, "Failed to update roundtable", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parseRoundTableResource(obj.Object))
+	}
+}
+
+// roundTableMemberKnights returns the Knight CRs belonging to a RoundTable:
+// labelled or owned by it, or listening on its NATS subject prefix.
+func roundTableMemberKnights(ctx context.Context, namespace string, rt *unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	list, err := dynClient.Resource(knightGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	name := rt.GetName()
+	prefix := getStr(getNestedMap(getNestedMap(rt.Object, "spec"), "nats"), "subjectPrefix")
+
+	var members []unstructured.Unstructured
+	for _, item := range list.Items {
+		member := item.GetLabels()[roundTableLabel] == name
+		for _, ref := range item.GetOwnerReferences() {
+			if ref.Kind == "RoundTable" && ref.Name == name {
+				member = true
+			}
+		}
+		if !member && prefix != "" {
+			if p, perr := deriveIntrospectPrefix(&item); perr == nil && p == prefix {
+				member = true
+			}
+		}
+		if member {
+			members = append(members, item)
+		}
+	}
+	return members, nil
+}
+
+// MemberKnightState is a knight's state after a table-wide suspend/resume.
+// Converged is false while the operator has yet to propagate the change.
+type MemberKnightState struct {
+	Name      string `json:"name"`
+	Phase     string `json:"phase"`
+	Ready     bool   `json:"ready"`
+	Suspended bool   `json:"suspended"`
+	Converged bool   `json:"converged"`
+}
+
+// roundTableSuspendHandler handles POST /roundtables/{name}/{suspend|resume}.
+func roundTableSuspendHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		vars := mux.Vars(r)
+		name := vars["name"]
+		if !validK8sName.MatchString(name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+		suspend := vars["action"] == "suspend"
+
+		rts := dynClient.Resource(roundTableGVR).Namespace(namespace)
+		if _, err := rts.Get(r.Context(), name, metav1.GetOptions{}); err != nil {
+			http.Error(w, "RoundTable not found", http.StatusNotFound)
+			return
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"spec": map[string]interface{}{"suspended": suspend},
+		})
+		obj, err := rts.Patch(r.Context(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			slog.Error("RoundTable suspend patch error", "roundtable", name, "error", err)
+			http.Error(w, "Failed to update roundtable", http.StatusInternalServerError)
+			return
+		}
+
+		knights := []MemberKnightState{}
+		members, err := roundTableMemberKnights(r.Context(), namespace, obj)
+		if err != nil {
+			slog.Warn("RoundTable member list error", "roundtable", name, "error", err)
+		}
+		for i := range members {
+			k := buildKnightStatus(&members[i], nil)
+			knights = append(knights, MemberKnightState{
+				Name:      k.Name,
+				Phase:     k.Phase,
+				Ready:     knightIsReady(&members[i]),
+				Suspended: k.Suspended,
+				Converged: k.Suspended == suspend,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":      name,
+			"suspended": suspend,
+			"knights":   knights,
+		})
+	}
+}
+
+// parseUSD reads the CRDs' decimal cost strings ("1.25", "$1.25").
+func parseUSD(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(s), "$"), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// MemberKnight is a knight's row in the RoundTable membership view.
+type MemberKnight struct {
+	Name           string `json:"name"`
+	Domain         string `json:"domain"`
+	Phase          string `json:"phase"`
+	Ready          bool   `json:"ready"`
+	Suspended      bool   `json:"suspended"`
+	TasksCompleted int64  `json:"tasksCompleted"`
+	TasksFailed    int64  `json:"tasksFailed"`
+	TotalCost      string `json:"totalCost,omitempty"`
+}
+
+// MemberMission is a mission's row in the RoundTable membership view.
+type MemberMission struct {
+	Name          string `json:"name"`
+	Phase         string `json:"phase"`
+	TotalCost     string `json:"totalCost,omitempty"`
+	CostBudgetUSD string `json:"costBudgetUSD,omitempty"`
+	Knights       int    `json:"knights"`
+	Chains        int    `json:"chains"`
+}
+
+// MemberChain is a chain's row in the RoundTable membership view.
+type MemberChain struct {
+	Name          string `json:"name"`
+	Phase         string `json:"phase"`
+	MissionRef    string `json:"missionRef,omitempty"`
+	Steps         int    `json:"steps"`
+	RunsCompleted int    `json:"runsCompleted"`
+	RunsFailed    int    `json:"runsFailed"`
+}
+
+// MemberTotals aggregates a RoundTable's members. CostUSD sums knight costs —
+// mission costs are the same spend viewed per mission, so adding both would
+// double count.
+type MemberTotals struct {
+	Knights        int     `json:"knights"`
+	KnightsReady   int     `json:"knightsReady"`
+	Missions       int     `json:"missions"`
+	ActiveMissions int     `json:"activeMissions"`
+	Chains         int     `json:"chains"`
+	TasksCompleted int64   `json:"tasksCompleted"`
+	TasksFailed    int64   `json:"tasksFailed"`
+	CostUSD        float64 `json:"costUSD"`
+}
+
+// RoundTableMembers is the response for GET /roundtables/{name}/members.
+type RoundTableMembers struct {
+	Name       string          `json:"name"`
+	NATSPrefix string          `json:"natsPrefix"`
+	Knights    []MemberKnight  `json:"knights"`
+	Missions   []MemberMission `json:"missions"`
+	Chains     []MemberChain   `json:"chains"`
+	Totals     MemberTotals    `json:"totals"`
+}
+
+// roundTableMembersHandler joins the Knights, Missions and Chains that belong
+// to a RoundTable. Missions match on roundTableRef; chains match on their own
+// roundTableRef or on belonging to a member mission.
+func roundTableMembersHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validK8sName.MatchString(name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		obj, err := dynClient.Resource(roundTableGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, "RoundTable not found", http.StatusNotFound)
+			return
+		}
+
+		resp := RoundTableMembers{
+			Name:       name,
+			NATSPrefix: parseRoundTableResource(obj.Object).NATSPrefix,
+			Knights:    []MemberKnight{},
+			Missions:   []MemberMission{},
+			Chains:     []MemberChain{},
+		}
+
+		knights, err := roundTableMemberKnights(ctx, namespace, obj)
+		if err != nil {
+			slog.Error("RoundTable member knights error", "roundtable", name, "error", err)
+			http.Error(w, "Failed to list knights", http.StatusInternalServerError)
+			return
+		}
+		for i := range knights {
+			k := buildKnightStatus(&knights[i], nil)
+			ready := knightIsReady(&knights[i])
+			resp.Knights = append(resp.Knights, MemberKnight{
+				Name:           k.Name,
+				Domain:         k.Domain,
+				Phase:          k.Phase,
+				Ready:          ready,
+				Suspended:      k.Suspended,
+				TasksCompleted: k.TasksCompleted,
+				TasksFailed:    k.TasksFailed,
+				TotalCost:      k.TotalCost,
+			})
+			resp.Totals.Knights++
+			if ready {
+				resp.Totals.KnightsReady++
+			}
+			resp.Totals.TasksCompleted += k.TasksCompleted
+			resp.Totals.TasksFailed += k.TasksFailed
+			resp.Totals.CostUSD += parseUSD(k.TotalCost)
+		}
+
+		missionList, err := dynClient.Resource(missionGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			slog.Error("Mission list error", "error", err)
+			http.Error(w, "Failed to list missions", http.StatusInternalServerError)
+			return
+		}
+		memberMissions := map[string]bool{}
+		for _, item := range missionList.Items {
+			m := parseMissionResource(item.Object)
+			if m.RoundTableRef != name {
+				continue
+			}
+			memberMissions[m.Name] = true
+			resp.Missions = append(resp.Missions, MemberMission{
+				Name:          m.Name,
+				Phase:         m.Phase,
+				TotalCost:     m.TotalCost,
+				CostBudgetUSD: m.CostBudgetUSD,
+				Knights:       len(m.Knights),
+				Chains:        len(m.Chains),
+			})
+			resp.Totals.Missions++
+			if !missionTerminalPhases[m.Phase] {
+				resp.Totals.ActiveMissions++
+			}
+		}
+
+		chainList, err := dynClient.Resource(chainGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			slog.Error("Chain list error", "error", err)
+			http.Error(w, "Failed to list chains", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range chainList.Items {
+			c := parseChainResource(item.Object, nil)
+			if c.RoundTableRef != name && !memberMissions[c.MissionRef] {
+				continue
+			}
+			resp.Chains = append(resp.Chains, MemberChain{
+				Name:          c.Name,
+				Phase:         c.Phase,
+				MissionRef:    c.MissionRef,
+				Steps:         len(c.Steps),
+				RunsCompleted: c.RunsCompleted,
+				RunsFailed:    c.RunsFailed,
+			})
+			resp.Totals.Chains++
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestRoundTableCreateAndPatch covers validation on create and partial updates
+func TestRoundTableCreateAndPatch(t *testing.T) {
+	router := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"create", "POST", "/api/roundtables", `{"name": "table-c", "description": "Ops", "nats": {"subjectPrefix": "fleet-c"}, "policies": {"maxKnights": 5, "costBudgetUSD": "50.00"}}`, http.StatusCreated},
+		{"duplicate", "POST", "/api/roundtables", `{"name": "table-c", "nats": {"subjectPrefix": "fleet-c"}}`, http.StatusConflict},
+		{"missing prefix", "POST", "/api/roundtables", `{"name": "table-d"}`, http.StatusBadRequest},
+		{"invalid prefix", "POST", "/api/roundtables", `{"name": "table-d", "nats": {"subjectPrefix": "fleet.>"}}`, http.StatusBadRequest},
+		{"invalid name", "POST", "/api/roundtables", `{"name": "Table_D", "nats": {"subjectPrefix": "fleet-d"}}`, http.StatusBadRequest},
+		{"policy out of range", "POST", "/api/roundtables", `{"name": "table-d", "nats": {"subjectPrefix": "fleet-d"}, "policies": {"maxConcurrentTasks": 0}}`, http.StatusBadRequest},
+		{"invalid budget", "PATCH", "/api/roundtables/table-c", `{"policies": {"costBudgetUSD": "lots"}}`, http.StatusBadRequest},
+		{"suspended not patchable", "PATCH", "/api/roundtables/table-c", `{"suspended": true}`, http.StatusBadRequest},
+		{"empty patch", "PATCH", "/api/roundtables/table-c", `{}`, http.StatusBadRequest},
+		{"patch invalid name", "PATCH", "/api/roundtables/Table-C", `{"ephemeral": true}`, http.StatusBadRequest},
+		{"patch unknown table", "PATCH", "/api/roundtables/missing", `{"ephemeral": true}`, http.StatusNotFound},
+		{"patch", "PATCH", "/api/roundtables/table-c", `{"description": "Operations", "ephemeral": true, "policies": {"maxMissions": 3}}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusConflict && strings.Contains(w.Body.String(), "roundtables.ai.roundtable.io") {
+				t.Errorf("expected no API server error text, got %s", w.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/roundtables/table-c", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var rt RoundTableSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &rt); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if rt.Description != "Operations" || !rt.Ephemeral || rt.NATSPrefix != "fleet-c" {
+		t.Errorf("unexpected roundtable after patch: %+v", rt)
+	}
+	// Merge patch keeps policies that were not part of the update
+	if rt.Policies == nil || rt.Policies.MaxKnights != 5 || rt.Policies.MaxMissions != 3 {
+		t.Errorf("expected merged policies, got %+v", rt.Policies)
+	}
+}
+
+// TestRoundTableSuspendResume verifies spec.suspended is toggled and member
+// knights are reported
+func TestRoundTableSuspendResume(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	req := httptest.NewRequest("POST", "/api/roundtables", strings.NewReader(`{"name": "table-s", "nats": {"subjectPrefix": "fleet-s"}}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	knights := dynClient.Resource(knightGVR).Namespace("test-namespace")
+	byPrefix := makeTestKnightCR("percival", "test-namespace", "ops")
+	byPrefix.Object["spec"].(map[string]interface{})["nats"] = map[string]interface{}{
+		"subjects": []interface{}{"fleet-s.tasks.ops.>"},
+	}
+	knights.Create(ctx, byPrefix, metav1.CreateOptions{})
+	byLabel := makeTestKnightCR("bors", "test-namespace", "ops")
+	byLabel.SetLabels(map[string]string{roundTableLabel: "table-s"})
+	byLabel.Object["spec"].(map[string]interface{})["suspended"] = true
+	knights.Create(ctx, byLabel, metav1.CreateOptions{})
+	knights.Create(ctx, makeTestKnightCR("outsider", "test-namespace", "ops"), metav1.CreateOptions{})
+
+	req = httptest.NewRequest("POST", "/api/roundtables/table-s/suspend", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Suspended bool                `json:"suspended"`
+		Knights   []MemberKnightState `json:"knights"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if !resp.Suspended || len(resp.Knights) != 2 {
+		t.Fatalf("expected 2 member knights of a suspended table, got %+v", resp)
+	}
+	for _, k := range resp.Knights {
+		if k.Converged != (k.Name == "bors") {
+			t.Errorf("unexpected converged=%v for %s", k.Converged, k.Name)
+		}
+	}
+
+	obj, _ := dynClient.Resource(roundTableGVR).Namespace("test-namespace").Get(ctx, "table-s", metav1.GetOptions{})
+	if !parseRoundTableResource(obj.Object).Suspended {
+		t.Error("expected spec.suspended to be set")
+	}
+
+	req = httptest.NewRequest("POST", "/api/roundtables/table-s/resume", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	obj, _ = dynClient.Resource(roundTableGVR).Namespace("test-namespace").Get(ctx, "table-s", metav1.GetOptions{})
+	if parseRoundTableResource(obj.Object).Suspended {
+		t.Error("expected spec.suspended to be cleared after resume")
+	}
+
+	req = httptest.NewRequest("POST", "/api/roundtables/missing/suspend", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown roundtable, got %d", w.Code)
+	}
+}
+
+// TestRoundTableMembers joins knights, missions and chains for one table
+func TestRoundTableMembers(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	req := httptest.NewRequest("POST", "/api/roundtables", strings.NewReader(`{"name": "table-m", "nats": {"subjectPrefix": "fleet-m"}}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	knights := dynClient.Resource(knightGVR).Namespace("test-namespace")
+	for _, name := range []string{"gawain", "kay"} {
+		k := makeTestKnightCR(name, "test-namespace", "ops")
+		k.SetLabels(map[string]string{roundTableLabel: "table-m"})
+		phase := "Provisioning"
+		if name == "gawain" {
+			phase = "Ready"
+		}
+		k.Object["status"] = map[string]interface{}{
+			"phase":          phase,
+			"ready":          phase == "Ready",
+			"tasksCompleted": int64(10),
+			"tasksFailed":    int64(1),
+			"totalCost":      "1.25",
+		}
+		knights.Create(ctx, k, metav1.CreateOptions{})
+	}
+	knights.Create(ctx, makeTestKnightCR("outsider", "test-namespace", "ops"), metav1.CreateOptions{})
+
+	missions := dynClient.Resource(missionGVR).Namespace("test-namespace")
+	for name, ref := range map[string]string{"op-1": "table-m", "op-2": "other"} {
+		m := makeTestMetaMission(name, false)
+		m.Object["spec"].(map[string]interface{})["roundTableRef"] = ref
+		missions.Create(ctx, m, metav1.CreateOptions{})
+	}
+
+	chains := dynClient.Resource(chainGVR).Namespace("test-namespace")
+	for name, spec := range map[string]map[string]interface{}{
+		"direct":    {"roundTableRef": "table-m"},
+		"via-op":    {"missionRef": "op-1"},
+		"unrelated": {"missionRef": "op-2"},
+	} {
+		chains.Create(ctx, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Chain",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "test-namespace"},
+			"spec":       spec,
+		}}, metav1.CreateOptions{})
+	}
+
+	req = httptest.NewRequest("GET", "/api/roundtables/table-m/members", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var members RoundTableMembers
+	if err := json.Unmarshal(w.Body.Bytes(), &members); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	totals := members.Totals
+	if totals.Knights != 2 || totals.KnightsReady != 1 || totals.TasksCompleted != 20 || totals.TasksFailed != 2 {
+		t.Errorf("unexpected knight totals: %+v", totals)
+	}
+	if totals.CostUSD != 2.5 {
+		t.Errorf("expected cost 2.5, got %f", totals.CostUSD)
+	}
+	if totals.Missions != 1 || totals.ActiveMissions != 1 || members.Missions[0].Name != "op-1" {
+		t.Errorf("expected only op-1 as member mission, got %+v", members.Missions)
+	}
+	if totals.Chains != 2 {
+		t.Errorf("expected direct and via-op chains, got %+v", members.Chains)
+	}
+
+	req = httptest.NewRequest("GET", "/api/roundtables/missing/members", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown roundtable, got %d", w.Code)
+	}
+}
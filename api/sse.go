@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// sseHeartbeat keeps idle SSE streams alive through proxies that close
+// connections without traffic.
+const sseHeartbeat = 15 * time.Second
+
+// missionRewatchMin and missionRewatchMax bound the backoff between mission
+// watch sessions, so a watch the API server keeps closing (or failing) isn't
+// re-established in a tight loop. Variables so tests can shorten them.
+var (
+	missionRewatchMin = time.Second
+	missionRewatchMax = 30 * time.Second
+)
+
+// sseWriter writes Server-Sent Events. Writes are serialized so NATS callbacks
+// and the watch loop can share one stream.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newSSEWriter sends the event-stream headers and returns a writer for the
+// stream. Buffering proxies (nginx) are told not to hold events back.
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	s := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	s.rc.Flush()
+	return s
+}
+
+// send writes one named event with a JSON payload and flushes it.
+func (s *sseWriter) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// heartbeat writes an SSE comment line, which clients ignore.
+func (s *sseWriter) heartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// missionTerminalPhases are the phases after which a mission makes no further
+// progress ("Completed" is the legacy alias of Succeeded).
+var missionTerminalPhases = map[string]bool{
+	"Succeeded": true,
+	"Failed":    true,
+	"Expired":   true,
+	"Completed": true,
+}
+
+// missionChainNames returns the chain CR names owned by a mission, used to
+// pick its events out of the shared <prefix>.chains.> subject space.
+func missionChainNames(m MissionSummary) map[string]bool {
+	names := map[string]bool{}
+	for _, cs := range m.ChainStatuses {
+		if cs.ChainCRName != "" {
+			names[cs.ChainCRName] = true
+		}
+	}
+	return names
+}
+
+// missionEventsHandler streams a mission's progress as SSE: a "mission" event
+// with the MissionSummary on every CR change, "nats" events for its
+// missions./chains. messages, and a final "done" event once the mission
+// reaches a terminal phase (or is deleted).
+func missionEventsHandler(namespace, fleetPrefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		missions := dynClient.Resource(missionGVR).Namespace(namespace)
+		obj, err := missions.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+
+		stream := newSSEWriter(w)
+		mission := parseMissionResource(obj.Object)
+		if stream.send("mission", mission) != nil {
+			return
+		}
+		if missionTerminalPhases[mission.Phase] {
+			stream.send("done", map[string]string{"name": name, "phase": mission.Phase})
+			return
+		}
+
+		// NATS events are best-effort: the CR watch alone is enough to follow
+		// a mission to completion
+		var chainsMu sync.Mutex
+		chains := missionChainNames(mission)
+		natsCh := make(chan TaskEvent, 64)
+		if nc != nil && nc.IsConnected() {
+			prefix := roundTablePrefix(ctx, namespace, mission.RoundTableRef, fleetPrefix)
+			forward := func(eventType string) nats.MsgHandler {
+				return func(msg *nats.Msg) {
+					if eventType == "chain" {
+						// <prefix>.chains.<chain>.… — keep only this mission's chains
+						rest := strings.TrimPrefix(msg.Subject, prefix+".chains.")
+						chainName, _, _ := strings.Cut(rest, ".")
+						chainsMu.Lock()
+						ours := chains[chainName]
+						chainsMu.Unlock()
+						if !ours {
+							return
+						}
+					}
+					select {
+					case natsCh <- TaskEvent{Type: eventType, Subject: msg.Subject, Data: msg.Data, Timestamp: eventTimestamp(msg.Data)}:
+					default: // slow client — drop rather than block the NATS dispatcher
+					}
+				}
+			}
+			base := fmt.Sprintf("%s.missions.%s", prefix, name)
+			for subject, eventType := range map[string]string{
+				base:                 "mission",
+				base + ".>":          "mission",
+				prefix + ".chains.>": "chain",
+			} {
+				sub, serr := nc.Subscribe(subject, forward(eventType))
+				if serr != nil {
+					slog.Warn("NATS sub error for mission events", "subject", subject, "error", serr)
+					continue
+				}
+				defer sub.Unsubscribe()
+			}
+		}
+
+		resourceVersion := obj.GetResourceVersion()
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		onChange := func(m MissionSummary) {
+			chainsMu.Lock()
+			chains = missionChainNames(m)
+			chainsMu.Unlock()
+		}
+
+		backoff := missionRewatchMin
+		for {
+			watcher, werr := missions.Watch(ctx, metav1.ListOptions{
+				FieldSelector:   "metadata.name=" + name,
+				ResourceVersion: resourceVersion,
+			})
+			if werr != nil {
+				slog.Warn("Mission watch error", "mission", name, "error", werr)
+				stream.send("error", map[string]string{"error": "mission watch failed"})
+				return
+			}
+
+			done, rv, serr := streamMissionWatch(ctx, stream, watcher, name, natsCh, heartbeat.C, onChange)
+			watcher.Stop()
+			if done {
+				return
+			}
+			if rv != "" {
+				resourceVersion = rv
+				backoff = missionRewatchMin
+			}
+			if apierrors.IsResourceExpired(serr) || apierrors.IsGone(serr) {
+				// Our resourceVersion was compacted away: changes since then
+				// are lost, so resync from the current object.
+				obj, err := missions.Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					stream.send("done", map[string]string{"name": name, "phase": "Deleted"})
+					return
+				}
+				if err != nil {
+					slog.Warn("Mission resync error", "mission", name, "error", err)
+					stream.send("error", map[string]string{"error": "mission watch failed"})
+					return
+				}
+				resourceVersion = obj.GetResourceVersion()
+				if sendMissionState(stream, name, parseMissionResource(obj.Object), onChange) {
+					return
+				}
+			} else if serr != nil {
+				slog.Warn("Mission watch error", "mission", name, "error", serr)
+			}
+			// Re-establish the watch from the last seen version, after a pause
+			if !waitMissionRewatch(ctx, stream, natsCh, heartbeat.C, backoff) {
+				return
+			}
+			backoff = min(backoff*2, missionRewatchMax)
+		}
+	}
+}
+
+// waitMissionRewatch waits d before the next watch session, still forwarding
+// NATS events and heartbeats. It returns false when the stream should end.
+func waitMissionRewatch(ctx context.Context, stream *sseWriter, natsCh <-chan TaskEvent, heartbeat <-chan time.Time, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-heartbeat:
+			if stream.heartbeat() != nil {
+				return false
+			}
+		case ev := <-natsCh:
+			if stream.send("nats", ev) != nil {
+				return false
+			}
+		}
+	}
+}
+
+// sendMissionState sends a mission's state, followed by "done" when it has
+// reached a terminal phase. It returns true when the stream should end.
+func sendMissionState(stream *sseWriter, name string, mission MissionSummary, onChange func(MissionSummary)) bool {
+	onChange(mission)
+	if stream.send("mission", mission) != nil {
+		return true
+	}
+	if missionTerminalPhases[mission.Phase] {
+		stream.send("done", map[string]string{"name": name, "phase": mission.Phase})
+		return true
+	}
+	return false
+}
+
+// streamMissionWatch pumps one watch session into the SSE stream. It returns
+// done=true when the stream should end (terminal phase, deletion, client gone),
+// and otherwise the last seen resourceVersion and the error the API server
+// ended the session with, if any.
+func streamMissionWatch(ctx context.Context, stream *sseWriter, watcher watch.Interface, name string,
+	natsCh <-chan TaskEvent, heartbeat <-chan time.Time, onChange func(MissionSummary)) (bool, string, error) {
+	var resourceVersion string
+	for {
+		select {
+		case <-ctx.Done():
+			return true, "", nil
+		case <-heartbeat:
+			if stream.heartbeat() != nil {
+				return true, "", nil
+			}
+		case ev := <-natsCh:
+			if stream.send("nats", ev) != nil {
+				return true, "", nil
+			}
+		case ev, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, resourceVersion, nil
+			}
+			if ev.Type == watch.Error {
+				// Typically 410 Gone: the version we watch from has expired
+				return false, resourceVersion, apierrors.FromObject(ev.Object)
+			}
+			obj, isObj := ev.Object.(*unstructured.Unstructured)
+			if !isObj || obj.GetName() != name {
+				continue // the fake client ignores field selectors; real bookmarks carry no mission
+			}
+			resourceVersion = obj.GetResourceVersion()
+			if ev.Type == watch.Deleted {
+				stream.send("done", map[string]string{"name": name, "phase": "Deleted"})
+				return true, "", nil
+			}
+			if sendMissionState(stream, name, parseMissionResource(obj.Object), onChange) {
+				return true, "", nil
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE parses events off an SSE stream onto a channel until it closes.
+func readSSE(resp *http.Response) <-chan sseEvent {
+	ch := make(chan sseEvent, 16)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.Data = strings.TrimPrefix(line, "data: ")
+			case line == "" && ev.Event != "":
+				ch <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return ch
+}
+
+func nextSSE(t *testing.T, ch <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("stream closed unexpectedly")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for SSE event")
+	}
+	return sseEvent{}
+}
+
+// missionWatches reports each mission watch the fake dynamic client
+// establishes, once it is registered with the tracker, so tests can update
+// the mission knowing the handler will see it. Watch sessions are served by
+// next when it returns a watcher, and by the tracker otherwise.
+func missionWatches(t *testing.T, next func() watch.Interface) <-chan struct{} {
+	t.Helper()
+	dc := dynClient.(*dynamicfake.FakeDynamicClient)
+	started := make(chan struct{}, 8)
+	dc.PrependWatchReactor("missions", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		var w watch.Interface
+		if next != nil {
+			w = next()
+		}
+		if w == nil {
+			var err error
+			if w, err = dc.Tracker().Watch(action.GetResource(), action.GetNamespace()); err != nil {
+				return true, nil, err
+			}
+		}
+		started <- struct{}{}
+		return true, w, nil
+	})
+	return started
+}
+
+func waitWatch(t *testing.T, started <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the mission watch")
+	}
+}
+
+// TestMissionEventsStream follows a mission over SSE until it succeeds. The
+// router is wrapped in metricsMiddleware to check events flush through it.
+func TestMissionEventsStream(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	mission := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata": map[string]interface{}{
+				"name":      "follow-me",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{"objective": "Follow along"},
+			"status": map[string]interface{}{
+				"phase": "Active",
+			},
+		},
+	}
+	missions := dynClient.Resource(missionGVR).Namespace("test-namespace")
+	missions.Create(ctx, mission, metav1.CreateOptions{})
+	watches := missionWatches(t, nil)
+
+	srv := httptest.NewServer(metricsMiddleware(router))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/missions/follow-me/events")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	events := readSSE(resp)
+
+	first := nextSSE(t, events)
+	if first.Event != "mission" {
+		t.Fatalf("expected initial mission event, got %s", first.Event)
+	}
+	var summary MissionSummary
+	json.Unmarshal([]byte(first.Data), &summary)
+	if summary.Phase != "Active" {
+		t.Errorf("expected phase Active, got %s", summary.Phase)
+	}
+
+	waitWatch(t, watches)
+	unstructured.SetNestedField(mission.Object, "Succeeded", "status", "phase")
+	if _, err := missions.Update(ctx, mission, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update mission: %v", err)
+	}
+
+	update := nextSSE(t, events)
+	if update.Event != "mission" || !strings.Contains(update.Data, `"phase":"Succeeded"`) {
+		t.Errorf("expected Succeeded mission event, got %+v", update)
+	}
+	done := nextSSE(t, events)
+	if done.Event != "done" {
+		t.Errorf("expected done event, got %s", done.Event)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected stream to close after done")
+	}
+}
+
+// TestMissionEventsWatchExpired resyncs from a fresh Get when the API server
+// expires the watch's resourceVersion, rather than re-watching from it.
+func TestMissionEventsWatchExpired(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+	defer func(lo, hi time.Duration) { missionRewatchMin, missionRewatchMax = lo, hi }(missionRewatchMin, missionRewatchMax)
+	missionRewatchMin, missionRewatchMax = time.Millisecond, time.Millisecond
+
+	mission := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata":   map[string]interface{}{"name": "expiring", "namespace": "test-namespace"},
+			"status":     map[string]interface{}{"phase": "Active"},
+		},
+	}
+	missions := dynClient.Resource(missionGVR).Namespace("test-namespace")
+	missions.Create(ctx, mission, metav1.CreateOptions{})
+
+	expired := watch.NewFake()
+	first := true
+	watches := missionWatches(t, func() watch.Interface {
+		if first {
+			first = false
+			return expired
+		}
+		return nil
+	})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/missions/expiring/events")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	events := readSSE(resp)
+	if ev := nextSSE(t, events); ev.Event != "mission" {
+		t.Fatalf("expected initial mission event, got %s", ev.Event)
+	}
+	waitWatch(t, watches)
+
+	// A change the expired watch never delivers
+	unstructured.SetNestedField(mission.Object, "Reviewing", "status", "phase")
+	missions.Update(ctx, mission, metav1.UpdateOptions{})
+	expired.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired, Message: "too old resource version"})
+
+	if ev := nextSSE(t, events); ev.Event != "mission" || !strings.Contains(ev.Data, `"phase":"Reviewing"`) {
+		t.Fatalf("expected the mission re-read after the expiry, got %+v", ev)
+	}
+	waitWatch(t, watches)
+	unstructured.SetNestedField(mission.Object, "Succeeded", "status", "phase")
+	missions.Update(ctx, mission, metav1.UpdateOptions{})
+	if ev := nextSSE(t, events); ev.Event != "mission" || !strings.Contains(ev.Data, `"phase":"Succeeded"`) {
+		t.Errorf("expected the re-established watch followed, got %+v", ev)
+	}
+	if ev := nextSSE(t, events); ev.Event != "done" {
+		t.Errorf("expected done event, got %s", ev.Event)
+	}
+}
+
+// TestMissionEventsTerminalMission ends immediately for finished missions
+func TestMissionEventsTerminalMission(t *testing.T) {
+	router := setupTestRouter()
+
+	mission := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata": map[string]interface{}{
+				"name":      "finished",
+				"namespace": "test-namespace",
+			},
+			"spec":   map[string]interface{}{},
+			"status": map[string]interface{}{"phase": "Failed"},
+		},
+	}
+	dynClient.Resource(missionGVR).Namespace("test-namespace").Create(context.Background(), mission, metav1.CreateOptions{})
+
+	req := httptest.NewRequest("GET", "/api/missions/finished/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: mission\n") || !strings.HasSuffix(body, "event: done\ndata: {\"name\":\"finished\",\"phase\":\"Failed\"}\n\n") {
+		t.Errorf("unexpected stream: %q", body)
+	}
+
+	req = httptest.NewRequest("GET", "/api/missions/missing/events", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown mission, got %d", w.Code)
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats.go/jetstream"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TimelineEvent is a single entry in a mission's post-mortem timeline. Entries
+// are assembled from the Mission/Chain/Knight CRs, Kubernetes Events and the
+// JetStream history of the mission's NATS subjects.
+type TimelineEvent struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`   // mission.started, step.completed, task.result, cost.increment, ...
+	Source    string          `json:"source"` // crd, k8s-event, jetstream
+	Resource  string          `json:"resource,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Subject   string          `json:"subject,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// parseCRTime parses an RFC3339 timestamp from a CR status field. The zero
+// time means "absent or unparseable" and such entries are skipped.
+func parseCRTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// roundTablePrefix resolves the NATS subject prefix of a RoundTable, falling
+// back to FLEET_PREFIX when the mission has no roundTableRef or the CR cannot
+// be read.
+func roundTablePrefix(ctx context.Context, namespace, roundTable, fallback string) string {
+	if roundTable == "" || dynClient == nil {
+		return fallback
+	}
+	obj, err := dynClient.Resource(roundTableGVR).Namespace(namespace).Get(ctx, roundTable, metav1.GetOptions{})
+	if err != nil {
+		return fallback
+	}
+	if p := getStr(getNestedMap(getNestedMap(obj.Object, "spec"), "nats"), "subjectPrefix"); p != "" {
+		return p
+	}
+	return fallback
+}
+
+// conditionEvents turns status.conditions into timeline entries keyed on
+// lastTransitionTime.
+func conditionEvents(status map[string]interface{}, resource string) []TimelineEvent {
+	var events []TimelineEvent
+	for _, c := range getSlice(status, "conditions") {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t := parseCRTime(getStr(cm, "lastTransitionTime"))
+		if t.IsZero() {
+			continue
+		}
+		msg := fmt.Sprintf("%s=%s", getStr(cm, "type"), getStr(cm, "status"))
+		if reason := getStr(cm, "reason"); reason != "" {
+			msg += " (" + reason + ")"
+		}
+		if m := getStr(cm, "message"); m != "" {
+			msg += ": " + m
+		}
+		events = append(events, TimelineEvent{
+			Timestamp: t, Type: "condition", Source: "crd", Resource: resource, Message: msg,
+		})
+	}
+	return events
+}
+
+// missionCREvents extracts the lifecycle milestones recorded on the Mission CR.
+func missionCREvents(obj *unstructured.Unstructured) []TimelineEvent {
+	status := getNestedMap(obj.Object, "status")
+	resource := "mission/" + obj.GetName()
+	var events []TimelineEvent
+
+	if ts := obj.GetCreationTimestamp(); !ts.IsZero() {
+		events = append(events, TimelineEvent{
+			Timestamp: ts.Time, Type: "mission.created", Source: "crd", Resource: resource,
+		})
+	}
+	if t := parseCRTime(getStr(status, "startedAt")); !t.IsZero() {
+		events = append(events, TimelineEvent{
+			Timestamp: t, Type: "mission.started", Source: "crd", Resource: resource,
+		})
+	}
+	if pr := getNestedMap(status, "planningResult"); pr != nil {
+		if t := parseCRTime(getStr(pr, "completedAt")); !t.IsZero() {
+			msg := fmt.Sprintf("generated %d chains, %d knights, %d skills",
+				getInt(pr, "chainsGenerated"), getInt(pr, "knightsGenerated"), getInt(pr, "skillsGenerated"))
+			if e := getStr(pr, "error"); e != "" {
+				msg = "planning failed: " + e
+			}
+			events = append(events, TimelineEvent{
+				Timestamp: t, Type: "planning.completed", Source: "crd", Resource: resource, Message: msg,
+			})
+		}
+	}
+	events = append(events, conditionEvents(status, resource)...)
+	if t := parseCRTime(getStr(status, "completedAt")); !t.IsZero() {
+		events = append(events, TimelineEvent{
+			Timestamp: t, Type: "mission.completed", Source: "crd", Resource: resource,
+			Message: getStr(status, "phase"),
+		})
+	}
+	return events
+}
+
+// chainCREvents extracts chain and step start/finish times from a Chain CR.
+func chainCREvents(obj *unstructured.Unstructured) []TimelineEvent {
+	status := getNestedMap(obj.Object, "status")
+	resource := "chain/" + obj.GetName()
+	var events []TimelineEvent
+
+	if t := parseCRTime(getStr(status, "startedAt")); !t.IsZero() {
+		events = append(events, TimelineEvent{
+			Timestamp: t, Type: "chain.started", Source: "crd", Resource: resource,
+		})
+	}
+	for _, s := range getSlice(status, "stepStatuses") {
+		sm, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		stepResource := resource + "/" + getStr(sm, "name")
+		if t := parseCRTime(getStr(sm, "startedAt")); !t.IsZero() {
+			events = append(events, TimelineEvent{
+				Timestamp: t, Type: "step.started", Source: "crd", Resource: stepResource,
+			})
+		}
+		if t := parseCRTime(getStr(sm, "completedAt")); !t.IsZero() {
+			events = append(events, TimelineEvent{
+				Timestamp: t, Type: "step.completed", Source: "crd", Resource: stepResource,
+				Message: getStr(sm, "phase"),
+			})
+		}
+	}
+	if t := parseCRTime(getStr(status, "completedAt")); !t.IsZero() {
+		events = append(events, TimelineEvent{
+			Timestamp: t, Type: "chain.completed", Source: "crd", Resource: resource,
+			Message: getStr(status, "phase"),
+		})
+	}
+	return events
+}
+
+// knightReadyEvents reports when each of the mission's knights last became
+// Ready, taken from the Knight CR's Ready condition.
+func knightReadyEvents(obj *unstructured.Unstructured) []TimelineEvent {
+	status := getNestedMap(obj.Object, "status")
+	for _, c := range getSlice(status, "conditions") {
+		cm, ok := c.(map[string]interface{})
+		if !ok || getStr(cm, "type") != "Ready" || getStr(cm, "status") != "True" {
+			continue
+		}
+		if t := parseCRTime(getStr(cm, "lastTransitionTime")); !t.IsZero() {
+			return []TimelineEvent{{
+				Timestamp: t, Type: "knight.ready", Source: "crd", Resource: "knight/" + obj.GetName(),
+			}}
+		}
+	}
+	return nil
+}
+
+// k8sTimelineEvents lists Kubernetes Events whose involved object is one of
+// the given resources (kind → names). The fake clientset used in tests does
+// not honour field selectors, so filtering happens here.
+func k8sTimelineEvents(ctx context.Context, namespace string, involved map[string]map[string]bool) []TimelineEvent {
+	if k8sClient == nil {
+		return nil
+	}
+	list, err := k8sClient.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		slog.Warn("K8s event list error", "error", err)
+		return nil
+	}
+	var events []TimelineEvent
+	for _, ev := range list.Items {
+		names := involved[ev.InvolvedObject.Kind]
+		if !names[ev.InvolvedObject.Name] {
+			continue
+		}
+		t := ev.LastTimestamp.Time
+		if t.IsZero() {
+			t = ev.EventTime.Time
+		}
+		if t.IsZero() {
+			t = ev.CreationTimestamp.Time
+		}
+		events = append(events, TimelineEvent{
+			Timestamp: t,
+			Type:      "k8s." + ev.Reason,
+			Source:    "k8s-event",
+			Resource:  strings.ToLower(ev.InvolvedObject.Kind) + "/" + ev.InvolvedObject.Name,
+			Message:   ev.Message,
+		})
+	}
+	return events
+}
+
+// payloadCost extracts a task cost in USD from a result payload. Knights
+// report it as a number or a decimal string under a few different keys.
+func payloadCost(data []byte) float64 {
+	var payload map[string]interface{}
+	if json.Unmarshal(data, &payload) != nil {
+		return 0
+	}
+	for _, key := range []string{"cost", "cost_usd", "costUSD"} {
+		switch v := payload[key].(type) {
+		case float64:
+			return v
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimPrefix(v, "$"), 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+// missionHistoryBatch is how many messages each fetch of a mission's
+// history asks for.
+const missionHistoryBatch = 256
+
+// missionHistoryEvents replays the JetStream history of
+// <prefix>.missions.<name> (and everything below it) into timeline entries:
+// task dispatches, task results and the running cost. Only the last limit
+// messages become entries; truncated reports that older ones were left out.
+func missionHistoryEvents(ctx context.Context, prefix, name string, limit int) ([]TimelineEvent, bool, error) {
+	base := fmt.Sprintf("%s.missions.%s", prefix, name)
+	streamName, err := js.StreamNameBySubject(ctx, base+".>")
+	if err != nil {
+		return nil, false, fmt.Errorf("no stream for %s: %w", base, err)
+	}
+	stream, err := js.Stream(ctx, streamName)
+	if err != nil {
+		return nil, false, err
+	}
+	// Ephemeral consumer with InactiveThreshold for auto-cleanup (#54)
+	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		DeliverPolicy:     jetstream.DeliverAllPolicy,
+		FilterSubjects:    []string{base, base + ".>"},
+		AckPolicy:         jetstream.AckNonePolicy,
+		InactiveThreshold: 30 * time.Second,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return replayMissionHistory(func(n int) ([]jetstream.Msg, error) {
+		batch, err := cons.FetchNoWait(n)
+		if err != nil {
+			return nil, err
+		}
+		var msgs []jetstream.Msg
+		for msg := range batch.Messages() {
+			msgs = append(msgs, msg)
+		}
+		return msgs, batch.Error()
+	}, limit)
+}
+
+// replayMissionHistory pages through a mission's history with fetch until
+// the consumer has nothing pending. Every result counts towards the running
+// cost, but only the last limit messages are kept, so a long mission shows
+// its latest activity rather than its first.
+func replayMissionHistory(fetch func(n int) ([]jetstream.Msg, error), limit int) ([]TimelineEvent, bool, error) {
+	var kept [][]TimelineEvent // entries per message, oldest first
+	truncated := false
+	var totalCost float64
+	for {
+		msgs, err := fetch(missionHistoryBatch)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, msg := range msgs {
+			ts := historyTimestamp(msg)
+			evType := "mission.event"
+			switch {
+			case strings.Contains(msg.Subject(), ".tasks."):
+				evType = "task.dispatched"
+			case strings.Contains(msg.Subject(), ".results."):
+				evType = "task.result"
+			}
+			entries := []TimelineEvent{{
+				Timestamp: ts, Type: evType, Source: "jetstream", Subject: msg.Subject(), Data: msg.Data(),
+			}}
+			if evType == "task.result" {
+				if cost := payloadCost(msg.Data()); cost > 0 {
+					totalCost += cost
+					data, _ := json.Marshal(map[string]float64{"cost": cost, "total": totalCost})
+					entries = append(entries, TimelineEvent{
+						Timestamp: ts, Type: "cost.increment", Source: "jetstream", Subject: msg.Subject(),
+						Message: fmt.Sprintf("+$%.4f (total $%.4f)", cost, totalCost), Data: data,
+					})
+				}
+			}
+			kept = append(kept, entries)
+			if len(kept) > limit {
+				kept, truncated = kept[1:], true
+			}
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		if meta, err := msgs[len(msgs)-1].Metadata(); err == nil && meta.NumPending == 0 {
+			break
+		}
+	}
+
+	var events []TimelineEvent
+	for _, entries := range kept {
+		events = append(events, entries...)
+	}
+	return events, truncated, nil
+}
+
+// historyTimestamp is eventTimestamp for replayed JetStream messages: payloads
+// without a producer timestamp fall back to the time the stream stored the
+// message rather than now.
+func historyTimestamp(msg jetstream.Msg) time.Time {
+	var payload struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(msg.Data(), &payload); err == nil && !payload.Timestamp.IsZero() {
+		return payload.Timestamp
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		return meta.Timestamp
+	}
+	return time.Now()
+}
+
+func missionTimelineHandler(namespace, fleetPrefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid mission name", http.StatusBadRequest)
+			return
+		}
+
+		// ?limit= caps the JetStream history at its latest messages
+		limit := 500
+		if limStr := r.URL.Query().Get("limit"); limStr != "" {
+			lim, err := strconv.Atoi(limStr)
+			if err != nil || lim <= 0 || lim > 2000 {
+				http.Error(w, "Invalid limit (1-2000)", http.StatusBadRequest)
+				return
+			}
+			limit = lim
+		}
+
+		ctx := r.Context()
+		obj, err := dynClient.Resource(missionGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, "Mission not found", http.StatusNotFound)
+			return
+		}
+		mission := parseMissionResource(obj.Object)
+
+		events := missionCREvents(obj)
+		involved := map[string]map[string]bool{
+			"Mission": {name: true},
+			"Chain":   {},
+			"Knight":  {},
+		}
+
+		// Chains: prefer the operator-created CR names from chainStatuses
+		for _, cs := range mission.ChainStatuses {
+			if cs.ChainCRName == "" {
+				continue
+			}
+			involved["Chain"][cs.ChainCRName] = true
+			chain, cerr := dynClient.Resource(chainGVR).Namespace(namespace).Get(ctx, cs.ChainCRName, metav1.GetOptions{})
+			if cerr != nil {
+				continue // chain may already be cleaned up
+			}
+			events = append(events, chainCREvents(chain)...)
+		}
+
+		knights := append([]string{}, mission.Knights...)
+		for _, ks := range mission.KnightStatuses {
+			knights = append(knights, ks.Name)
+		}
+		for _, k := range knights {
+			if k == "" || involved["Knight"][k] {
+				continue
+			}
+			involved["Knight"][k] = true
+			knight, kerr := dynClient.Resource(knightGVR).Namespace(namespace).Get(ctx, k, metav1.GetOptions{})
+			if kerr != nil {
+				continue
+			}
+			events = append(events, knightReadyEvents(knight)...)
+		}
+
+		events = append(events, k8sTimelineEvents(ctx, namespace, involved)...)
+
+		// JetStream history is best-effort — the timeline is still useful
+		// from CRs and Events alone when NATS is down
+		truncated := false
+		if js != nil {
+			prefix := roundTablePrefix(ctx, namespace, mission.RoundTableRef, fleetPrefix)
+			if hist, more, herr := missionHistoryEvents(ctx, prefix, name, limit); herr != nil {
+				slog.Debug("Mission history unavailable", "mission", name, "error", herr)
+			} else {
+				events, truncated = append(events, hist...), more
+			}
+		}
+
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+		if events == nil {
+			events = []TimelineEvent{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mission":   name,
+			"phase":     mission.Phase,
+			"events":    events,
+			"truncated": truncated,
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestMissionTimelineHandler verifies the timeline merges Mission, Chain and
+// Knight CR milestones with Kubernetes Events in chronological order.
+func TestMissionTimelineHandler(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+
+	mission := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Mission",
+			"metadata": map[string]interface{}{
+				"name":      "recon",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"objective": "Map the perimeter",
+				"knights": []interface{}{
+					map[string]interface{}{"name": "galahad"},
+				},
+			},
+			"status": map[string]interface{}{
+				"phase":       "Succeeded",
+				"startedAt":   "2024-01-01T10:00:00Z",
+				"completedAt": "2024-01-01T11:00:00Z",
+				"planningResult": map[string]interface{}{
+					"completedAt":     "2024-01-01T10:05:00Z",
+					"chainsGenerated": int64(1),
+				},
+				"chainStatuses": []interface{}{
+					map[string]interface{}{
+						"name":        "sweep",
+						"chainCRName": "recon-sweep",
+						"phase":       "Succeeded",
+					},
+				},
+			},
+		},
+	}
+	if _, err := dynClient.Resource(missionGVR).Namespace("test-namespace").Create(ctx, mission, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create mission: %v", err)
+	}
+
+	chain := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "Chain",
+			"metadata": map[string]interface{}{
+				"name":      "recon-sweep",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{},
+			"status": map[string]interface{}{
+				"phase":       "Succeeded",
+				"startedAt":   "2024-01-01T10:10:00Z",
+				"completedAt": "2024-01-01T10:50:00Z",
+				"stepStatuses": []interface{}{
+					map[string]interface{}{
+						"name":        "scan",
+						"phase":       "Succeeded",
+						"startedAt":   "2024-01-01T10:11:00Z",
+						"completedAt": "2024-01-01T10:40:00Z",
+					},
+				},
+			},
+		},
+	}
+	dynClient.Resource(chainGVR).Namespace("test-namespace").Create(ctx, chain, metav1.CreateOptions{})
+
+	knight := makeTestKnightCR("galahad", "test-namespace", "security")
+	unstructured.SetNestedSlice(knight.Object, []interface{}{
+		map[string]interface{}{
+			"type":               "Ready",
+			"status":             "True",
+			"lastTransitionTime": "2024-01-01T10:08:00Z",
+		},
+	}, "status", "conditions")
+	dynClient.Resource(knightGVR).Namespace("test-namespace").Create(ctx, knight, metav1.CreateOptions{})
+
+	events := []*corev1.Event{
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "recon.1", Namespace: "test-namespace"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Mission", Name: "recon"},
+			Reason:         "KnightRecruited",
+			Message:        "Recruited galahad",
+			LastTimestamp:  metav1.NewTime(time.Date(2024, 1, 1, 10, 6, 0, 0, time.UTC)),
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "other.1", Namespace: "test-namespace"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Mission", Name: "other"},
+			Reason:         "Unrelated",
+			LastTimestamp:  metav1.NewTime(time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)),
+		},
+	}
+	for _, ev := range events {
+		fakeK8sClient.CoreV1().Events("test-namespace").Create(ctx, ev, metav1.CreateOptions{})
+	}
+
+	req := httptest.NewRequest("GET", "/api/missions/recon/timeline", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Mission string          `json:"mission"`
+		Events  []TimelineEvent `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	var types []string
+	for i, ev := range response.Events {
+		if i > 0 && ev.Timestamp.Before(response.Events[i-1].Timestamp) {
+			t.Errorf("events not in chronological order at %d", i)
+		}
+		if ev.Type == "k8s.Unrelated" {
+			t.Error("event for another mission leaked into the timeline")
+		}
+		types = append(types, ev.Type)
+	}
+
+	// Creation timestamp is "now" in the fake client, so skip it when
+	// comparing the fixed-date sequence
+	expected := []string{
+		"mission.started", "planning.completed", "k8s.KnightRecruited", "knight.ready",
+		"chain.started", "step.started", "step.completed", "chain.completed", "mission.completed",
+	}
+	var got []string
+	for _, typ := range types {
+		if typ != "mission.created" {
+			got = append(got, typ)
+		}
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+// TestMissionTimelineHandlerErrors covers name validation and unknown missions.
+func TestMissionTimelineHandlerErrors(t *testing.T) {
+	router := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"unknown mission returns 404", "/api/missions/missing/timeline", http.StatusNotFound},
+		{"invalid name returns 400", "/api/missions/1bad/timeline", http.StatusBadRequest},
+		{"non-numeric limit returns 400", "/api/missions/missing/timeline?limit=all", http.StatusBadRequest},
+		{"zero limit returns 400", "/api/missions/missing/timeline?limit=0", http.StatusBadRequest},
+		{"oversized limit returns 400", "/api/missions/missing/timeline?limit=5000", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+// historyMsg is a replayed JetStream message; only the parts the timeline
+// reads are implemented.
+type historyMsg struct {
+	jetstream.Msg
+	subject string
+	data    []byte
+	pending uint64
+}
+
+func (m historyMsg) Subject() string { return m.subject }
+func (m historyMsg) Data() []byte    { return m.data }
+func (m historyMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{NumPending: m.pending, Timestamp: time.Unix(1700000000, 0)}, nil
+}
+
+// TestReplayMissionHistory pages through a history longer than the limit,
+// keeping the latest messages while the running cost covers them all.
+func TestReplayMissionHistory(t *testing.T) {
+	var history []jetstream.Msg
+	for i := 0; i < 600; i++ {
+		data := fmt.Sprintf(`{"cost": 0.5, "seq": %d}`, i)
+		history = append(history, historyMsg{subject: "fleet-a.missions.op-1.results.t" + fmt.Sprint(i), data: []byte(data), pending: uint64(599 - i)})
+	}
+	var fetches []int
+	fetch := func(n int) ([]jetstream.Msg, error) {
+		fetches = append(fetches, n)
+		batch := history[:min(n, len(history))]
+		history = history[len(batch):]
+		return batch, nil
+	}
+
+	events, truncated, err := replayMissionHistory(fetch, 10)
+	if err != nil || !truncated {
+		t.Fatalf("expected a truncated history, got %v %v", truncated, err)
+	}
+	if len(fetches) != 3 {
+		t.Errorf("expected the history paged in batches of %d, got %v", missionHistoryBatch, fetches)
+	}
+	// 10 results, each followed by its cost increment
+	if len(events) != 20 || events[0].Subject != "fleet-a.missions.op-1.results.t590" || events[18].Subject != "fleet-a.missions.op-1.results.t599" {
+		t.Fatalf("expected the last 10 messages, got %d events starting %s", len(events), events[0].Subject)
+	}
+	if last := events[19]; last.Type != "cost.increment" || last.Message != "+$0.5000 (total $300.0000)" {
+		t.Errorf("expected the running cost over the whole history, got %+v", last)
+	}
+
+	history = []jetstream.Msg{historyMsg{subject: "fleet-a.missions.op-1.tasks.t1", data: []byte(`{}`)}}
+	events, truncated, _ = replayMissionHistory(fetch, 10)
+	if truncated || len(events) != 1 || events[0].Type != "task.dispatched" {
+		t.Errorf("expected a short history replayed whole, got %v %+v", truncated, events)
+	}
+}
+
+// TestPayloadCost tests cost extraction from knight result payloads
+func TestPayloadCost(t *testing.T) {
+	tests := []struct {
+		payload  string
+		expected float64
+	}{
+		{`{"cost": 0.25}`, 0.25},
+		{`{"cost_usd": "0.5"}`, 0.5},
+		{`{"costUSD": "$1.25"}`, 1.25},
+		{`{"result": "ok"}`, 0},
+		{`not json`, 0},
+	}
+	for _, tt := range tests {
+		if got := payloadCost([]byte(tt.payload)); got != tt.expected {
+			t.Errorf("payloadCost(%s) = %v, expected %v", tt.payload, got, tt.expected)
+		}
+	}
+}
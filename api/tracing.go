@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates every span; until initTracing installs a provider they
+// are no-ops (the global provider forwards once one is set).
+var tracer = otel.Tracer("github.com/dapperdivers/roundtable-ui")
+
+// propagator carries W3C trace context and baggage in HTTP and NATS headers.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// initTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set; the exporter and sampler
+// read the rest of the standard OTEL_* variables themselves. The returned
+// func flushes buffered spans and is a no-op when tracing is off.
+func initTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+	endpoint := envOr("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", envOr("OTEL_EXPORTER_OTLP_ENDPOINT", ""))
+	if endpoint == "" || envOr("OTEL_SDK_DISABLED", "") == "true" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("roundtable-ui")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMiddleware starts a server span per /api request, named by the
+// matched route template and continuing the caller's trace. It runs first
+// so the span covers auth, RBAC and rate limiting.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A WebSocket would make one span last the whole connection (and the
+		// recorder would break the upgrade); dispatches over it get their own.
+		if r.URL.Path == "/api/ws" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := routeLabel(r)
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(clientIP(r)),
+			))
+		defer span.End()
+
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sr.status))
+		if sr.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sr.status))
+		}
+	})
+}
+
+// tracingTransport wraps the Kubernetes clients' transport with a client
+// span per API server call. Calls outside a traced request (the warm pool
+// sampler, discovery) aren't worth a trace of their own and pass through.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return t.next.RoundTrip(req)
+	}
+	resourceName, namespace := k8sPathResource(req.URL.Path)
+	ctx, span := tracer.Start(req.Context(), "k8s "+req.Method+" "+resourceName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.ServerAddress(req.URL.Hostname()),
+		))
+	if namespace != "" {
+		span.SetAttributes(semconv.K8SNamespaceName(namespace))
+	}
+	req = req.Clone(ctx)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+// k8sPathResource extracts the resource type and namespace from an API
+// server path such as /apis/ai.roundtable.io/v1alpha1/namespaces/roundtable/missions/m1,
+// keeping span names free of object names.
+func k8sPathResource(path string) (resourceName, namespace string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:] // api/v1
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:] // apis/group/version
+	default:
+		return path, ""
+	}
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		namespace = parts[1]
+		if len(parts) == 2 {
+			return "namespaces", namespace
+		}
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return "discovery", namespace
+	}
+	resourceName = parts[0]
+	if len(parts) >= 3 {
+		resourceName += "/" + parts[2] // subresource, e.g. pods/log
+	}
+	return resourceName, namespace
+}
+
+// natsHeaderCarrier adapts NATS headers for the propagator. Unlike
+// propagation.HeaderCarrier it keeps keys as given ("traceparent"), since
+// NATS header lookups are case-sensitive.
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string {
+	if v := c[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c natsHeaderCarrier) Set(key, value string) { c[key] = []string{value} }
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// natsMsg builds a message carrying ctx's trace context in its headers, so
+// a knight can continue the trace.
+func natsMsg(ctx context.Context, subject string, data []byte) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	propagator.Inject(ctx, natsHeaderCarrier(msg.Header))
+	if len(msg.Header) == 0 || (nc != nil && !nc.HeadersSupported()) {
+		msg.Header = nil
+	}
+	return msg
+}
+
+// startNATSSpan starts a span for a NATS operation. template is the subject
+// with its variable tokens named (e.g. "{prefix}.tasks.{domain}.{task_id}")
+// and names the span, keeping task ids out of span names.
+func startNATSSpan(ctx context.Context, operation, subject, template string, size int, kind trace.SpanKind) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+template,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("nats"),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(subject),
+			semconv.MessagingDestinationTemplate(template),
+			semconv.MessagingMessageBodySize(size),
+		))
+}
+
+// natsPublish publishes data to subject under a producer span.
+func natsPublish(ctx context.Context, subject, template string, data []byte) error {
+	ctx, span := startNATSSpan(ctx, "publish", subject, template, len(data), trace.SpanKindProducer)
+	err := nc.PublishMsg(natsMsg(ctx, subject, data))
+	endSpan(span, err)
+	return err
+}
+
+// natsRequest sends a request under a client span covering the wait for
+// the reply, which is where a slow knight shows up.
+func natsRequest(ctx context.Context, subject, template string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	ctx, span := startNATSSpan(ctx, "request", subject, template, len(data), trace.SpanKindClient)
+	msg, err := nc.RequestMsg(natsMsg(ctx, subject, data), timeout)
+	endSpan(span, err)
+	return msg, err
+}
+
+// shutdownTracing flushes spans on exit, bounded by ctx.
+func shutdownTracing(ctx context.Context, shutdown func(context.Context) error) {
+	if err := shutdown(ctx); err != nil {
+		slog.Warn("Trace export on shutdown failed", "error", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// testCollector is an in-process OTLP/HTTP trace receiver.
+type testCollector struct {
+	*httptest.Server
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	c := &testCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req coltracepb.ExportTraceServiceRequest
+		if r.URL.Path != "/v1/traces" || proto.Unmarshal(body, &req) != nil {
+			http.Error(w, "bad export", http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+		c.mu.Unlock()
+		resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *testCollector) span(name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestTracingExportsOTLP(t *testing.T) {
+	collector := newTestCollector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	shutdown, err := initTracing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A Kubernetes API server, reached through the traced transport
+	var apiTraceparent string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiTraceparent = r.Header.Get("Traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"roundtable"}}`)
+	}))
+	defer apiServer.Close()
+	cfg := &rest.Config{Host: apiServer.URL}
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper { return tracingTransport{next: rt} })
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var natsHeader map[string][]string
+	r := mux.NewRouter()
+	r.Use(tracingMiddleware)
+	r.HandleFunc("/api/fleet/{knight}/session", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := cs.CoreV1().Namespaces().Get(r.Context(), "roundtable", metav1.GetOptions{}); err != nil {
+			t.Errorf("namespace get: %v", err)
+		}
+		// What natsRequest does, minus the connection
+		ctx, span := startNATSSpan(r.Context(), "request", "fleet-a.introspect.Galahad", "{prefix}.introspect.{knight}", 2, trace.SpanKindClient)
+		natsHeader = natsMsg(ctx, "fleet-a.introspect.Galahad", []byte("{}")).Header
+		endSpan(span, nil)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/fleet/galahad/session", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	server := collector.span("GET /api/fleet/{knight}/session")
+	k8s := collector.span("k8s GET namespaces")
+	nats := collector.span("request {prefix}.introspect.{knight}")
+	if server == nil || k8s == nil || nats == nil {
+		t.Fatalf("expected server, k8s and nats spans exported, got %d spans", len(collector.spans))
+	}
+	for _, s := range []*tracepb.Span{server, k8s, nats} {
+		if hex.EncodeToString(s.TraceId) != traceID {
+			t.Errorf("span %q not in the caller's trace", s.Name)
+		}
+	}
+	if hex.EncodeToString(server.ParentSpanId) != "00f067aa0ba902b7" {
+		t.Error("expected the server span parented on the incoming traceparent")
+	}
+	if string(k8s.ParentSpanId) != string(server.SpanId) || string(nats.ParentSpanId) != string(server.SpanId) {
+		t.Error("expected k8s and nats spans children of the route span")
+	}
+	if !strings.Contains(apiTraceparent, hex.EncodeToString(k8s.SpanId)) {
+		t.Errorf("expected trace context sent to the API server, got %q", apiTraceparent)
+	}
+	if tp := natsHeader["traceparent"]; len(tp) != 1 || tp[0] != "00-"+traceID+"-"+hex.EncodeToString(nats.SpanId)+"-01" {
+		t.Errorf("expected traceparent in the NATS headers, got %v", natsHeader)
+	}
+}
+
+func TestNATSMsgWithoutTrace(t *testing.T) {
+	msg := natsMsg(context.Background(), "fleet-a.tasks.security.t1", []byte("{}"))
+	if msg.Header != nil || msg.Subject != "fleet-a.tasks.security.t1" || string(msg.Data) != "{}" {
+		t.Errorf("expected a plain message outside a trace, got %+v", msg)
+	}
+}
+
+func TestK8sPathResource(t *testing.T) {
+	tests := []struct{ path, resource, namespace string }{
+		{"/apis/ai.roundtable.io/v1alpha1/namespaces/roundtable/missions/m1", "missions", "roundtable"},
+		{"/apis/ai.roundtable.io/v1alpha1/namespaces/roundtable/roundtables", "roundtables", "roundtable"},
+		{"/api/v1/namespaces/roundtable/pods/knight-galahad/log", "pods/log", "roundtable"},
+		{"/api/v1/namespaces/roundtable", "namespaces", "roundtable"},
+		{"/apis/ai.roundtable.io/v1alpha1", "discovery", ""},
+		{"/version", "/version", ""},
+	}
+	for _, tt := range tests {
+		if res, ns := k8sPathResource(tt.path); res != tt.resource || ns != tt.namespace {
+			t.Errorf("k8sPathResource(%s) = %s, %s; want %s, %s", tt.path, res, ns, tt.resource, tt.namespace)
+		}
+	}
+}
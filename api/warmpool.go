@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxWarmPoolSize bounds spec.warmPool.size so a typo can't provision a
+// hundred idle pods.
+const maxWarmPoolSize = 50
+
+// WarmPoolSample is one point of a RoundTable's warm pool time series.
+type WarmPoolSample struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Available    int       `json:"available"`
+	Provisioning int       `json:"provisioning"`
+	Claimed      int       `json:"claimed"`
+	Target       int       `json:"target"`
+}
+
+// WarmPoolHistory is the response for GET /roundtables/{name}/warmpool/history.
+// Exhausted is the fraction of samples with no available knight — a pool
+// that is often exhausted is too small for its claim rate.
+type WarmPoolHistory struct {
+	Name         string           `json:"name"`
+	Interval     string           `json:"interval"`
+	Samples      []WarmPoolSample `json:"samples"`
+	MinAvailable int              `json:"minAvailable"`
+	MaxClaimed   int              `json:"maxClaimed"`
+	Exhausted    float64          `json:"exhausted"`
+}
+
+// warmPoolSampler periodically records every RoundTable's warm pool counts
+// into a fixed-size in-process ring per table and mirrors the latest values
+// into Prometheus. History is lost on restart; Prometheus keeps the long view.
+type warmPoolSampler struct {
+	mu       sync.RWMutex
+	interval time.Duration
+	capacity int
+	history  map[string][]WarmPoolSample
+}
+
+func newWarmPoolSampler(interval time.Duration, capacity int) *warmPoolSampler {
+	return &warmPoolSampler{
+		interval: interval,
+		capacity: capacity,
+		history:  map[string][]WarmPoolSample{},
+	}
+}
+
+// run samples until ctx is cancelled.
+func (s *warmPoolSampler) run(ctx context.Context, namespace string) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.sample(ctx, namespace); err != nil {
+			slog.Warn("Warm pool sample failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample records one point for every RoundTable and drops tables that no
+// longer exist, along with their gauges.
+func (s *warmPoolSampler) sample(ctx context.Context, namespace string) error {
+	if dynClient == nil {
+		return nil
+	}
+	list, err := dynClient.Resource(roundTableGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	seen := map[string]bool{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range list.Items {
+		rt := parseRoundTableResource(item.Object)
+		seen[rt.Name] = true
+		point := WarmPoolSample{Timestamp: now}
+		if wp := rt.WarmPool; wp != nil {
+			point.Available, point.Provisioning, point.Claimed, point.Target = wp.Available, wp.Provisioning, wp.Claimed, wp.Target
+		}
+		samples := append(s.history[rt.Name], point)
+		if len(samples) > s.capacity {
+			samples = samples[len(samples)-s.capacity:]
+		}
+		s.history[rt.Name] = samples
+
+		warmPoolKnights.WithLabelValues(rt.Name, "available").Set(float64(point.Available))
+		warmPoolKnights.WithLabelValues(rt.Name, "provisioning").Set(float64(point.Provisioning))
+		warmPoolKnights.WithLabelValues(rt.Name, "claimed").Set(float64(point.Claimed))
+		warmPoolKnights.WithLabelValues(rt.Name, "target").Set(float64(point.Target))
+	}
+	for name := range s.history {
+		if !seen[name] {
+			delete(s.history, name)
+			warmPoolKnights.DeletePartialMatch(map[string]string{"roundtable": name})
+		}
+	}
+	return nil
+}
+
+// samples returns a copy of a table's history at or after since.
+func (s *warmPoolSampler) samples(name string, since time.Time) []WarmPoolSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []WarmPoolSample{}
+	for _, p := range s.history[name] {
+		if !p.Timestamp.Before(since) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// warmPoolHistoryHandler serves the sampled series, optionally limited to
+// the last ?since=<duration> (e.g. 6h).
+func warmPoolHistoryHandler(namespace string, sampler *warmPoolSampler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				http.Error(w, "Invalid since duration", http.StatusBadRequest)
+				return
+			}
+			since = time.Now().Add(-d)
+		}
+
+		if _, err := dynClient.Resource(roundTableGVR).Namespace(namespace).Get(r.Context(), name, metav1.GetOptions{}); err != nil {
+			http.Error(w, "RoundTable not found", http.StatusNotFound)
+			return
+		}
+
+		history := WarmPoolHistory{
+			Name:     name,
+			Interval: sampler.interval.String(),
+			Samples:  sampler.samples(name, since),
+		}
+		exhausted := 0
+		for i, p := range history.Samples {
+			if i == 0 || p.Available < history.MinAvailable {
+				history.MinAvailable = p.Available
+			}
+			if p.Claimed > history.MaxClaimed {
+				history.MaxClaimed = p.Claimed
+			}
+			if p.Available == 0 {
+				exhausted++
+			}
+		}
+		if n := len(history.Samples); n > 0 {
+			history.Exhausted = float64(exhausted) / float64(n)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+// warmPoolSizeHandler handles PATCH /roundtables/{name}/warmpool with a body
+// of {"size": N}, setting the pool's target size (spec.warmPool.size).
+func warmPoolSizeHandler(namespace string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if dynClient == nil {
+			http.Error(w, "Kubernetes not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name := mux.Vars(r)["name"]
+		if !validKnightName.MatchString(name) {
+			http.Error(w, "Invalid roundtable name", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Size *int `json:"size"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Size == nil {
+			http.Error(w, "Request body must be {\"size\": <int>}", http.StatusBadRequest)
+			return
+		}
+		if *req.Size < 0 || *req.Size > maxWarmPoolSize {
+			http.Error(w, fmt.Sprintf("size must be 0-%d", maxWarmPoolSize), http.StatusBadRequest)
+			return
+		}
+
+		rts := dynClient.Resource(roundTableGVR).Namespace(namespace)
+		if _, err := rts.Get(r.Context(), name, metav1.GetOptions{}); err != nil {
+			http.Error(w, "RoundTable not found", http.StatusNotFound)
+			return
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"spec": map[string]interface{}{
+				"warmPool": map[string]interface{}{"size": int64(*req.Size)},
+			},
+		})
+		obj, err := rts.Patch(r.Context(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			slog.Error("Warm pool patch error", "roundtable", name, "error", err)
+			http.Error(w, "Failed to update warm pool", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Warm pool resized", "roundtable", name, "size", *req.Size)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parseRoundTableResource(obj.Object))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func makeTestWarmPoolTable(name string, available int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ai.roundtable.io/v1alpha1",
+			"kind":       "RoundTable",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"warmPool": map[string]interface{}{"size": int64(3)},
+			},
+			"status": map[string]interface{}{
+				"warmPool": map[string]interface{}{
+					"available":    available,
+					"provisioning": int64(0),
+					"claimed":      3 - available,
+				},
+			},
+		},
+	}
+}
+
+// TestWarmPoolSize covers target size validation and the resulting spec
+func TestWarmPoolSize(t *testing.T) {
+	router := setupTestRouter()
+	dynClient.Resource(roundTableGVR).Namespace("test-namespace").Create(context.Background(), makeTestWarmPoolTable("pool-a", 2), metav1.CreateOptions{})
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"missing size", "/api/roundtables/pool-a/warmpool", `{}`, http.StatusBadRequest},
+		{"negative", "/api/roundtables/pool-a/warmpool", `{"size": -1}`, http.StatusBadRequest},
+		{"too large", "/api/roundtables/pool-a/warmpool", `{"size": 500}`, http.StatusBadRequest},
+		{"unknown table", "/api/roundtables/missing/warmpool", `{"size": 2}`, http.StatusNotFound},
+		{"resize", "/api/roundtables/pool-a/warmpool", `{"size": 5}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	obj, _ := dynClient.Resource(roundTableGVR).Namespace("test-namespace").Get(context.Background(), "pool-a", metav1.GetOptions{})
+	rt := parseRoundTableResource(obj.Object)
+	if rt.WarmPool == nil || rt.WarmPool.Target != 5 || rt.WarmPool.Available != 2 {
+		t.Errorf("expected target 5 with status untouched, got %+v", rt.WarmPool)
+	}
+}
+
+// TestWarmPoolHistory samples a table several times and checks the ring
+// bound, summary stats and gauges
+func TestWarmPoolHistory(t *testing.T) {
+	router := setupTestRouter()
+	ctx := context.Background()
+	rts := dynClient.Resource(roundTableGVR).Namespace("test-namespace")
+
+	table := makeTestWarmPoolTable("pool-h", 2)
+	rts.Create(ctx, table, metav1.CreateOptions{})
+	for _, available := range []int64{2, 1, 0, 1} {
+		unstructured.SetNestedField(table.Object, available, "status", "warmPool", "available")
+		unstructured.SetNestedField(table.Object, 3-available, "status", "warmPool", "claimed")
+		rts.Update(ctx, table, metav1.UpdateOptions{})
+		if err := testWarmPools.sample(ctx, "test-namespace"); err != nil {
+			t.Fatalf("sample failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/roundtables/pool-h/warmpool/history", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var history WarmPoolHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	// The test sampler keeps 3 samples: the first (available=2) is evicted
+	if len(history.Samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(history.Samples))
+	}
+	if history.MinAvailable != 0 || history.MaxClaimed != 3 {
+		t.Errorf("expected minAvailable 0 and maxClaimed 3, got %d/%d", history.MinAvailable, history.MaxClaimed)
+	}
+	if history.Exhausted < 0.33 || history.Exhausted > 0.34 {
+		t.Errorf("expected a third of samples exhausted, got %f", history.Exhausted)
+	}
+	if got := testutil.ToFloat64(warmPoolKnights.WithLabelValues("pool-h", "available")); got != 1 {
+		t.Errorf("expected available gauge 1, got %f", got)
+	}
+	if got := testutil.ToFloat64(warmPoolKnights.WithLabelValues("pool-h", "target")); got != 3 {
+		t.Errorf("expected target gauge 3, got %f", got)
+	}
+
+	// Deleted tables drop out of the history
+	rts.Delete(ctx, "pool-h", metav1.DeleteOptions{})
+	testWarmPools.sample(ctx, "test-namespace")
+	if got := testWarmPools.samples("pool-h", table.GetCreationTimestamp().Time); len(got) != 0 {
+		t.Errorf("expected history cleared for deleted table, got %d samples", len(got))
+	}
+
+	req = httptest.NewRequest("GET", "/api/roundtables/pool-h/warmpool/history?since=bogus", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid since, got %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// wsTicketTTL is how long a ticket can wait to be redeemed; the UI
+	// connects immediately after asking for one.
+	wsTicketTTL = 30 * time.Second
+	// wsAuthRecheckInterval is how often an open socket's credentials are
+	// re-checked (piggybacking on the ping ticker).
+	wsAuthRecheckInterval = 30 * time.Second
+)
+
+type credentialCheckKey struct{}
+
+// withCredentialCheck attaches a check reporting whether the credential a
+// request authenticated with is still valid, for connections that outlive
+// the request.
+func withCredentialCheck(ctx context.Context, check func(time.Time) bool) context.Context {
+	return context.WithValue(ctx, credentialCheckKey{}, check)
+}
+
+// credentialActive reports whether the request's credential is still valid:
+// a JWT hasn't expired, a named API key hasn't been removed, rotated or
+// expired. Requests without a check (shared key, auth disabled) stay valid.
+func credentialActive(r *http.Request, now time.Time) bool {
+	if check, ok := r.Context().Value(credentialCheckKey{}).(func(time.Time) bool); ok {
+		return check(now)
+	}
+	return true
+}
+
+// wsTicket is what a ticket grants: the issuing caller's identity and role.
+type wsTicket struct {
+	identity Identity
+	role     string
+	check    func(time.Time) bool
+	expires  time.Time
+}
+
+// wsTicketStore holds outstanding tickets by the SHA-256 of their value.
+type wsTicketStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	tickets map[string]wsTicket
+}
+
+func newWSTicketStore(ttl time.Duration) *wsTicketStore {
+	return &wsTicketStore{ttl: ttl, tickets: map[string]wsTicket{}}
+}
+
+// wsTickets are the tickets issued by POST /api/ws/ticket.
+var wsTickets = newWSTicketStore(wsTicketTTL)
+
+func ticketDigest(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// issue creates a single-use ticket bound to the caller of r.
+func (s *wsTicketStore) issue(r *http.Request, now time.Time) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(raw)
+	t := wsTicket{identity: requestIdentity(r), role: requestRole(r), expires: now.Add(s.ttl)}
+	if check, ok := r.Context().Value(credentialCheckKey{}).(func(time.Time) bool); ok {
+		t.check = check
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for digest, old := range s.tickets {
+		if !now.Before(old.expires) {
+			delete(s.tickets, digest)
+		}
+	}
+	s.tickets[ticketDigest(ticket)] = t
+	return ticket, t.expires, nil
+}
+
+// redeem consumes a ticket; each one works once, before it expires.
+func (s *wsTicketStore) redeem(ticket string, now time.Time) (wsTicket, bool) {
+	digest := ticketDigest(ticket)
+	s.mu.Lock()
+	t, ok := s.tickets[digest]
+	delete(s.tickets, digest)
+	s.mu.Unlock()
+	if !ok || !now.Before(t.expires) || (t.check != nil && !t.check(now)) {
+		return wsTicket{}, false
+	}
+	return t, true
+}
+
+// context returns ctx carrying the ticket's identity, role and credential
+// check, as authMiddleware would have set them.
+func (t wsTicket) context(ctx context.Context) context.Context {
+	ctx = withRole(withIdentity(ctx, t.identity), t.role)
+	if t.check != nil {
+		ctx = withCredentialCheck(ctx, t.check)
+	}
+	return ctx
+}
+
+// wsTicketHandler serves POST /ws/ticket: a single-use ticket for
+// /api/ws?ticket=, so browsers can authenticate the WebSocket without
+// putting a long-lived key in the URL.
+func wsTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ticket, expires, err := wsTickets.issue(r, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to issue ticket", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ticket":    ticket,
+		"expiresAt": expires.UTC().Format(time.RFC3339),
+	})
+}
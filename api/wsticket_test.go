@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWSTicketStore(t *testing.T) {
+	store := newWSTicketStore(wsTicketTTL)
+	req := httptest.NewRequest("POST", "/api/ws/ticket", nil)
+	req = req.WithContext(withRole(withIdentity(req.Context(), Identity{Subject: "u-1", Method: "oidc"}), roleOperator))
+	now := time.Now()
+
+	ticket, expires, err := store.issue(req, now)
+	if err != nil || len(ticket) < 40 || !expires.Equal(now.Add(wsTicketTTL)) {
+		t.Fatalf("unexpected ticket %q %v %v", ticket, expires, err)
+	}
+	got, ok := store.redeem(ticket, now)
+	if !ok || got.identity.Subject != "u-1" || got.role != roleOperator {
+		t.Errorf("expected ticket bound to the issuer, got %+v %v", got, ok)
+	}
+	if _, ok := store.redeem(ticket, now); ok {
+		t.Error("expected ticket to be single-use")
+	}
+
+	ticket, _, _ = store.issue(req, now)
+	if _, ok := store.redeem(ticket, now.Add(wsTicketTTL)); ok {
+		t.Error("expected expired ticket rejected")
+	}
+
+	revoked := req.WithContext(withCredentialCheck(req.Context(), func(time.Time) bool { return false }))
+	ticket, _, _ = store.issue(revoked, now)
+	if _, ok := store.redeem(ticket, now); ok {
+		t.Error("expected ticket of a revoked credential rejected")
+	}
+
+	// Expired tickets are dropped when new ones are issued
+	store.issue(req, now.Add(time.Hour))
+	if len(store.tickets) != 1 {
+		t.Errorf("expected expired tickets swept, have %d", len(store.tickets))
+	}
+}
+
+func TestWSTicketAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[{"name":"ci","hash":%q,"role":"operator","scopes":["ws:read"]}]}`, testKeyHash("ci-secret")))
+	var err error
+	if apiKeys, err = newAPIKeyStore(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { apiKeys = nil }()
+
+	var wsRequest *http.Request
+	r := mux.NewRouter()
+	r.Use(authMiddleware)
+	r.Use(rbacMiddleware)
+	r.HandleFunc("/api/ws", func(w http.ResponseWriter, r *http.Request) { wsRequest = r })
+	r.HandleFunc("/api/ws/ticket", wsTicketHandler).Methods("POST")
+	r.HandleFunc("/api/fleet", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	do := func(method, target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/ws/ticket", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tickets to require credentials, got %d", w.Code)
+	}
+	w := do("POST", "/api/ws/ticket", "ci-secret")
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &issued)
+	if w.Code != http.StatusOK || issued.Ticket == "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected a ticket, got %d %s", w.Code, w.Body.String())
+	}
+
+	ticket := url.QueryEscape(issued.Ticket)
+	if w := do("GET", "/api/fleet?ticket="+ticket, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected tickets accepted only on /api/ws, got %d", w.Code)
+	}
+	if w := do("GET", "/api/ws?ticket="+ticket, ""); w.Code != http.StatusOK || requestIdentity(wsRequest).Subject != "key:ci" || requestRole(wsRequest) != roleOperator {
+		t.Fatalf("expected ticket redeemed as the ci key, got %d", w.Code)
+	}
+	if w := do("GET", "/api/ws?ticket="+ticket, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected ticket reuse rejected, got %d", w.Code)
+	}
+	if w := do("GET", "/api/ws?api_key=ci-secret", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected ?api_key= no longer accepted, got %d", w.Code)
+	}
+	if w := do("GET", "/api/ws", "ci-secret"); w.Code != http.StatusOK {
+		t.Errorf("expected bearer header still accepted, got %d", w.Code)
+	}
+
+	// Removing the key revokes the open connection's credentials
+	if !credentialActive(wsRequest, time.Now()) {
+		t.Fatal("expected credentials active before rotation")
+	}
+	writeTestKeys(t, path, fmt.Sprintf(`{"keys":[{"name":"ci","hash":%q,"scopes":["ws:read"]}]}`, testKeyHash("ci-rotated")))
+	if credentialActive(wsRequest, time.Now().Add(apiKeysCheckInterval)) {
+		t.Error("expected rotated-out key revoked on the open socket")
+	}
+}
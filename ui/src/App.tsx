@@ -14,6 +14,7 @@ import { ArchitecturePage } from './pages/Architecture'
 import { RoundTablesPage } from './pages/RoundTables'
 import { ToastProvider, useToast } from './components/Toast'
 import { ErrorBoundary } from './components/ErrorBoundary'
+import { DependencyBanner } from './components/DependencyBanner'
 import { useWebSocket, WebSocketProvider } from './hooks/useWebSocket'
 import { useTaskNotifications } from './hooks/useTaskNotifications'
 
@@ -110,6 +111,7 @@ export default function App() {
 
       {/* Main content */}
       <main className="flex-1 overflow-auto p-4 md:p-8 mt-14 md:mt-0">
+        <DependencyBanner />
         <ErrorBoundary>
           <Routes>
             <Route path="/" element={<DashboardPage />} />
@@ -0,0 +1,39 @@
+import { AlertTriangle } from 'lucide-react'
+import { usePolledFetch } from '../hooks/usePolledFetch'
+
+interface ReadinessReport {
+  status: 'ok' | 'degraded' | 'unavailable'
+  components: Array<{ name: string; status: 'ok' | 'down'; required: boolean; error?: string }>
+}
+
+const POLL_MS = 30_000
+
+/**
+ * Names the backend dependencies that are down (NATS, JetStream,
+ * Kubernetes, CRDs, vault), so empty pages aren't mistaken for an idle
+ * fleet. Hidden while everything is healthy or the report can't be fetched.
+ */
+export function DependencyBanner() {
+  const { data } = usePolledFetch<ReadinessReport | null>('/api/health?verbose=1', POLL_MS, null)
+  if (!data || data.status === 'ok') return null
+
+  const down = data.components.filter((c) => c.status !== 'ok')
+  const color = data.status === 'unavailable'
+    ? 'bg-red-500/10 border-red-500/30 text-red-400'
+    : 'bg-yellow-500/10 border-yellow-500/30 text-yellow-400'
+  return (
+    <div className={`mb-4 border rounded-lg p-3 text-sm flex items-start gap-2 ${color}`}>
+      <AlertTriangle className="w-4 h-4 mt-0.5 shrink-0" />
+      <div>
+        {data.status === 'unavailable' ? 'Backend unavailable' : 'Running degraded'}:{' '}
+        {down.map((c, i) => (
+          <span key={c.name}>
+            {i > 0 && '; '}
+            <span className="font-medium">{c.name}</span>
+            {c.error && <span className="opacity-75"> ({c.error})</span>}
+          </span>
+        ))}
+      </div>
+    </div>
+  )
+}
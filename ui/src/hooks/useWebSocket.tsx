@@ -1,8 +1,8 @@
 import { useState, useEffect, useRef, useCallback, createContext, useContext, type ReactNode } from 'react'
-import { apiGet } from '../lib/api'
+import { apiGet, apiPost } from '../lib/api'
 
 export interface NatsEvent {
-  type: 'task' | 'result' | 'mission' | 'chain'
+  type: 'task' | 'result' | 'mission' | 'chain' | 'briefing.created'
   subject: string
   data: unknown
   timestamp: string
@@ -39,7 +39,18 @@ function useWebSocketConnection() {
   const mountedRef = useRef(true)
   const seenEvents = useRef(new Set<string>())
 
-  const connect = useCallback(() => {
+  const connect = useCallback(async () => {
+    if (!mountedRef.current) return
+
+    // Browsers can't set headers on a WebSocket, so authenticate it with a
+    // short-lived single-use ticket rather than putting a key in the URL.
+    let query = ''
+    try {
+      const { ticket } = await apiPost<{ ticket: string }>('/api/ws/ticket', {})
+      query = `?ticket=${encodeURIComponent(ticket)}`
+    } catch {
+      // fall through — the upgrade will report 401 if a ticket was needed
+    }
     if (!mountedRef.current) return
 
     // Clean up existing connection
@@ -49,7 +60,7 @@ function useWebSocketConnection() {
     }
 
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const ws = new WebSocket(`${protocol}//${window.location.host}/api/ws`)
+    const ws = new WebSocket(`${protocol}//${window.location.host}/api/ws${query}`)
 
     ws.onopen = () => {
       if (!mountedRef.current) return
@@ -82,7 +93,13 @@ function useWebSocketConnection() {
     ws.onmessage = (e) => {
       if (!mountedRef.current) return
       try {
-        const event: NatsEvent = { ...JSON.parse(e.data), live: true }
+        const parsed = JSON.parse(e.data)
+        // Error frames answer our own commands (e.g. a rate-limited dispatch)
+        if (parsed.type === 'error') {
+          setError(parsed.error)
+          return
+        }
+        const event: NatsEvent = { ...parsed, live: true }
         const key = eventKey(event)
         if (seenEvents.current.has(key)) return // deduplicate
         seenEvents.current.add(key)
@@ -136,7 +153,7 @@ function useWebSocketConnection() {
       })
       .catch(() => {}) // silently fail — WS will still work
 
-    connect()
+    void connect()
     return () => {
       mountedRef.current = false
       if (reconnectTimer.current) clearTimeout(reconnectTimer.current)
@@ -17,6 +17,7 @@ export interface RoundTable {
     available: number
     provisioning: number
     claimed: number
+    target?: number
   }
   policies?: {
     maxConcurrentTasks: number
@@ -31,6 +32,48 @@ export interface RoundTable {
   ephemeral?: boolean
 }
 
+/** GET /api/roundtables/{name}/members (api/roundtables.go RoundTableMembers) */
+export interface RoundTableMembers {
+  name: string
+  natsPrefix: string
+  knights: {
+    name: string
+    domain: string
+    phase: string
+    ready: boolean
+    suspended: boolean
+    tasksCompleted: number
+    tasksFailed: number
+    totalCost?: string
+  }[]
+  missions: {
+    name: string
+    phase: string
+    totalCost?: string
+    costBudgetUSD?: string
+    knights: number
+    chains: number
+  }[]
+  chains: {
+    name: string
+    phase: string
+    missionRef?: string
+    steps: number
+    runsCompleted: number
+    runsFailed: number
+  }[]
+  totals: {
+    knights: number
+    knightsReady: number
+    missions: number
+    activeMissions: number
+    chains: number
+    tasksCompleted: number
+    tasksFailed: number
+    costUSD: number
+  }
+}
+
 export interface ChainStep {
   name: string
   knight: string
@@ -64,3 +107,48 @@ export interface Chain {
   runsCompleted?: number
   runsFailed?: number
 }
+
+export interface BriefingSearchResult {
+  date: string
+  file: string
+  score: number
+  matches: number
+  // HTML-escaped by the server, with matches wrapped in <mark>
+  snippet: string
+}
+
+export interface BriefingSearchResponse {
+  query: string
+  total: number
+  results: BriefingSearchResult[]
+}
+
+export interface BriefingSummary {
+  date: string
+  file: string
+  title: string
+  tags: string[]
+  modified: string
+}
+
+export interface BriefingSection {
+  level: number
+  title: string
+  content: string
+}
+
+export interface WikiLink {
+  target: string
+  heading?: string
+  alias?: string
+  // Vault-relative path; absent when the link doesn't resolve
+  path?: string
+}
+
+export interface BriefingDocument extends BriefingSummary {
+  frontMatter?: Record<string, unknown>
+  sections: BriefingSection[]
+  knights: string[]
+  missions: string[]
+  links: WikiLink[]
+}
@@ -1,8 +1,10 @@
 import { apiGet, apiGetText } from '../lib/api'
-import { useState, useEffect } from 'react'
-import { BookOpen, Calendar, FileText, ChevronLeft, ChevronRight, Clock } from 'lucide-react'
+import { useState, useEffect, type FormEvent } from 'react'
+import { BookOpen, Calendar, FileText, ChevronLeft, ChevronRight, Clock, Search, X } from 'lucide-react'
 import ReactMarkdown from 'react-markdown'
 import { Spinner, EmptyState, PageHeader } from '../components/ui'
+import { useWebSocket } from '../hooks/useWebSocket'
+import type { BriefingSearchResponse, BriefingSummary } from '../lib/types'
 
 // Helper to format date in human-friendly format
 function formatDate(dateStr: string): string {
@@ -45,28 +47,46 @@ function navigateDay(currentDate: string, direction: 'prev' | 'next'): string {
 }
 
 export function BriefingsPage() {
-  const [briefings, setBriefings] = useState<string[]>([])
+  const [briefings, setBriefings] = useState<BriefingSummary[]>([])
   const [selected, setSelected] = useState<string | null>(null)
   const [content, setContent] = useState<string>('')
   const [loading, setLoading] = useState(true)
   const [contentLoading, setContentLoading] = useState(false)
   const [contentError, setContentError] = useState(false)
+  const [query, setQuery] = useState('')
+  const [searchResults, setSearchResults] = useState<BriefingSearchResponse | null>(null)
+
+  const runSearch = (e: FormEvent) => {
+    e.preventDefault()
+    const q = query.trim()
+    if (!q) {
+      setSearchResults(null)
+      return
+    }
+    apiGet<BriefingSearchResponse>(`/api/briefings/search?q=${encodeURIComponent(q)}`)
+      .then(setSearchResults)
+      .catch(() => setSearchResults({ query: q, total: 0, results: [] }))
+  }
+
+  const { events } = useWebSocket()
+  // Refetch the archive when the server announces a new daily briefing
+  const latestDaily = events.find((e) => e.type === 'briefing.created' && e.subject.startsWith('Briefings/Daily/'))
+  const refreshKey = latestDaily ? `${latestDaily.subject}:${latestDaily.timestamp}` : ''
 
   useEffect(() => {
-    apiGet<string[]>('/api/briefings')
+    // The API returns briefings newest first
+    apiGet<BriefingSummary[]>('/api/briefings')
       .then((data) => {
-        // Sort newest first
-        const sorted = data.sort().reverse()
-        setBriefings(sorted)
+        setBriefings(data)
         setLoading(false)
         
         // Auto-select the most recent briefing
-        if (sorted.length > 0) {
-          setSelected(sorted[0])
+        if (data.length > 0) {
+          setSelected((current) => current ?? data[0].file)
         }
       })
       .catch(() => setLoading(false))
-  }, [])
+  }, [refreshKey])
 
   useEffect(() => {
     if (selected) {
@@ -107,7 +127,51 @@ export function BriefingsPage() {
         {/* Archive sidebar */}
         <div className="w-64 shrink-0">
           <div className="bg-roundtable-slate border border-roundtable-steel rounded-xl p-4">
-            <h2 className="text-sm font-medium text-gray-400 mb-3">Archives</h2>
+            <form onSubmit={runSearch} className="relative mb-3">
+              <Search className="w-3.5 h-3.5 text-gray-500 absolute left-2.5 top-2.5" />
+              <input
+                value={query}
+                onChange={e => setQuery(e.target.value)}
+                placeholder="Search briefings"
+                className="w-full pl-8 pr-7 py-1.5 bg-roundtable-navy border border-roundtable-steel rounded-lg text-sm text-white placeholder-gray-500 focus:outline-none focus:border-roundtable-gold/50"
+              />
+              {searchResults && (
+                <button
+                  type="button"
+                  onClick={() => { setQuery(''); setSearchResults(null) }}
+                  className="absolute right-2 top-2 text-gray-500 hover:text-white"
+                  title="Clear search"
+                >
+                  <X className="w-3.5 h-3.5" />
+                </button>
+              )}
+            </form>
+            <h2 className="text-sm font-medium text-gray-400 mb-3">
+              {searchResults ? `${searchResults.total} matches` : 'Archives'}
+            </h2>
+            {searchResults ? (
+              <div className="space-y-1 max-h-[70vh] overflow-y-auto">
+                {searchResults.results.map((r) => (
+                  <button
+                    key={r.file}
+                    onClick={() => setSelected(r.file)}
+                    className={`w-full text-left px-3 py-2 rounded-lg text-xs transition-colors ${
+                      selected === r.file
+                        ? 'bg-roundtable-gold/10 text-roundtable-gold'
+                        : 'text-gray-400 hover:text-white hover:bg-roundtable-steel/50'
+                    }`}
+                  >
+                    <div className="text-sm">📜 {r.date}</div>
+                    {/* Snippets are escaped server-side; only <mark> is markup */}
+                    <div
+                      className="mt-1 text-gray-500 [&_mark]:bg-roundtable-gold/30 [&_mark]:text-white"
+                      dangerouslySetInnerHTML={{ __html: r.snippet }}
+                    />
+                  </button>
+                ))}
+              </div>
+            ) : (
+            <>
             {loading && <p className="text-gray-500 text-sm">Loading...</p>}
             {!loading && briefings.length === 0 && (
               <p className="text-gray-500 text-xs">No briefings yet</p>
@@ -115,18 +179,30 @@ export function BriefingsPage() {
             <div className="space-y-1 max-h-[70vh] overflow-y-auto">
               {briefings.map((b) => (
                 <button
-                  key={b}
-                  onClick={() => setSelected(b)}
+                  key={b.file}
+                  onClick={() => setSelected(b.file)}
                   className={`w-full text-left px-3 py-2 rounded-lg text-sm transition-colors ${
-                    selected === b
+                    selected === b.file
                       ? 'bg-roundtable-gold/10 text-roundtable-gold'
                       : 'text-gray-400 hover:text-white hover:bg-roundtable-steel/50'
                   }`}
                 >
-                  📜 {b.replace('.md', '')}
+                  <div>📜 {b.date}</div>
+                  {b.title !== b.date && (
+                    <div className="text-xs text-gray-500 truncate">{b.title}</div>
+                  )}
+                  {b.tags.length > 0 && (
+                    <div className="flex flex-wrap gap-1 mt-1">
+                      {b.tags.map((tag) => (
+                        <span key={tag} className="text-[10px] px-1.5 rounded bg-roundtable-steel/50 text-gray-400">#{tag}</span>
+                      ))}
+                    </div>
+                  )}
                 </button>
               ))}
             </div>
+            </>
+            )}
           </div>
         </div>
 
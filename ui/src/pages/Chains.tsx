@@ -138,6 +138,20 @@ function StepKVOutput({ chainName, stepName }: { chainName: string; stepName: st
       .then(d => setData(d))
       .catch(e => setError(e.message))
       .finally(() => setLoading(false))
+
+    // Follow later writes so the output appears as soon as the step stores it
+    const key = encodeURIComponent(`${chainName}.${stepName}`)
+    const source = new EventSource(`/api/kv/chain-outputs/watch?key=${key}&updatesOnly=true`)
+    source.addEventListener('kv', (e: MessageEvent) => {
+      const ev = JSON.parse(e.data) as { operation: string; value?: Record<string, unknown> }
+      if (ev.operation === 'put' && ev.value) {
+        setData(ev.value)
+        setError('')
+      } else if (ev.operation !== 'put') {
+        setData(null)
+      }
+    })
+    return () => source.close()
   }, [chainName, stepName])
 
   if (loading) return <p className="text-xs text-gray-500 mt-2">Loading full output from KV...</p>
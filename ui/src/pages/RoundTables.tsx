@@ -1,7 +1,7 @@
 import { useState } from 'react'
-import { Crown, Shield, DollarSign, ChevronDown, Layers, Settings, CheckSquare } from 'lucide-react'
+import { Crown, Shield, DollarSign, ChevronDown, Layers, Settings, CheckSquare, Users } from 'lucide-react'
 import { usePolledFetch } from '../hooks/usePolledFetch'
-import type { RoundTable } from '../lib/types'
+import type { RoundTable, RoundTableMembers } from '../lib/types'
 import { PageHeader, RefreshButton, ErrorBanner, Spinner, EmptyState, PhaseBadge, ProgressBar } from '../components/ui'
 
 export function RoundTablesPage() {
@@ -45,7 +45,6 @@ export function RoundTablesPage() {
       <div className="grid grid-cols-1 md:grid-cols-2 xl:grid-cols-3 gap-4">
         {roundTables.map(rt => {
           const expanded = expandedCards.has(rt.name)
-          const hasDetail = rt.warmPool || rt.policies || rt.activeMissions != null || rt.totalTasksCompleted != null || rt.description || rt.suspended
           return (
             <div key={rt.name} className="bg-roundtable-slate border border-roundtable-steel rounded-xl hover:border-roundtable-gold/30 transition-colors">
               <div className="p-5">
@@ -113,20 +112,18 @@ export function RoundTablesPage() {
                   )}
                 </div>
 
-                {/* Expand toggle */}
-                {hasDetail && (
-                  <button
-                    onClick={() => toggleCard(rt.name)}
-                    className="mt-4 w-full flex items-center justify-center gap-1 text-xs text-gray-500 hover:text-roundtable-gold transition-colors"
-                  >
-                    <ChevronDown className={`w-3 h-3 transition-transform ${expanded ? 'rotate-180' : ''}`} />
-                    {expanded ? 'Less' : 'More details'}
-                  </button>
-                )}
+                {/* Expand toggle — always available, members are fetched on expand */}
+                <button
+                  onClick={() => toggleCard(rt.name)}
+                  className="mt-4 w-full flex items-center justify-center gap-1 text-xs text-gray-500 hover:text-roundtable-gold transition-colors"
+                >
+                  <ChevronDown className={`w-3 h-3 transition-transform ${expanded ? 'rotate-180' : ''}`} />
+                  {expanded ? 'Less' : 'More details'}
+                </button>
               </div>
 
               {/* Expanded detail section */}
-              {expanded && hasDetail && (
+              {expanded && (
                 <div className="border-t border-roundtable-steel/50 px-5 pb-5 pt-4 space-y-4">
                   {/* Description */}
                   {rt.description && (
@@ -202,6 +199,8 @@ export function RoundTablesPage() {
                       )}
                     </div>
                   )}
+
+                  <MembersPanel name={rt.name} />
                 </div>
               )}
             </div>
@@ -211,3 +210,62 @@ export function RoundTablesPage() {
     </div>
   )
 }
+
+/** Drill-down of the knights, missions and chains belonging to one table. */
+function MembersPanel({ name }: { name: string }) {
+  const { data: members, error } = usePolledFetch<RoundTableMembers | null>(
+    `/api/roundtables/${encodeURIComponent(name)}/members`, 15000, null,
+  )
+
+  if (error) return <ErrorBanner>Failed to load members: {error}</ErrorBanner>
+  if (!members) return <div className="flex justify-center"><Spinner /></div>
+
+  return (
+    <div>
+      <h4 className="text-xs font-medium text-gray-400 mb-2 flex items-center gap-1.5">
+        <Users className="w-3.5 h-3.5" />
+        Members
+        <span className="text-gray-500 font-normal">
+          · {members.totals.knightsReady}/{members.totals.knights} knights ready · ${members.totals.costUSD.toFixed(2)}
+        </span>
+      </h4>
+      <div className="bg-roundtable-navy rounded-lg p-3 space-y-3 text-xs">
+        {members.knights.length === 0 && members.missions.length === 0 && members.chains.length === 0 && (
+          <p className="text-gray-500">No members.</p>
+        )}
+        {members.knights.length > 0 && (
+          <div className="space-y-1">
+            {members.knights.map(k => (
+              <div key={k.name} className="flex items-center justify-between">
+                <span className={k.ready ? 'text-green-400' : 'text-gray-400'}>{k.name}</span>
+                <span className="text-gray-500">
+                  {k.tasksCompleted} done · {k.tasksFailed} failed{k.totalCost ? ` · $${k.totalCost}` : ''}
+                </span>
+              </div>
+            ))}
+          </div>
+        )}
+        {members.missions.length > 0 && (
+          <div className="space-y-1 border-t border-roundtable-steel/50 pt-2">
+            {members.missions.map(m => (
+              <div key={m.name} className="flex items-center justify-between">
+                <span className="text-gray-300">{m.name}</span>
+                <PhaseBadge phase={m.phase || 'Unknown'} />
+              </div>
+            ))}
+          </div>
+        )}
+        {members.chains.length > 0 && (
+          <div className="space-y-1 border-t border-roundtable-steel/50 pt-2">
+            {members.chains.map(c => (
+              <div key={c.name} className="flex items-center justify-between">
+                <span className="text-gray-300">{c.name}</span>
+                <span className="text-gray-500">{c.steps} steps · {c.phase || 'Idle'}</span>
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}